@@ -0,0 +1,225 @@
+// Package auth authenticates REST API clients by API key or JWT bearer
+// token and checks which corps and operations they may call.
+package auth
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+
+	"server/config"
+	"server/logger"
+	"server/metrics"
+)
+
+// KeyHeader carries the API key of a client.
+const KeyHeader = "X-API-Key"
+
+const clientEnv = "CLIENT"
+
+// Reasons a request is denied, reported in the audit log and metrics.
+const (
+	reasonMissing       = "missing_credentials"
+	reasonBadKey        = "bad_key"
+	reasonBadToken      = "bad_token"
+	reasonUnknownClient = "unknown_client"
+	reasonCorp          = "corp"
+	reasonOperation     = "operation"
+)
+
+// Client is an authenticated API client.
+type Client struct {
+	Name string
+	config.Client
+}
+
+// may returns why c may not call op for corp, or "" when it may. An
+// empty corp, for endpoints that are not tied to one, only checks op.
+func (c *Client) may(corp, op string) string {
+	if corp != "" && !contains(c.Corps, corp) {
+		return reasonCorp
+	}
+	if !contains(c.Operations, op) {
+		return reasonOperation
+	}
+	return ""
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == "*" || v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy is a compiled Auth configuration.
+type Policy struct {
+	cfg     config.Auth
+	clients map[string]*Client
+	// keys maps the SHA-256 of each API key to its client, so lookups
+	// do not compare the keys themselves.
+	keys map[[sha256.Size]byte]*Client
+	jwks keySet
+}
+
+// Load checks cfg and reads its JWKS file.
+func Load(cfg config.Auth) (*Policy, error) {
+	p := &Policy{
+		cfg:     cfg,
+		clients: make(map[string]*Client),
+		keys:    make(map[[sha256.Size]byte]*Client),
+	}
+	for name, cc := range cfg.Clients {
+		c := &Client{Name: name, Client: cc}
+		p.clients[name] = c
+		for _, k := range cc.Keys {
+			sum := sha256.Sum256([]byte(k))
+			if other, ok := p.keys[sum]; ok {
+				return nil, fmt.Errorf("auth: clients %s and %s share an API key", other.Name, name)
+			}
+			p.keys[sum] = c
+		}
+	}
+	if cfg.JWKS != "" {
+		ks, err := loadJWKS(cfg.JWKS)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %v", err)
+		}
+		p.jwks = ks
+	}
+	return p, nil
+}
+
+// authenticate returns the client req comes from, or the reason it is
+// refused.
+func (p *Policy) authenticate(req *rest.Request) (*Client, string) {
+	if key := req.Header.Get(KeyHeader); key != "" {
+		if c, ok := p.keys[sha256.Sum256([]byte(key))]; ok {
+			return c, ""
+		}
+		return nil, reasonBadKey
+	}
+
+	h := req.Header.Get("Authorization")
+	if !strings.HasPrefix(h, "Bearer ") {
+		return nil, reasonMissing
+	}
+	if p.jwks == nil {
+		return nil, reasonBadToken
+	}
+	cl, err := p.jwks.verify(strings.TrimPrefix(h, "Bearer "), time.Now())
+	if err != nil {
+		logger.Debug("token refused", "request_id", logger.RequestID(req), "err", err)
+		return nil, reasonBadToken
+	}
+	if p.cfg.Issuer != "" && cl.Issuer != p.cfg.Issuer {
+		return nil, reasonBadToken
+	}
+	if p.cfg.Audience != "" && !cl.Audience.contains(p.cfg.Audience) {
+		return nil, reasonBadToken
+	}
+	c, ok := p.clients[cl.Subject]
+	if !ok {
+		return nil, reasonUnknownClient
+	}
+	return c, ""
+}
+
+// Middleware authenticates every request except the /admin/ endpoints,
+// which have their own token, and the health probes. It lets everything
+// through while the policy has no clients.
+type Middleware struct {
+	mu     sync.RWMutex
+	policy *Policy
+}
+
+// SetPolicy replaces the policy for new requests.
+func (mw *Middleware) SetPolicy(p *Policy) {
+	mw.mu.Lock()
+	mw.policy = p
+	mw.mu.Unlock()
+}
+
+func (mw *Middleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, req *rest.Request) {
+		mw.mu.RLock()
+		p := mw.policy
+		mw.mu.RUnlock()
+
+		if p == nil || len(p.clients) == 0 || exempt(req.URL.Path) {
+			h(w, req)
+			return
+		}
+		c, reason := p.authenticate(req)
+		if c == nil {
+			audit(req, "", reason)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			rest.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+		req.Env[clientEnv] = c
+		h(w, req)
+	}
+}
+
+func exempt(path string) bool {
+	return strings.HasPrefix(path, "/admin/") || path == "/healthz" || path == "/readyz"
+}
+
+// ClientName returns the name of the client authenticated for req, or
+// "" when authentication is off.
+func ClientName(req *rest.Request) string {
+	if c, ok := req.Env[clientEnv].(*Client); ok {
+		return c.Name
+	}
+	return ""
+}
+
+// CorpParam reads the corp from the :corp path parameter.
+func CorpParam(req *rest.Request) string {
+	return req.PathParam("corp")
+}
+
+// Allow wraps h so that only clients permitted op on the corp of the
+// request, from the :corp path parameter, reach it.
+func Allow(op string, h rest.HandlerFunc) rest.HandlerFunc {
+	return AllowCorp(op, CorpParam, h)
+}
+
+// AllowCorp is Allow with the corp of the request read by corp.
+func AllowCorp(op string, corp func(*rest.Request) string, h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, req *rest.Request) {
+		c, ok := req.Env[clientEnv].(*Client)
+		if !ok {
+			h(w, req)
+			return
+		}
+		if reason := c.may(corp(req), op); reason != "" {
+			audit(req, c.Name, reason)
+			rest.Error(w, "not allowed", http.StatusForbidden)
+			return
+		}
+		h(w, req)
+	}
+}
+
+// audit records a refused request.
+func audit(req *rest.Request, client, reason string) {
+	metrics.AuthDenied.WithLabelValues(reason).Inc()
+	logger.Warn("access denied",
+		"audit", true,
+		"request_id", logger.RequestID(req),
+		"client", client,
+		"remote", req.RemoteAddr,
+		"method", req.Method,
+		"path", logger.Path(req),
+		"reason", reason,
+	)
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+
+	"server/config"
+)
+
+func newRequest(header, value string) *rest.Request {
+	r, _ := http.NewRequest("GET", "/balance/dtac/66812345678", nil)
+	if header != "" {
+		r.Header.Set(header, value)
+	}
+	return &rest.Request{Request: r, Env: map[string]interface{}{}}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	p, err := Load(config.Auth{Clients: map[string]config.Client{
+		"crm": {Keys: []string{"s3cret"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c, _ := p.authenticate(newRequest(KeyHeader, "s3cret")); c == nil || c.Name != "crm" {
+		t.Error("It should authenticate crm but got ", c)
+	}
+	if _, reason := p.authenticate(newRequest(KeyHeader, "guess")); reason != reasonBadKey {
+		t.Error("It should be "+reasonBadKey+" but was ", reason)
+	}
+	if _, reason := p.authenticate(newRequest("", "")); reason != reasonMissing {
+		t.Error("It should be "+reasonMissing+" but was ", reason)
+	}
+}
+
+func TestAuthenticateJWT(t *testing.T) {
+	rk, _, ks := testKeys(t)
+	p, err := Load(config.Auth{Audience: "dccserve", Clients: map[string]config.Client{"crm": {}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.jwks = ks
+	exp := time.Now().Add(time.Minute).Unix()
+
+	tok := sign(t, rk, "RS256", "rsa", map[string]interface{}{"sub": "crm", "aud": []string{"dccserve"}, "exp": exp})
+	if c, _ := p.authenticate(newRequest("Authorization", "Bearer "+tok)); c == nil || c.Name != "crm" {
+		t.Error("It should authenticate crm but got ", c)
+	}
+
+	tok = sign(t, rk, "RS256", "rsa", map[string]interface{}{"sub": "crm", "aud": "other", "exp": exp})
+	if _, reason := p.authenticate(newRequest("Authorization", "Bearer "+tok)); reason != reasonBadToken {
+		t.Error("It should be "+reasonBadToken+" but was ", reason)
+	}
+
+	tok = sign(t, rk, "RS256", "rsa", map[string]interface{}{"sub": "billing", "aud": "dccserve", "exp": exp})
+	if _, reason := p.authenticate(newRequest("Authorization", "Bearer "+tok)); reason != reasonUnknownClient {
+		t.Error("It should be "+reasonUnknownClient+" but was ", reason)
+	}
+}
+
+func TestLoadRefusesSharedKeys(t *testing.T) {
+	_, err := Load(config.Auth{Clients: map[string]config.Client{
+		"crm":     {Keys: []string{"same"}},
+		"billing": {Keys: []string{"same"}},
+	}})
+	if err == nil {
+		t.Error("It should refuse an API key shared by two clients")
+	}
+}
+
+func TestClientMay(t *testing.T) {
+	c := &Client{Name: "crm", Client: config.Client{
+		Corps:      []string{"dtac"},
+		Operations: []string{"balance", "check"},
+	}}
+	for _, tc := range []struct {
+		corp, op, want string
+	}{
+		{"dtac", "balance", ""},
+		{"dtn", "balance", reasonCorp},
+		{"dtac", "debit", reasonOperation},
+		{"", "check", ""},
+	} {
+		if got := c.may(tc.corp, tc.op); got != tc.want {
+			t.Error("It should be \""+tc.want+"\" for "+tc.corp+"/"+tc.op+" but was ", got)
+		}
+	}
+
+	all := &Client{Client: config.Client{Corps: []string{"*"}, Operations: []string{"*"}}}
+	if got := all.may("dtn", "refund"); got != "" {
+		t.Error("It should allow everything with * but was ", got)
+	}
+}
+
+// serve sends a request for path with the API key key through the
+// middlewares mw to a handler answering 200.
+func serve(t *testing.T, path, key string, mw ...rest.Middleware) *httptest.ResponseRecorder {
+	api := rest.NewApi()
+	api.Use(mw...)
+	ok := func(w rest.ResponseWriter, req *rest.Request) { w.WriteJson(map[string]string{"status": "ok"}) }
+	router, err := rest.MakeRouter(
+		&rest.Route{HttpMethod: "GET", PathExp: "/balance/:corp/:subr", Func: ok},
+		&rest.Route{HttpMethod: "GET", PathExp: "/admin/capture", Func: ok},
+		&rest.Route{HttpMethod: "GET", PathExp: "/healthz", Func: ok},
+		&rest.Route{HttpMethod: "GET", PathExp: "/readyz", Func: ok},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.SetApp(router)
+
+	r, _ := http.NewRequest("GET", "http://localhost"+path, nil)
+	if key != "" {
+		r.Header.Set(KeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	api.MakeHandler().ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	p, err := Load(config.Auth{Clients: map[string]config.Client{
+		"crm": {Keys: []string{"s3cret"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		path, key string
+		want      int
+	}{
+		{"/balance/dtac/66812345678", "s3cret", http.StatusOK},
+		{"/balance/dtac/66812345678", "", http.StatusUnauthorized},
+		{"/balance/dtac/66812345678", "guess", http.StatusUnauthorized},
+		{"/healthz", "", http.StatusOK},
+		{"/readyz", "", http.StatusOK},
+		// The admin token is checked by balance.AdminMiddleware.
+		{"/admin/capture", "", http.StatusOK},
+	} {
+		mw := &Middleware{}
+		mw.SetPolicy(p)
+		w := serve(t, tt.path, tt.key, mw)
+		if w.Code != tt.want {
+			t.Error("It should be ", tt.want, " for "+tt.path+" but was ", w.Code)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Error("It should ask for a bearer token for " + tt.path)
+		}
+	}
+
+	open := &Middleware{}
+	open.SetPolicy(&Policy{})
+	if w := serve(t, "/balance/dtac/66812345678", "", open); w.Code != http.StatusOK {
+		t.Error("It should let everything through without clients but was ", w.Code)
+	}
+}
+
+func TestAllowCorp(t *testing.T) {
+	crm := &Client{Name: "crm", Client: config.Client{Corps: []string{"dtac"}, Operations: []string{"session"}}}
+	ok := func(w rest.ResponseWriter, req *rest.Request) { w.WriteJson(map[string]string{"status": "ok"}) }
+	for _, tt := range []struct {
+		client *Client
+		corp   string
+		op     string
+		want   int
+	}{
+		{crm, "dtac", "session", http.StatusOK},
+		{crm, "dtn", "session", http.StatusForbidden},
+		{crm, "dtac", "debit", http.StatusForbidden},
+		// An unknown session has no corp; its handler answers 404.
+		{crm, "", "session", http.StatusOK},
+		{nil, "dtn", "debit", http.StatusOK},
+	} {
+		client, corp := tt.client, tt.corp
+		api := rest.NewApi()
+		api.Use(rest.MiddlewareSimple(func(h rest.HandlerFunc) rest.HandlerFunc {
+			return func(w rest.ResponseWriter, req *rest.Request) {
+				if client != nil {
+					req.Env[clientEnv] = client
+				}
+				h(w, req)
+			}
+		}))
+		router, err := rest.MakeRouter(&rest.Route{HttpMethod: "GET", PathExp: "/sessions/:id",
+			Func: AllowCorp(tt.op, func(*rest.Request) string { return corp }, ok)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		api.SetApp(router)
+
+		r, _ := http.NewRequest("GET", "http://localhost/sessions/dtac.co.th;OMR1", nil)
+		w := httptest.NewRecorder()
+		api.MakeHandler().ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Error("It should be ", tt.want, " for "+tt.op+" on \""+corp+"\" but was ", w.Code)
+		}
+	}
+}
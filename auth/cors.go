@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+
+	"server/config"
+)
+
+// origin is an allowed CORS origin. A wildcard one matches every
+// subdomain of host, but not host itself.
+type origin struct {
+	scheme   string
+	host     string
+	wildcard bool
+}
+
+// origins is the compiled list of config.CORS.Origins. any is set by
+// "*".
+type origins struct {
+	any  bool
+	list []origin
+}
+
+func compileOrigins(patterns []string) (origins, error) {
+	var o origins
+	for _, p := range patterns {
+		if p == "*" {
+			o.any = true
+			continue
+		}
+		u, err := url.Parse(p)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return o, fmt.Errorf("cors: bad origin %q, want scheme://host[:port]", p)
+		}
+		or := origin{scheme: strings.ToLower(u.Scheme), host: strings.ToLower(u.Host)}
+		if strings.HasPrefix(or.host, "*.") {
+			or.wildcard = true
+			or.host = or.host[1:]
+		}
+		if strings.Contains(or.host, "*") {
+			return o, fmt.Errorf("cors: bad origin %q, * only as the first label", p)
+		}
+		o.list = append(o.list, or)
+	}
+	return o, nil
+}
+
+// allow reports whether the Origin header value s matches.
+func (o origins) allow(s string) bool {
+	if o.any {
+		return true
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return false
+	}
+	scheme, host := strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+	for _, or := range o.list {
+		if or.scheme != scheme {
+			continue
+		}
+		if or.wildcard && strings.HasSuffix(host, or.host) && len(host) > len(or.host) {
+			return true
+		}
+		if !or.wildcard && or.host == host {
+			return true
+		}
+	}
+	return false
+}
+
+// exposedHeaders are the response headers browsers let scripts read.
+var exposedHeaders = []string{"X-Request-Id", "X-Cache", "Idempotent-Replayed", "Retry-After"}
+
+// NewCors returns the CORS middleware for cfg. Requests without an
+// Origin header, such as those of server-side clients, pass through.
+func NewCors(cfg config.CORS) (*rest.CorsMiddleware, error) {
+	o, err := compileOrigins(cfg.Origins)
+	if err != nil {
+		return nil, err
+	}
+	if o.any && cfg.Credentials {
+		return nil, fmt.Errorf("cors: credentials cannot be allowed for every origin")
+	}
+	return &rest.CorsMiddleware{
+		RejectNonCorsRequests: false,
+		OriginValidator: func(origin string, request *rest.Request) bool {
+			return o.allow(origin)
+		},
+		AllowedMethods:                cfg.Methods,
+		AllowedHeaders:                cfg.Headers,
+		AccessControlExposeHeaders:    exposedHeaders,
+		AccessControlAllowCredentials: cfg.Credentials,
+		AccessControlMaxAge:           int(cfg.MaxAge.Seconds()),
+	}, nil
+}
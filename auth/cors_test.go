@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+
+	"server/config"
+)
+
+func TestOriginsAllow(t *testing.T) {
+	o, err := compileOrigins([]string{"https://portal.dtac.co.th", "https://*.example.com", "http://localhost:3000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for origin, want := range map[string]bool{
+		"https://portal.dtac.co.th":  true,
+		"https://PORTAL.dtac.co.th":  true,
+		"http://portal.dtac.co.th":   false,
+		"https://a.example.com":      true,
+		"https://a.b.example.com":    true,
+		"https://example.com":        false,
+		"https://evilexample.com":    false,
+		"https://example.com.evil":   false,
+		"http://localhost:3000":      true,
+		"http://localhost:3001":      false,
+		"null":                       false,
+		"https://portal.dtac.co.th.": false,
+	} {
+		if got := o.allow(origin); got != want {
+			t.Error("It should be ", want, " for "+origin+" but was ", got)
+		}
+	}
+}
+
+func TestCompileOriginsRejectsBadPatterns(t *testing.T) {
+	for _, p := range []string{"portal.dtac.co.th", "https://a.*.example.com", "https://example.com/path"} {
+		if _, err := compileOrigins([]string{p}); err == nil {
+			t.Error("It should refuse the origin ", p)
+		}
+	}
+}
+
+func TestNewCorsRefusesAnyOriginWithCredentials(t *testing.T) {
+	if _, err := NewCors(config.CORS{Origins: []string{"*"}, Credentials: true}); err == nil {
+		t.Error("It should refuse credentials for every origin")
+	}
+}
+
+// preflight sends an OPTIONS preflight from origin through the CORS
+// middleware of cfg.
+func preflight(t *testing.T, cfg config.CORS, origin, method, headers string) *httptest.ResponseRecorder {
+	cors, err := NewCors(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := rest.NewApi()
+	api.Use(cors)
+	router, err := rest.MakeRouter(&rest.Route{HttpMethod: "GET", PathExp: "/balance/:corp/:subr", Func: func(w rest.ResponseWriter, req *rest.Request) {
+		w.WriteJson(map[string]string{"status": "ok"})
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.SetApp(router)
+
+	r, _ := http.NewRequest("OPTIONS", "http://localhost/balance/dtac/66812345678", nil)
+	r.Header.Set("Origin", origin)
+	r.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		r.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	w := httptest.NewRecorder()
+	api.MakeHandler().ServeHTTP(w, r)
+	return w
+}
+
+func TestPreflight(t *testing.T) {
+	cfg := config.Default().CORS
+	cfg.Origins = []string{"https://*.dtac.co.th"}
+	cfg.Credentials = true
+
+	w := preflight(t, cfg, "https://portal.dtac.co.th", "GET", "Authorization")
+	if w.Code != http.StatusOK {
+		t.Fatal("It should be 200 but was ", w.Code)
+	}
+	if v := w.Header().Get("Access-Control-Allow-Origin"); v != "https://portal.dtac.co.th" {
+		t.Error("It should echo the origin but was ", v)
+	}
+	if v := w.Header().Get("Access-Control-Allow-Credentials"); v != "true" {
+		t.Error("It should allow credentials but was ", v)
+	}
+	if v := w.Header().Get("Access-Control-Max-Age"); v != "3600" {
+		t.Error("It should be 3600 but was ", v)
+	}
+}
+
+func TestPreflightRejected(t *testing.T) {
+	cfg := config.Default().CORS
+	cfg.Origins = []string{"https://*.dtac.co.th"}
+
+	for name, w := range map[string]*httptest.ResponseRecorder{
+		"origin": preflight(t, cfg, "https://evil.example.com", "GET", ""),
+		"method": preflight(t, cfg, "https://portal.dtac.co.th", "PATCH", ""),
+		"header": preflight(t, cfg, "https://portal.dtac.co.th", "GET", "X-Forwarded-For"),
+	} {
+		if w.Code != http.StatusForbidden {
+			t.Error("It should refuse the preflight with a bad "+name+" but was ", w.Code)
+		}
+		if v := w.Header().Get("Access-Control-Allow-Origin"); v != "" {
+			t.Error("It should not allow the origin for a bad "+name+" but was ", v)
+		}
+	}
+}
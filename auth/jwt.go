@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed = errors.New("auth: malformed token")
+	ErrSignature = errors.New("auth: bad token signature")
+	ErrExpired   = errors.New("auth: token expired")
+)
+
+// jwk is a public key of a JWKS file (RFC 7517). Only RSA and EC keys
+// are used.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// keySet holds the public keys of a JWKS file by key id.
+type keySet map[string]crypto.PublicKey
+
+// loadJWKS reads the public keys of the JWKS file filename.
+func loadJWKS(filename string) (keySet, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(b)
+}
+
+func parseJWKS(b []byte) (keySet, error) {
+	var f struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+	ks := make(keySet)
+	for _, k := range f.Keys {
+		pub, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwks key %q: %v", k.Kid, err)
+		}
+		ks[k.Kid] = pub
+	}
+	return ks, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// claims are the registered JWT claims we check.
+type claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	Expires   int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+}
+
+// audience is the aud claim, a single string or a list of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(a))
+}
+
+func (a audience) contains(s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// algorithms maps the supported JWS algorithms to their hash.
+var algorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// verify checks the signature and lifetime of the compact JWS token and
+// returns its claims. Tokens without an expiry are refused.
+func (ks keySet) verify(token string, now time.Time) (*claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodePart(parts[0], &header); err != nil {
+		return nil, err
+	}
+	hash, ok := algorithms[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("auth: unsupported algorithm %q", header.Alg)
+	}
+	pub, ok := ks[header.Kid]
+	if !ok {
+		return nil, fmt.Errorf("auth: unknown key %q", header.Kid)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	if !verifySignature(pub, header.Alg, hash, h.Sum(nil), sig) {
+		return nil, ErrSignature
+	}
+
+	var c claims
+	if err := decodePart(parts[1], &c); err != nil {
+		return nil, err
+	}
+	if c.Expires == 0 || now.Unix() >= c.Expires {
+		return nil, ErrExpired
+	}
+	if c.NotBefore != 0 && now.Unix() < c.NotBefore {
+		return nil, fmt.Errorf("auth: token not valid yet")
+	}
+	return &c, nil
+}
+
+func verifySignature(pub crypto.PublicKey, alg string, hash crypto.Hash, digest, sig []byte) bool {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return alg[:2] == "RS" && rsa.VerifyPKCS1v15(k, hash, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" || len(sig) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	}
+	return false
+}
+
+func decodePart(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return ErrMalformed
+	}
+	if err = json.Unmarshal(b, v); err != nil {
+		return ErrMalformed
+	}
+	return nil
+}
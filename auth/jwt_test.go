@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// sign builds a token with claims c signed by key under alg and kid.
+func sign(t *testing.T, key crypto.Signer, alg, kid string, c map[string]interface{}) string {
+	h, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	p, _ := json.Marshal(c)
+	input := b64(h) + "." + b64(p)
+	hash := algorithms[alg]
+	d := hash.New()
+	d.Write([]byte(input))
+	digest := d.Sum(nil)
+
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, hash, digest); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		sig = make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+	}
+	return input + "." + b64(sig)
+}
+
+func testKeys(t *testing.T) (*rsa.PrivateKey, *ecdsa.PrivateKey, keySet) {
+	rk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, _ := json.Marshal(map[string][]jwk{"keys": {
+		{Kty: "RSA", Kid: "rsa", N: b64(rk.N.Bytes()), E: b64(big.NewInt(int64(rk.E)).Bytes())},
+		{Kty: "EC", Kid: "ec", Crv: "P-256", X: b64(ek.X.Bytes()), Y: b64(ek.Y.Bytes())},
+	}})
+	ks, err := parseJWKS(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rk, ek, ks
+}
+
+func TestVerify(t *testing.T) {
+	rk, ek, ks := testKeys(t)
+	now := time.Now()
+	c := map[string]interface{}{"sub": "crm", "aud": "dccserve", "exp": now.Add(time.Minute).Unix()}
+
+	for _, tok := range []string{sign(t, rk, "RS256", "rsa", c), sign(t, ek, "ES256", "ec", c)} {
+		cl, err := ks.verify(tok, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cl.Subject != "crm" || !cl.Audience.contains("dccserve") {
+			t.Error("Unexpected claims ", cl)
+		}
+	}
+}
+
+func TestVerifyRefusesBadTokens(t *testing.T) {
+	rk, ek, ks := testKeys(t)
+	now := time.Now()
+	valid := map[string]interface{}{"sub": "crm", "exp": now.Add(time.Minute).Unix()}
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	for name, tok := range map[string]string{
+		"expired":   sign(t, rk, "RS256", "rsa", map[string]interface{}{"sub": "crm", "exp": now.Add(-time.Minute).Unix()}),
+		"no expiry": sign(t, rk, "RS256", "rsa", map[string]interface{}{"sub": "crm"}),
+		"wrong key": sign(t, other, "RS256", "rsa", valid),
+		"wrong alg": sign(t, ek, "RS256", "ec", valid),
+		"unknown":   sign(t, rk, "RS256", "nope", valid),
+		"none":      b64([]byte(`{"alg":"none","kid":"rsa"}`)) + "." + b64([]byte(`{"sub":"crm"}`)) + ".",
+		"garbage":   "not.a.token",
+	} {
+		if _, err := ks.verify(tok, now); err == nil {
+			t.Error("It should refuse the token: ", name)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+// Package avps holds the vendor AVP codes, enumerated values and
+// grouped AVP structs generated from the dictionary XML. Run go
+// generate after editing the dictionary files.
+package avps
+
+//go:generate go run ../dictionary/avpgen -out avps_gen.go -structs Balance-Information -skip Calling-Party-Address,Access-Method,Account-Query-Method,SSP-Time
@@ -0,0 +1,1110 @@
+// Code generated by avpgen from the dictionary XML. DO NOT EDIT.
+
+package avps
+
+// AVP codes.
+const (
+	TGPPIMSI                              = 1     // vendor 10415, UTF8String
+	TGPPChargingId                        = 2     // vendor 10415, OctetString
+	TGPPPDPType                           = 3     // vendor 10415, Enumerated
+	TGPPIMSIMCCMNC                        = 8     // vendor 10415, UTF8String
+	TGPPGGSNMCCMNC                        = 9     // vendor 10415, UTF8String
+	TGPPNSAPI                             = 10    // vendor 10415, OctetString
+	TGPPSessionStopIndicator              = 11    // vendor 10415, OctetString
+	TGPPSelectionMode                     = 12    // vendor 10415, UTF8String
+	TGPPChargingCharacteristics           = 13    // vendor 10415, UTF8String
+	TGPPSGSNMCCMNC                        = 18    // vendor 10415, UTF8String
+	TGPPRATType                           = 21    // vendor 10415, OctetString
+	TGPPUserLocationInfo                  = 22    // vendor 10415, OctetString
+	TGPPMSTimeZone                        = 23    // vendor 10415, OctetString
+	AccessNetworkChargingIdentifierValue  = 503   // vendor 10415, OctetString
+	AFChargingIdentifier                  = 505   // vendor 10415, OctetString
+	Flows                                 = 510   // vendor 10415, Grouped
+	MaxRequestedBandwidthDL               = 515   // vendor 10415, Unsigned32
+	MaxRequestedBandwidthUL               = 516   // vendor 10415, Unsigned32
+	SponsorIdentity                       = 531   // vendor 10415, UTF8String
+	ApplicationServiceProviderIdentity    = 532   // vendor 10415, OctetString
+	ServerName                            = 602   // vendor 10415, UTF8String
+	ServerCapabilities                    = 603   // vendor 10415, Grouped
+	MandatoryCapability                   = 604   // vendor 10415, Unsigned32
+	OptionalCapability                    = 605   // vendor 10415, Unsigned32
+	UserData                              = 606   // vendor 10415, OctetString
+	SessionPriority                       = 650   // vendor 10415, Enumerated
+	MSISDN                                = 701   // vendor 10415, OctetString
+	EventType                             = 823   // vendor 10415, Grouped
+	SIPMethod                             = 824   // vendor 10415, UTF8String
+	Event                                 = 825   // vendor 10415, UTF8String
+	ContentType                           = 826   // vendor 10415, UTF8String
+	ContentLength                         = 827   // vendor 10415, Unsigned32
+	ContentDisposition                    = 828   // vendor 10415, UTF8String
+	RoleOfNode                            = 829   // vendor 10415, Enumerated
+	UserSessionId                         = 830   // vendor 10415, UTF8String
+	CalledPartyAddress                    = 832   // vendor 10415, UTF8String
+	TimeStamps                            = 833   // vendor 10415, Grouped
+	SIPRequestTimestamp                   = 834   // vendor 10415, Time
+	SIPResponseTimestamp                  = 835   // vendor 10415, Time
+	ApplicationServer                     = 836   // vendor 10415, UTF8String
+	ApplicationProvidedCalledPartyAddress = 837   // vendor 10415, UTF8String
+	InterOperatorIdentifier               = 838   // vendor 10415, Grouped
+	OriginatingIOI                        = 839   // vendor 10415, UTF8String
+	TerminatingIOI                        = 840   // vendor 10415, UTF8String
+	IMSChargingIdentifier                 = 841   // vendor 10415, UTF8String
+	SDPSessionDescription                 = 842   // vendor 10415, UTF8String
+	SDPMediaComponent                     = 843   // vendor 10415, Grouped
+	SDPMediaName                          = 844   // vendor 10415, UTF8String
+	SDPMediaDescription                   = 845   // vendor 10415, UTF8String
+	CGAddress                             = 846   // vendor 10415, Address
+	GGSNAddress                           = 847   // vendor 10415, Address
+	ServedPartyIPAddress                  = 848   // vendor 10415, Address
+	AuthorisedQoS                         = 849   // vendor 10415, UTF8String
+	ApplicationServerInformation          = 850   // vendor 10415, Grouped
+	TrunkGroupId                          = 851   // vendor 10415, Grouped
+	IncomingTrunkGroupId                  = 852   // vendor 10415, UTF8String
+	OutgoingTrunkGroupId                  = 853   // vendor 10415, UTF8String
+	BearerService                         = 854   // vendor 10415, OctetString
+	ServiceId                             = 855   // vendor 10415, UTF8String
+	AssociatedURI                         = 856   // vendor 10415, UTF8String
+	ChargedParty                          = 857   // vendor 10415, UTF8String
+	PoCControllingAddress                 = 858   // vendor 10415, UTF8String
+	PoCGroupName                          = 859   // vendor 10415, UTF8String
+	CauseCode                             = 861   // vendor 10415, Integer32
+	NodeFunctionality                     = 862   // vendor 10415, Enumerated
+	ServiceSpecificData                   = 863   // vendor 10415, UTF8String
+	Originator                            = 864   // vendor 10415, Enumerated
+	PSFurnishChargingInformation          = 865   // vendor 10415, Grouped
+	PSFreeFormatData                      = 866   // vendor 10415, OctetString
+	PSAppendFreeFormatData                = 867   // vendor 10415, Enumerated
+	TimeQuotaThreshold                    = 868   // vendor 10415, Unsigned32
+	VolumeQuotaThreshold                  = 869   // vendor 10415, Unsigned32
+	TriggerType                           = 870   // vendor 10415, Enumerated
+	QuotaHoldingTime                      = 871   // vendor 10415, Unsigned32
+	ReportingReason                       = 872   // vendor 10415, Enumerated
+	ServiceInformation                    = 873   // vendor 10415, Grouped
+	PSInformation                         = 874   // vendor 10415, Grouped
+	IMSInformation                        = 876   // vendor 10415, Grouped
+	MMSInformation                        = 877   // vendor 10415, Grouped
+	LCSInformation                        = 878   // vendor 10415, Grouped
+	PoCInformation                        = 879   // vendor 10415, Grouped
+	MBMSInformation                       = 880   // vendor 10415, Grouped
+	QuotaConsumptionTime                  = 881   // vendor 10415, Unsigned32
+	MediaInitiatorFlag                    = 882   // vendor 10415, Enumerated
+	PoCServerRole                         = 883   // vendor 10415, Enumerated
+	PoCSessionType                        = 884   // vendor 10415, Enumerated
+	NumberOfParticipants                  = 885   // vendor 10415, Unsigned32
+	OriginatorAddress                     = 886   // vendor 10415, Grouped
+	ParticipantsInvolved                  = 887   // vendor 10415, UTF8String
+	Expires                               = 888   // vendor 10415, Unsigned32
+	MessageBody                           = 889   // vendor 10415, Grouped
+	AddressData                           = 897   // vendor 10415, UTF8String
+	AddressDomain                         = 898   // vendor 10415, Grouped
+	AddressType                           = 899   // vendor 10415, Enumerated
+	TMGI                                  = 900   // vendor 10415, OctetString
+	RequiredMBMSBearerCapabilities        = 901   // vendor 10415, UTF8String
+	MBMSServiceArea                       = 903   // vendor 10415, OctetString
+	MBMSServiceType                       = 906   // vendor 10415, Enumerated
+	MBMS2G3GIndicator                     = 907   // vendor 10415, Enumerated
+	MBMSSessionIdentity                   = 908   // vendor 10415, OctetString
+	RAI                                   = 909   // vendor 10415, UTF8String
+	CNIPMulticastDistribution             = 921   // vendor 10415, Enumerated
+	ChargingRuleBaseName                  = 1004  // vendor 10415, UTF8String
+	QoSInformation                        = 1016  // vendor 10415, Grouped
+	GuaranteedBitrateUL                   = 1026  // vendor 10415, Unsigned32
+	QoSClassIdentifier                    = 1028  // vendor 10415, Enumerated
+	RATType                               = 1032  // vendor 10415, Enumerated
+	AllocationRetentionPriority           = 1034  // vendor 10415, Grouped
+	PriorityLevel                         = 1046  // vendor 10415, Unsigned32
+	TDFIPAddress                          = 1091  // vendor 10415, Address
+	ADCRuleBaseName                       = 1095  // vendor 10415, UTF8String
+	VASPId                                = 1101  // vendor 10415, UTF8String
+	VASId                                 = 1102  // vendor 10415, UTF8String
+	DomainName                            = 1200  // vendor 10415, UTF8String
+	RecipientAddress                      = 1201  // vendor 10415, Grouped
+	SubmissionTime                        = 1202  // vendor 10415, Time
+	MMContentType                         = 1203  // vendor 10415, Grouped
+	TypeNumber                            = 1204  // vendor 10415, UTF8String
+	AdditionalTypeInformation             = 1205  // vendor 10415, UTF8String
+	ContentSize                           = 1206  // vendor 10415, Unsigned32
+	AdditionalContentInformation          = 1207  // vendor 10415, Grouped
+	AddresseeType                         = 1208  // vendor 10415, Enumerated
+	Priority                              = 1209  // vendor 10415, Enumerated
+	MessageId                             = 1210  // vendor 10415, UTF8String
+	MessageType                           = 1211  // vendor 10415, Enumerated
+	MessageSize                           = 1212  // vendor 10415, Unsigned32
+	MessageClass                          = 1213  // vendor 10415, Grouped
+	ClassIdentifier                       = 1214  // vendor 10415, Enumerated
+	TokenText                             = 1215  // vendor 10415, UTF8String
+	DeliveryReportRequested               = 1216  // vendor 10415, Enumerated
+	Adaptations                           = 1217  // vendor 10415, Enumerated
+	ApplicId                              = 1218  // vendor 10415, UTF8String
+	AuxApplicInfo                         = 1219  // vendor 10415, UTF8String
+	ContentClass                          = 1220  // vendor 10415, Enumerated
+	DRMContent                            = 1221  // vendor 10415, Enumerated
+	ReadReplyReportRequested              = 1222  // vendor 10415, Enumerated
+	ReplyApplicId                         = 1223  // vendor 10415, UTF8String
+	FileRepairSupported                   = 1224  // vendor 10415, Enumerated
+	MBMSUserServiceType                   = 1225  // vendor 10415, Enumerated
+	UnitQuotaThreshold                    = 1226  // vendor 10415, Unsigned32
+	PDPAddress                            = 1227  // vendor 10415, Address
+	SGSNAddress                           = 1228  // vendor 10415, Address
+	PoCSessionId                          = 1229  // vendor 10415, UTF8String
+	DeferredLocationEventType             = 1230  // vendor 10415, UTF8String
+	LCSAPN                                = 1231  // vendor 10415, UTF8String
+	LCSClientId                           = 1232  // vendor 10415, Grouped
+	LCSClientDialedByMS                   = 1233  // vendor 10415, UTF8String
+	LCSClientExternalId                   = 1234  // vendor 10415, UTF8String
+	LCSClientName                         = 1235  // vendor 10415, Grouped
+	LCSDataCodingScheme                   = 1236  // vendor 10415, UTF8String
+	LCSFormatIndicator                    = 1237  // vendor 10415, Enumerated
+	LCSNameString                         = 1238  // vendor 10415, UTF8String
+	LCSRequestorId                        = 1239  // vendor 10415, Grouped
+	LCSRequestorIdString                  = 1240  // vendor 10415, UTF8String
+	LCSClientType                         = 1241  // vendor 10415, Enumerated
+	LocationEstimate                      = 1242  // vendor 10415, OctetString
+	LocationEstimateType                  = 1243  // vendor 10415, Enumerated
+	LocationType                          = 1244  // vendor 10415, Grouped
+	PositioningData                       = 1245  // vendor 10415, UTF8String
+	PDPContextType                        = 1247  // vendor 10415, Enumerated
+	MMBoxStorageRequested                 = 1248  // vendor 10415, Enumerated
+	ServiceSpecificInfo                   = 1249  // vendor 10415, Grouped
+	CalledAssertedIdentity                = 1250  // vendor 10415, UTF8String
+	RequestedPartyAddress                 = 1251  // vendor 10415, UTF8String
+	PoCUserRole                           = 1252  // vendor 10415, Grouped
+	PoCUserRoleIds                        = 1253  // vendor 10415, UTF8String
+	PoCUserRoleInfoUnits                  = 1254  // vendor 10415, Enumerated
+	TalkBurstExchange                     = 1255  // vendor 10415, Grouped
+	ServiceSpecificType                   = 1257  // vendor 10415, Unsigned32
+	EventChargingTimeStamp                = 1258  // vendor 10415, Time
+	ParticipantAccessPriority             = 1259  // vendor 10415, Enumerated
+	ParticipantGroup                      = 1260  // vendor 10415, Grouped
+	PoCChangeCondition                    = 1261  // vendor 10415, Enumerated
+	PoCChangeTime                         = 1262  // vendor 10415, Time
+	AccessNetworkInformation              = 1263  // vendor 10415, OctetString
+	Trigger                               = 1264  // vendor 10415, Grouped
+	BaseTimeInterval                      = 1265  // vendor 10415, Unsigned32
+	Envelope                              = 1266  // vendor 10415, Grouped
+	EnvelopeEndTime                       = 1267  // vendor 10415, Time
+	EnvelopeReporting                     = 1268  // vendor 10415, Enumerated
+	EnvelopeStartTime                     = 1269  // vendor 10415, Time
+	TimeQuotaMechanism                    = 1270  // vendor 10415, Grouped
+	TimeQuotaType                         = 1271  // vendor 10415, Enumerated
+	EarlyMediaDescription                 = 1272  // vendor 10415, Grouped
+	SDPTimeStamps                         = 1273  // vendor 10415, Grouped
+	SDPOfferTimestamp                     = 1274  // vendor 10415, Time
+	SDPAnswerTimestamp                    = 1275  // vendor 10415, Time
+	AFCorrelationInformation              = 1276  // vendor 10415, Grouped
+	PoCSessionInitiationType              = 1277  // vendor 10415, Enumerated
+	OfflineCharging                       = 1278  // vendor 10415, Grouped
+	UserParticipatingType                 = 1279  // vendor 10415, Enumerated
+	AlternateChargedPartyAddress          = 1280  // vendor 10415, UTF8String
+	IMSCommunicationServiceIdentifier     = 1281  // vendor 10415, UTF8String
+	NumberOfReceivedTalkBursts            = 1282  // vendor 10415, Unsigned32
+	NumberOfTalkBursts                    = 1283  // vendor 10415, Unsigned32
+	ReceivedTalkBurstTime                 = 1284  // vendor 10415, Unsigned32
+	ReceivedTalkBurstVolume               = 1285  // vendor 10415, Unsigned32
+	TalkBurstTime                         = 1286  // vendor 10415, Unsigned32
+	TalkBurstVolume                       = 1287  // vendor 10415, Unsigned32
+	MediaInitiatorParty                   = 1288  // vendor 10415, UTF8String
+	TerminalInformation                   = 1401  // vendor 10415, Grouped
+	CSGId                                 = 1437  // vendor 10415, Unsigned32
+	SSID                                  = 1524  // vendor 10415, UTF8String
+	MMENumberForMTSMS                     = 1645  // vendor 10415, OctetString
+	SMSInformation                        = 2000  // vendor 10415, Grouped
+	DataCodingScheme                      = 2001  // vendor 10415, Integer32
+	DestinationInterface                  = 2002  // vendor 10415, Grouped
+	InterfaceId                           = 2003  // vendor 10415, UTF8String
+	InterfacePort                         = 2004  // vendor 10415, UTF8String
+	InterfaceText                         = 2005  // vendor 10415, UTF8String
+	InterfaceType                         = 2006  // vendor 10415, Enumerated
+	SMMessageType                         = 2007  // vendor 10415, Enumerated
+	OriginatorSCCPAddress                 = 2008  // vendor 10415, Address
+	OriginatorInterface                   = 2009  // vendor 10415, Grouped
+	RecipientSCCPAddress                  = 2010  // vendor 10415, Address
+	ReplyPathRequested                    = 2011  // vendor 10415, Enumerated
+	SMDischargeTime                       = 2012  // vendor 10415, Time
+	SMProtocolId                          = 2013  // vendor 10415, OctetString
+	SMStatus                              = 2014  // vendor 10415, OctetString
+	SMUserDataHeader                      = 2015  // vendor 10415, OctetString
+	SMSNode                               = 2016  // vendor 10415, Enumerated
+	SMSCAddress                           = 2017  // vendor 10415, Address
+	ClientAddress                         = 2018  // vendor 10415, Address
+	NumberOfMessagesSent                  = 2019  // vendor 10415, Unsigned32
+	LowBalanceIndication                  = 2020  // vendor 10415, Enumerated
+	RemainingBalance                      = 2021  // vendor 10415, Grouped
+	RefundInformation                     = 2022  // vendor 10415, OctetString
+	CarrierSelectRoutingInformation       = 2023  // vendor 10415, UTF8String
+	NumberPortabilityRoutingInformation   = 2024  // vendor 10415, UTF8String
+	PoCEventType                          = 2025  // vendor 10415, Enumerated
+	RecipientInfo                         = 2026  // vendor 10415, Grouped
+	OriginatorReceivedAddress             = 2027  // vendor 10415, Grouped
+	RecipientReceivedAddress              = 2028  // vendor 10415, Grouped
+	SMServiceType                         = 2029  // vendor 10415, Enumerated
+	MMTelInformation                      = 2030  // vendor 10415, Grouped
+	MMTelSServiceType                     = 2031  // vendor 10415, Unsigned32
+	ServiceMode                           = 2032  // vendor 10415, Unsigned32
+	SubscriberRole                        = 2033  // vendor 10415, Enumerated
+	NumberOfDiversions                    = 2034  // vendor 10415, Unsigned32
+	AssociatedPartyAddress                = 2035  // vendor 10415, UTF8String
+	SDPType                               = 2036  // vendor 10415, Enumerated
+	ChangeCondition                       = 2037  // vendor 10415, Integer32
+	ChangeTime                            = 2038  // vendor 10415, Time
+	Diagnostics                           = 2039  // vendor 10415, Integer32
+	ServiceDataContainer                  = 2040  // vendor 10415, Grouped
+	StartTime                             = 2041  // vendor 10415, Time
+	StopTime                              = 2042  // vendor 10415, Time
+	TimeFirstUsage                        = 2043  // vendor 10415, Time
+	TimeLastUsage                         = 2044  // vendor 10415, Time
+	TimeUsage                             = 2045  // vendor 10415, Unsigned32
+	TrafficDataVolumes                    = 2046  // vendor 10415, Grouped
+	ServingNodeType                       = 2047  // vendor 10415, Enumerated
+	SupplementaryService                  = 2048  // vendor 10415, Grouped
+	ParticipantActionType                 = 2049  // vendor 10415, Enumerated
+	PDNConnectionChargingId               = 2050  // vendor 10415, Unsigned32
+	DynamicAddressFlag                    = 2051  // vendor 10415, Enumerated
+	AccumulatedCost                       = 2052  // vendor 10415, Grouped
+	AoCCostInformation                    = 2053  // vendor 10415, Grouped
+	AoCInformation                        = 2054  // vendor 10415, Grouped
+	AoCRequestType                        = 2055  // vendor 10415, Enumerated
+	CurrentTariff                         = 2056  // vendor 10415, Grouped
+	NextTariff                            = 2057  // vendor 10415, Grouped
+	RateElement                           = 2058  // vendor 10415, Grouped
+	ScaleFactor                           = 2059  // vendor 10415, Grouped
+	TariffInformation                     = 2060  // vendor 10415, Grouped
+	UnitCost                              = 2061  // vendor 10415, Grouped
+	IncrementalCost                       = 2062  // vendor 10415, Grouped
+	LocalSequenceNumber                   = 2063  // vendor 10415, Unsigned32
+	NodeId                                = 2064  // vendor 10415, UTF8String
+	SGWChange                             = 2065  // vendor 10415, Enumerated
+	ChargingCharacteristicsSelectionMode  = 2066  // vendor 10415, Enumerated
+	SGWAddress                            = 2067  // vendor 10415, Address
+	DynamicAddressFlagExtension           = 2068  // vendor 10415, Enumerated
+	ApplicationServerId                   = 2101  // vendor 10415, UTF8String
+	ApplicationSessionId                  = 2103  // vendor 10415, Unsigned32
+	DeliveryStatus                        = 2104  // vendor 10415, UTF8String
+	NumberOfMessagesSuccessfullyExploded  = 2111  // vendor 10415, Unsigned32
+	NumberOfMessagesSuccessfullySent      = 2112  // vendor 10415, Unsigned32
+	TotalNumberOfMessagesExploded         = 2113  // vendor 10415, Unsigned32
+	TotalNumberOfMessagesSent             = 2114  // vendor 10415, Unsigned32
+	ContentId                             = 2116  // vendor 10415, UTF8String
+	ContentProviderId                     = 2117  // vendor 10415, UTF8String
+	ChargeReasonCode                      = 2118  // vendor 10415, Enumerated
+	SIPRequestTimestampFraction           = 2301  // vendor 10415, Unsigned32
+	SIPResponseTimestampFraction          = 2302  // vendor 10415, Unsigned32
+	OnlineChargingFlag                    = 2303  // vendor 10415, Enumerated
+	CUGInformation                        = 2304  // vendor 10415, OctetString
+	RealTimeTariffInformation             = 2305  // vendor 10415, Grouped
+	TariffXML                             = 2306  // vendor 10415, UTF8String
+	MBMSGWAddress                         = 2307  // vendor 10415, Address
+	IMSIUnauthenticatedFlag               = 2308  // vendor 10415, Enumerated
+	AccountExpiration                     = 2309  // vendor 10415, Time
+	AoCFormat                             = 2310  // vendor 10415, Enumerated
+	AoCService                            = 2311  // vendor 10415, Grouped
+	AoCServiceObligatoryType              = 2312  // vendor 10415, Enumerated
+	AoCServiceType                        = 2313  // vendor 10415, Enumerated
+	AoCSubscriptionInformation            = 2314  // vendor 10415, Grouped
+	PreferredAoCCurrency                  = 2315  // vendor 10415, Unsigned32
+	CSGAccessMode                         = 2317  // vendor 10415, Enumerated
+	CSGMembershipIndication               = 2318  // vendor 10415, Enumerated
+	UserCSGInformation                    = 2319  // vendor 10415, Grouped
+	OutgoingSessionId                     = 2320  // vendor 10415, UTF8String
+	InitialIMSChargingIdentifier          = 2321  // vendor 10415, UTF8String
+	IMSEmergencyIndicator                 = 2322  // vendor 10415, Enumerated
+	MBMSChargedParty                      = 2323  // vendor 10415, Enumerated
+	ServingNode                           = 2401  // vendor 10415, Grouped
+	MMEName                               = 2402  // vendor 10415, DiameterIdentity
+	MMERealm                              = 2408  // vendor 10415, DiameterIdentity
+	IMSApplicationReferenceIdentifier     = 2601  // vendor 10415, UTF8String
+	LowPriorityIndicator                  = 2602  // vendor 10415, Enumerated
+	IPRealmDefaultIndication              = 2603  // vendor 10415, Enumerated
+	LocalGWInsertedIndication             = 2604  // vendor 10415, Enumerated
+	TranscoderInsertedIndication          = 2605  // vendor 10415, Enumerated
+	PDPAddressPrefixLength                = 2606  // vendor 10415, Unsigned32
+	TransitIOIList                        = 2701  // vendor 10415, UTF8String
+	StatusASCode                          = 2702  // vendor 10415, Enumerated
+	NNIInformation                        = 2703  // vendor 10415, Grouped
+	NNIType                               = 2704  // vendor 10415, Enumerated
+	NeighbourNodeAddress                  = 2705  // vendor 10415, Address
+	RelationshipMode                      = 2706  // vendor 10415, Enumerated
+	SessionDirection                      = 2707  // vendor 10415, Enumerated
+	FromAddress                           = 2708  // vendor 10415, UTF8String
+	AccessTransferInformation             = 2709  // vendor 10415, Grouped
+	AccessTransferType                    = 2710  // vendor 10415, Enumerated
+	RelatedIMSChargingIdentifier          = 2711  // vendor 10415, UTF8String
+	RelatedIMSChargingIdentifierNode      = 2712  // vendor 10415, Address
+	IMSVisitedNetworkIdentifier           = 2713  // vendor 10415, UTF8String
+	TWANUserLocationInfo                  = 2714  // vendor 10415, Grouped
+	BSSID                                 = 2716  // vendor 10415, UTF8String
+	TADIdentifier                         = 2717  // vendor 10415, Enumerated
+	UserLocationInfoTime                  = 2812  // vendor 10415, Time
+	PresenceReportingAreaIdentifier       = 2821  // vendor 10415, OctetString
+	PresenceReportingAreaInformation      = 2822  // vendor 10415, Grouped
+	PresenceReportingAreaStatus           = 2823  // vendor 10415, Unsigned32
+	FixedUserLocationInfo                 = 2825  // vendor 10415, Grouped
+	PriorityIndication                    = 3006  // vendor 10415, Enumerated
+	ReferenceNumber                       = 3007  // vendor 10415, Unsigned32
+	ApplicationPortIdentifer              = 3010  // vendor 10415, Unsigned32
+	ReasonHeader                          = 3401  // vendor 10415, UTF8String
+	InstanceId                            = 3402  // vendor 10415, UTF8String
+	RouteHeaderReceived                   = 3403  // vendor 10415, UTF8String
+	RouteHeaderTransmitted                = 3404  // vendor 10415, UTF8String
+	SMDeviceTriggerInformation            = 3405  // vendor 10415, Grouped
+	MTCIWFAddress                         = 3406  // vendor 10415, Address
+	SMDeviceTriggerIndicator              = 3407  // vendor 10415, Enumerated
+	SMSequenceNumber                      = 3408  // vendor 10415, Unsigned32
+	SMSResult                             = 3409  // vendor 10415, Unsigned32
+	VCSInformation                        = 3410  // vendor 10415, Grouped
+	BasicServiceCode                      = 3411  // vendor 10415, Grouped
+	BearerCapability                      = 3412  // vendor 10415, OctetString
+	Teleservice                           = 3413  // vendor 10415, OctetString
+	ISUPLocationNumber                    = 3414  // vendor 10415, OctetString
+	ForwardingPending                     = 3415  // vendor 10415, Enumerated
+	ISUPCause                             = 3416  // vendor 10415, Grouped
+	MSCAddress                            = 3417  // vendor 10415, OctetString
+	NetworkCallReferenceNumber            = 3418  // vendor 10415, OctetString
+	StartOfCharging                       = 3419  // vendor 10415, Time
+	VLRNumber                             = 3420  // vendor 10415, OctetString
+	CNOperatorSelectionEntity             = 3421  // vendor 10415, Enumerated
+	ISUPCauseDiagnostics                  = 3422  // vendor 10415, OctetString
+	ISUPCauseLocation                     = 3423  // vendor 10415, Unsigned32
+	ISUPCauseValue                        = 3424  // vendor 10415, Unsigned32
+	EPDGAddress                           = 3425  // vendor 10415, Address
+	CallingPartyAddress                   = 20336 // vendor 10415, UTF8String
+	AccessMethod                          = 20340 // vendor 10415, Unsigned32
+	AccountQueryMethod                    = 20346 // vendor 10415, Unsigned32
+	AccountChangeInfo                     = 20349 // vendor 10415, Grouped
+	CurrentAccountBalance                 = 20350 // vendor 10415, Integer64
+	MeasureType                           = 20353 // vendor 10415, Integer32
+	AccountId                             = 20357 // vendor 10415, OctetString
+	AccountEndDate                        = 20359 // vendor 10415, OctetString
+	AccountType                           = 20372 // vendor 10415, Unsigned32
+	SSPTime                               = 20386 // vendor 10415, Time
+	ActivePeriod                          = 20733 // vendor 10415, OctetString
+	GracePeriod                           = 20734 // vendor 10415, OctetString
+	DisablePeriod                         = 20735 // vendor 10415, OctetString
+	FirstActiveDate                       = 20771 // vendor 10415, OctetString
+	BalanceInformation                    = 21100 // vendor 10415, Grouped
+	LanguageIVR                           = 21194 // vendor 10415, Integer32
+	LanguageSMS                           = 21195 // vendor 10415, Integer32
+	AccountBeginDate                      = 22123 // vendor 10415, OctetString
+	ExternalOfferCode                     = 22144 // vendor 10415, UTF8String
+	ManagementStatus                      = 22149 // vendor 10415, UTF8String
+	OfferInfo                             = 22150 // vendor 10415, Grouped
+	OfferId                               = 22151 // vendor 10415, UTF8String
+	OfferOrderKey                         = 22152 // vendor 10415, UTF8String
+	EffectiveTime                         = 22153 // vendor 10415, UTF8String
+	ExpireTime                            = 22154 // vendor 10415, UTF8String
+	Status                                = 22155 // vendor 10415, UTF8String
+	CurrentCycle                          = 22158 // vendor 10415, Integer32
+	TotalCycle                            = 22159 // vendor 10415, Integer32
+	OfferOrderIntegrationKey              = 22160 // vendor 10415, UTF8String
+	AccountTypeDesc                       = 22320 // vendor 10415, OctetString
+	RelatedType                           = 22322 // vendor 10415, Unsigned32
+	RelatedObjectID                       = 22323 // vendor 10415, OctetString
+	OfferInformation                      = 23000 // vendor 10415, Grouped
+	SubscriberState                       = 30814 // vendor 10415, Unsigned32
+	Balance                               = 30841 // vendor 10415, Integer64
+	LanguageUSSD                          = 30939 // vendor 10415, Integer32
+	ShareFlag                             = 30941 // vendor 10415, Integer32
+)
+
+// TGPP-PDP-Type values.
+const (
+	TGPPPDPTypeIpv4   = 0
+	TGPPPDPTypePpp    = 1
+	TGPPPDPTypeIpv6   = 2
+	TGPPPDPTypeIpv4v6 = 3
+)
+
+// Session-Priority values.
+const (
+	SessionPriorityPriority0 = 0
+	SessionPriorityPriority1 = 1
+	SessionPriorityPriority2 = 2
+	SessionPriorityPriority3 = 3
+	SessionPriorityPriority4 = 4
+)
+
+// Role-Of-Node values.
+const (
+	RoleOfNodeOriginatingRole = 0
+	RoleOfNodeTerminatingRole = 1
+	RoleOfNodeForwardingRole  = 2
+)
+
+// Node-Functionality values.
+const (
+	NodeFunctionalitySCscf         = 0
+	NodeFunctionalityPCscf         = 1
+	NodeFunctionalityICscf         = 2
+	NodeFunctionalityMrfc          = 3
+	NodeFunctionalityMgcf          = 4
+	NodeFunctionalityBgcf          = 5
+	NodeFunctionalityAs            = 6
+	NodeFunctionalityIbcf          = 7
+	NodeFunctionalitySGw           = 8
+	NodeFunctionalityPGw           = 9
+	NodeFunctionalityHsgw          = 10
+	NodeFunctionalityECscf         = 11
+	NodeFunctionalityMme           = 12
+	NodeFunctionalityTrf           = 13
+	NodeFunctionalityTf            = 14
+	NodeFunctionalityAtcf          = 15
+	NodeFunctionalityProxyFunction = 16
+	NodeFunctionalityEPDG          = 17
+)
+
+// Originator values.
+const (
+	OriginatorCallingParty = 0
+	OriginatorCalledParty  = 1
+)
+
+// PS-Append-Free-Format-Data values.
+const (
+	PSAppendFreeFormatDataAppend    = 0
+	PSAppendFreeFormatDataOverwrite = 1
+)
+
+// Trigger-Type values.
+const (
+	TriggerTypeChangeInSgsnIpAddress                        = 1
+	TriggerTypeChangeInQos                                  = 2
+	TriggerTypeChangeInLocation                             = 3
+	TriggerTypeChangeInRat                                  = 4
+	TriggerTypeChangeInUeTimezone                           = 5
+	TriggerTypeChangeinqosTrafficClass                      = 10
+	TriggerTypeChangeinqosReliabilityClass                  = 11
+	TriggerTypeChangeinqosDelayClass                        = 12
+	TriggerTypeChangeinqosPeakThroughput                    = 13
+	TriggerTypeChangeinqosPrecedenceClass                   = 14
+	TriggerTypeChangeinqosMeanThroughput                    = 15
+	TriggerTypeChangeinqosMaximumBitRateForUplink           = 16
+	TriggerTypeChangeinqosMaximumBitRateForDownlink         = 17
+	TriggerTypeChangeinqosResidualBer                       = 18
+	TriggerTypeChangeinqosSduErrorRatio                     = 19
+	TriggerTypeChangeinqosTransferDelay                     = 20
+	TriggerTypeChangeinqosTrafficHandlingPriority           = 21
+	TriggerTypeChangeinqosGuaranteedBitRateForUplink        = 22
+	TriggerTypeChangeinqosGuaranteedBitRateForDownlink      = 23
+	TriggerTypeChangeinqosApnAggregateMaximumBitRate        = 24
+	TriggerTypeChangeinlocationMcc                          = 30
+	TriggerTypeChangeinlocationMnc                          = 31
+	TriggerTypeChangeinlocationRac                          = 32
+	TriggerTypeChangeinlocationLac                          = 33
+	TriggerTypeChangeinlocationCellId                       = 34
+	TriggerTypeChangeinlocationTac                          = 35
+	TriggerTypeChangeinlocationEcgi                         = 36
+	TriggerTypeChangeInMediaComposition                     = 40
+	TriggerTypeChangeInParticipantsNmb                      = 50
+	TriggerTypeChangeInThrshldOfParticipantsNmb             = 51
+	TriggerTypeChangeInUserParticipatingType                = 52
+	TriggerTypeChangeInServiceCondition                     = 60
+	TriggerTypeChangeInServingNode                          = 61
+	TriggerTypeChangeInUserCsgInformation                   = 70
+	TriggerTypeChangeInHybridSubscribedUserCsgInformation   = 71
+	TriggerTypeChangeInHybridUnsubscribedUserCsgInformation = 72
+	TriggerTypeChangeOfUePresenceInPresenceReportingArea    = 73
+)
+
+// Reporting-Reason values.
+const (
+	ReportingReasonThreshold             = 0
+	ReportingReasonQht                   = 1
+	ReportingReasonFinal                 = 2
+	ReportingReasonQuotaExhausted        = 3
+	ReportingReasonValidityTime          = 4
+	ReportingReasonOtherQuotaType        = 5
+	ReportingReasonRatingConditionChange = 6
+	ReportingReasonForcedReauthorisation = 7
+	ReportingReasonPoolExhausted         = 8
+)
+
+// Media-Initiator-Flag values.
+const (
+	MediaInitiatorFlagCalledParty  = 0
+	MediaInitiatorFlagCallingParty = 1
+	MediaInitiatorFlagUnknown      = 2
+)
+
+// PoC-Server-Role values.
+const (
+	PoCServerRoleParticipatingPoCServer = 0
+	PoCServerRoleControllingPoCServer   = 1
+)
+
+// PoC-Session-Type values.
+const (
+	PoCSessionTypeAVP1To1PoCSession          = 0
+	PoCSessionTypeChatPoCGroupSession        = 1
+	PoCSessionTypePreArrangedPoCGroupSession = 2
+	PoCSessionTypeAdHocPoCGroupSession       = 3
+)
+
+// Address-Type values.
+const (
+	AddressTypeEMailAddress          = 0
+	AddressTypeMsisdn                = 1
+	AddressTypeIPv4Address           = 2
+	AddressTypeIPv6Address           = 3
+	AddressTypeNumericShortcode      = 4
+	AddressTypeAlphanumericShortcode = 5
+	AddressTypeOther                 = 6
+	AddressTypeImsi                  = 7
+)
+
+// MBMS-Service-Type values.
+const (
+	MBMSServiceTypeMulticast = 0
+	MBMSServiceTypeBroadcast = 1
+)
+
+// MBMS-2G-3G-Indicator values.
+const (
+	MBMS2G3GIndicatorAVP2g      = 0
+	MBMS2G3GIndicatorAVP3g      = 1
+	MBMS2G3GIndicatorAVP2gAnd3g = 2
+)
+
+// CN-IP-Multicast-Distribution values.
+const (
+	CNIPMulticastDistributionNoIpMulticast = 0
+	CNIPMulticastDistributionIpMulticast   = 1
+)
+
+// QoS-Class-Identifier values.
+const (
+	QoSClassIdentifierQci1  = 1
+	QoSClassIdentifierQci2  = 2
+	QoSClassIdentifierQci3  = 3
+	QoSClassIdentifierQci4  = 4
+	QoSClassIdentifierQci5  = 5
+	QoSClassIdentifierQci6  = 6
+	QoSClassIdentifierQci7  = 7
+	QoSClassIdentifierQci8  = 8
+	QoSClassIdentifierQci9  = 9
+	QoSClassIdentifierQci65 = 65
+	QoSClassIdentifierQci66 = 66
+	QoSClassIdentifierQci69 = 69
+	QoSClassIdentifierQci70 = 70
+)
+
+// RAT-Type values.
+const (
+	RATTypeWlan          = 0
+	RATTypeVirtual       = 1
+	RATTypeUtran         = 1000
+	RATTypeGeran         = 1001
+	RATTypeGan           = 1002
+	RATTypeHspaEvolution = 1003
+	RATTypeEutran        = 1004
+	RATTypeCdma20001x    = 2000
+	RATTypeHrpd          = 2001
+	RATTypeUmb           = 2002
+	RATTypeEhrpd         = 2003
+)
+
+// Addressee-Type values.
+const (
+	AddresseeTypeTo  = 0
+	AddresseeTypeCc  = 1
+	AddresseeTypeBcc = 2
+)
+
+// Priority values.
+const (
+	PriorityLow    = 0
+	PriorityNormal = 1
+	PriorityHigh   = 2
+)
+
+// Message-Type values.
+const (
+	MessageTypeMSendReq         = 1
+	MessageTypeMSendConf        = 2
+	MessageTypeMNotificationInd = 3
+	MessageTypeMNotifyrespInd   = 4
+	MessageTypeMRetrieveConf    = 5
+	MessageTypeMAcknowledgeInd  = 6
+	MessageTypeMDeliveryInd     = 7
+	MessageTypeMReadRecInd      = 8
+	MessageTypeMReadOrigInd     = 9
+	MessageTypeMForwardReq      = 10
+	MessageTypeMForwardConf     = 11
+	MessageTypeMMboxStoreConf   = 12
+	MessageTypeMMboxViewConf    = 13
+	MessageTypeMMboxUploadConf  = 14
+	MessageTypeMMboxDeleteConf  = 15
+)
+
+// Class-Identifier values.
+const (
+	ClassIdentifierPersonal      = 0
+	ClassIdentifierAdvertisement = 1
+	ClassIdentifierInformational = 2
+	ClassIdentifierAuto          = 3
+)
+
+// Delivery-Report-Requested values.
+const (
+	DeliveryReportRequestedNo  = 0
+	DeliveryReportRequestedYes = 1
+)
+
+// Adaptations values.
+const (
+	AdaptationsYes = 0
+	AdaptationsNo  = 1
+)
+
+// Content-Class values.
+const (
+	ContentClassText         = 0
+	ContentClassImageBasic   = 1
+	ContentClassImageRich    = 2
+	ContentClassVideoBasic   = 3
+	ContentClassVideoRich    = 4
+	ContentClassMegapixel    = 5
+	ContentClassContentBasic = 6
+	ContentClassContentRich  = 7
+)
+
+// DRM-Content values.
+const (
+	DRMContentNo  = 0
+	DRMContentYes = 1
+)
+
+// Read-Reply-Report-Requested values.
+const (
+	ReadReplyReportRequestedNo  = 0
+	ReadReplyReportRequestedYes = 1
+)
+
+// File-Repair-Supported values.
+const (
+	FileRepairSupportedSupported    = 0
+	FileRepairSupportedNotSupported = 1
+)
+
+// MBMS-User-Service-Type values.
+const (
+	MBMSUserServiceTypeDownload  = 1
+	MBMSUserServiceTypeStreaming = 2
+)
+
+// LCS-Format-Indicator values.
+const (
+	LCSFormatIndicatorLogicalName  = 0
+	LCSFormatIndicatorEmailAddress = 1
+	LCSFormatIndicatorMsisdn       = 2
+	LCSFormatIndicatorUrl          = 3
+	LCSFormatIndicatorSipUrl       = 4
+)
+
+// LCS-Client-Type values.
+const (
+	LCSClientTypeEmergencyServices       = 0
+	LCSClientTypeValueAddedServices      = 1
+	LCSClientTypePlmnOperatorServices    = 2
+	LCSClientTypeLawfulInterceptServices = 3
+)
+
+// Location-Estimate-Type values.
+const (
+	LocationEstimateTypeCurrentLocation          = 0
+	LocationEstimateTypeCurrentLastKnownLocation = 1
+	LocationEstimateTypeInitialLocation          = 2
+	LocationEstimateTypeActivateDeferredLocation = 3
+	LocationEstimateTypeCancelDeferredLocation   = 4
+)
+
+// PDP-Context-Type values.
+const (
+	PDPContextTypePrimary   = 0
+	PDPContextTypeSecondary = 1
+)
+
+// MMBox-Storage-Requested values.
+const (
+	MMBoxStorageRequestedNo  = 0
+	MMBoxStorageRequestedYes = 1
+)
+
+// PoC-User-Role-info-Units values.
+const (
+	PoCUserRoleInfoUnitsModerator          = 1
+	PoCUserRoleInfoUnitsDispatcher         = 2
+	PoCUserRoleInfoUnitsSessionOwner       = 3
+	PoCUserRoleInfoUnitsSessionParticipant = 4
+)
+
+// Participant-Access-Priority values.
+const (
+	ParticipantAccessPriorityPreEmptivePriority = 1
+	ParticipantAccessPriorityHighPriority       = 2
+	ParticipantAccessPriorityNormalPriority     = 3
+	ParticipantAccessPriorityLowPriority        = 4
+)
+
+// PoC-Change-Condition values.
+const (
+	PoCChangeConditionServiceChange              = 0
+	PoCChangeConditionVolumeLimit                = 1
+	PoCChangeConditionTimeLimit                  = 2
+	PoCChangeConditionNumberofTalkBurstLimit     = 3
+	PoCChangeConditionNumberofActiveParticipants = 4
+	PoCChangeConditionTariffTime                 = 5
+)
+
+// Envelope-Reporting values.
+const (
+	EnvelopeReportingDoNotReportEnvelopes               = 0
+	EnvelopeReportingReportEnvelopes                    = 1
+	EnvelopeReportingReportEnvelopesWithVolume          = 2
+	EnvelopeReportingReportEnvelopesWithEvents          = 3
+	EnvelopeReportingReportEnvelopesWithVolumeAndEvents = 4
+)
+
+// Time-Quota-Type values.
+const (
+	TimeQuotaTypeDiscreteTimePeriod   = 0
+	TimeQuotaTypeContinuousTimePeriod = 1
+)
+
+// PoC-Session-Initiation-type values.
+const (
+	PoCSessionInitiationTypePreEstablished = 0
+	PoCSessionInitiationTypeOnDemand       = 1
+)
+
+// User-Participating-Type values.
+const (
+	UserParticipatingTypeNormal   = 0
+	UserParticipatingTypeNwPoCBox = 1
+	UserParticipatingTypeUePoCBox = 2
+)
+
+// Interface-Type values.
+const (
+	InterfaceTypeUnknown                = 0
+	InterfaceTypeMobileOriginating      = 1
+	InterfaceTypeMobileTerminating      = 2
+	InterfaceTypeApplicationOriginating = 3
+	InterfaceTypeApplicationTermination = 4
+)
+
+// SM-Message-Type values.
+const (
+	SMMessageTypeSubmission       = 0
+	SMMessageTypeDeliveryReport   = 1
+	SMMessageTypeSmServiceRequest = 2
+)
+
+// Reply-Path-Requested values.
+const (
+	ReplyPathRequestedNoReplyPathSet = 0
+	ReplyPathRequestedReplyPathSet   = 1
+)
+
+// SMS-Node values.
+const (
+	SMSNodeSmsRouter          = 0
+	SMSNodeIpSmGw             = 1
+	SMSNodeSmsRouterAndIpSmGw = 2
+	SMSNodeSmsSc              = 3
+)
+
+// Low-Balance-Indication values.
+const (
+	LowBalanceIndicationNotApplicable = 0
+	LowBalanceIndicationYes           = 1
+)
+
+// PoC-Event-Type values.
+const (
+	PoCEventTypeNormal                      = 0
+	PoCEventTypeInstantPpersonalAalertEvent = 1
+	PoCEventTypePoCGroupAdvertisementEvent  = 2
+	PoCEventTypeEarlySsessionSettingUpEvent = 3
+	PoCEventTypePoCTalkBurst                = 4
+)
+
+// SM-Service-Type values.
+const (
+	SMServiceTypeVas4smsShortMessageContentProcessing               = 0
+	SMServiceTypeVas4smsShortMessageForwarding                      = 1
+	SMServiceTypeVas4smsShortMessageForwardingMultipleSubscriptions = 2
+	SMServiceTypeVas4smsShortMessageFiltering                       = 3
+	SMServiceTypeVas4smsShortMessageReceipt                         = 4
+	SMServiceTypeVas4smsShortMessageNetworkStorage                  = 5
+	SMServiceTypeVas4smsShortMessageToMultipleDestinations          = 6
+	SMServiceTypeVas4smsShortMessageVirtualPrivateNetworkVpn        = 7
+	SMServiceTypeVas4smsShortMessageAutoReply                       = 8
+	SMServiceTypeVas4smsShortMessagePersonalSignature               = 9
+	SMServiceTypeVas4smsShortMessageDeferredDelivery                = 10
+)
+
+// Subscriber-Role values.
+const (
+	SubscriberRoleOriginating = 0
+	SubscriberRoleTerminating = 1
+)
+
+// SDP-Type values.
+const (
+	SDPTypeSdpOffer  = 0
+	SDPTypeSdpAnswer = 1
+)
+
+// Serving-Node-Type values.
+const (
+	ServingNodeTypeSgsn    = 0
+	ServingNodeTypePmipsgw = 1
+	ServingNodeTypeGtpsgw  = 2
+	ServingNodeTypeEPDG    = 3
+	ServingNodeTypeHSGW    = 4
+	ServingNodeTypeMme     = 5
+	ServingNodeTypeTwan    = 6
+)
+
+// Participant-Action-Type values.
+const (
+	ParticipantActionTypeCreateConf     = 0
+	ParticipantActionTypeJoinConf       = 1
+	ParticipantActionTypeInviteIntoConf = 2
+	ParticipantActionTypeQuitConf       = 3
+)
+
+// Dynamic-Address-Flag values.
+const (
+	DynamicAddressFlagStatic  = 0
+	DynamicAddressFlagDynamic = 1
+)
+
+// AoC-Request-Type values.
+const (
+	AoCRequestTypeAoCNotRequested = 0
+	AoCRequestTypeAoCFull         = 1
+	AoCRequestTypeAoCCostOnly     = 2
+	AoCRequestTypeAoCTariffOnly   = 3
+)
+
+// SGW-Change values.
+const (
+	SGWChangeAcrStartNotDueToSgwChange = 0
+	SGWChangeAcrStartDueToSgwChange    = 1
+)
+
+// Charging-Characteristics-Selection-Mode values.
+const (
+	ChargingCharacteristicsSelectionModeServingNodeSupplied  = 0
+	ChargingCharacteristicsSelectionModeSubscriptionSpecific = 1
+	ChargingCharacteristicsSelectionModeApnSpecific          = 2
+	ChargingCharacteristicsSelectionModeHomeDefault          = 3
+	ChargingCharacteristicsSelectionModeRoamingDefault       = 4
+	ChargingCharacteristicsSelectionModeVisitingDefault      = 5
+)
+
+// Dynamic-Address-Flag-Extension values.
+const (
+	DynamicAddressFlagExtensionStatic  = 0
+	DynamicAddressFlagExtensionDynamic = 1
+)
+
+// Charge-Reason-Code values.
+const (
+	ChargeReasonCodeUnknown                    = 0
+	ChargeReasonCodeUsage                      = 1
+	ChargeReasonCodeCommunicationAttemptCharge = 2
+	ChargeReasonCodeSetupCharge                = 3
+	ChargeReasonCodeAddOnCharge                = 4
+)
+
+// Online-Charging-Flag values.
+const (
+	OnlineChargingFlagEcfAddressNotProvided = 0
+	OnlineChargingFlagEcfAddressProvided    = 1
+)
+
+// IMSI-Unauthenticated-Flag values.
+const (
+	IMSIUnauthenticatedFlagAuthenticated   = 0
+	IMSIUnauthenticatedFlagUnauthenticated = 1
+)
+
+// AoC-Format values.
+const (
+	AoCFormatMonetary    = 0
+	AoCFormatNonMonetary = 1
+	AoCFormatCai         = 2
+)
+
+// AoC-Service-Obligatory-Type values.
+const (
+	AoCServiceObligatoryTypeNonBinding = 0
+	AoCServiceObligatoryTypeBinding    = 1
+)
+
+// AoC-Service-Type values.
+const (
+	AoCServiceTypeNone = 0
+	AoCServiceTypeAocS = 1
+	AoCServiceTypeAocD = 2
+	AoCServiceTypeAocE = 3
+)
+
+// CSG-Access-Mode values.
+const (
+	CSGAccessModeClosedMode = 0
+	CSGAccessModeHybridMode = 1
+)
+
+// CSG-Membership-Indication values.
+const (
+	CSGMembershipIndicationNotCsgMember = 0
+	CSGMembershipIndicationCsgMember    = 1
+)
+
+// IMS-Emergency-Indicator values.
+const (
+	IMSEmergencyIndicatorNonEmergency = 0
+	IMSEmergencyIndicatorEmergency    = 1
+)
+
+// MBMS-Charged-Party values.
+const (
+	MBMSChargedPartyContentProvider = 0
+	MBMSChargedPartySubscriber      = 1
+)
+
+// Low-Priority-Indicator values.
+const (
+	LowPriorityIndicatorNo  = 0
+	LowPriorityIndicatorYes = 1
+)
+
+// IP-Realm-Default-Indication values.
+const (
+	IPRealmDefaultIndicationDefaultIpRealmNotUsed = 0
+	IPRealmDefaultIndicationDefaultIpRealmUsed    = 1
+)
+
+// Local-GW-Inserted-Indication values.
+const (
+	LocalGWInsertedIndicationLocalGwNotInserted = 0
+	LocalGWInsertedIndicationLocalGwInserted    = 1
+)
+
+// Transcoder-Inserted-Indication values.
+const (
+	TranscoderInsertedIndicationTranscoderNotInserted = 0
+	TranscoderInsertedIndicationTranscoderInserted    = 1
+)
+
+// Status-AS-Code values.
+const (
+	StatusASCodeAVP4xx  = 0
+	StatusASCodeAVP5xx  = 1
+	StatusASCodeTimeout = 2
+)
+
+// NNI-Type values.
+const (
+	NNITypeNonRoaming             = 0
+	NNITypeRoamingWithoutLoopback = 1
+	NNITypeRoamingWithLoopback    = 2
+)
+
+// Relationship-Mode values.
+const (
+	RelationshipModeTrusted    = 0
+	RelationshipModeNonTrusted = 1
+)
+
+// Session-Direction values.
+const (
+	SessionDirectionInbound  = 0
+	SessionDirectionOutbound = 1
+)
+
+// Access-Transfer-Type values.
+const (
+	AccessTransferTypePsToCsTransfer = 0
+	AccessTransferTypeCsToPsTransfer = 1
+)
+
+// TAD-Identifier values.
+const (
+	TADIdentifierCs = 0
+	TADIdentifierPs = 1
+)
+
+// Priority-Indication values.
+const (
+	PriorityIndicationNonPriority = 0
+	PriorityIndicationPriority    = 1
+)
+
+// SM-Device-Trigger-Indicator values.
+const (
+	SMDeviceTriggerIndicatorNotDeviceTrigger = 0
+	SMDeviceTriggerIndicatorDeviceTrigger    = 1
+)
+
+// Forwarding-Pending values.
+const (
+	ForwardingPendingForwardingNotPending = 0
+	ForwardingPendingForwardingPending    = 1
+)
+
+// CN-Operator-Selection-Entity values.
+const (
+	CNOperatorSelectionEntityTheServingNetworkHasBeenSelectedByTheUe      = 0
+	CNOperatorSelectionEntityTheServingNetworkHasBeenSelectedByTheNetwork = 1
+)
+
+// BalanceInformationGroup is the grouped AVP Balance-Information (21100).
+type BalanceInformationGroup struct {
+	FirstActiveDate   string                   `avp:"First-Active-Date"`
+	SubscriberState   uint32                   `avp:"Subscriber-State"`
+	ActivePeriod      string                   `avp:"Active-Period"`
+	GracePeriod       string                   `avp:"Grace-Period"`
+	DisablePeriod     string                   `avp:"Disable-Period"`
+	Balance           int64                    `avp:"Balance"`
+	LanguageIVR       int32                    `avp:"Language-IVR"`
+	LanguageSMS       int32                    `avp:"Language-SMS"`
+	LanguageUSSD      int32                    `avp:"Language-USSD"`
+	AccountChangeInfo []AccountChangeInfoGroup `avp:"Account-Change-Info"`
+	OfferInformation  []OfferInformationGroup  `avp:"Offer-Information"`
+}
+
+// AccountChangeInfoGroup is the grouped AVP Account-Change-Info (20349).
+type AccountChangeInfoGroup struct {
+	AccountId             string `avp:"Account-Id"`
+	AccountType           uint32 `avp:"Account-Type"`
+	AccountTypeDesc       string `avp:"Account-Type-Desc"`
+	AccountBeginDate      string `avp:"Account-Begin-Date"`
+	RelatedType           uint32 `avp:"Related-Type"`
+	RelatedObjectID       string `avp:"Related-Object-ID"`
+	CurrentAccountBalance int64  `avp:"Current-Account-Balance"`
+	AccountEndDate        string `avp:"Account-End-Date"`
+	MeasureType           int32  `avp:"Measure-Type"`
+	ShareFlag             int32  `avp:"Share-Flag"`
+}
+
+// OfferInformationGroup is the grouped AVP Offer-Information (23000).
+type OfferInformationGroup struct {
+	OfferInfo []OfferInfoGroup `avp:"Offer-Info"`
+}
+
+// OfferInfoGroup is the grouped AVP Offer-Info (22150).
+type OfferInfoGroup struct {
+	OfferId                  string `avp:"Offer-Id"`
+	OfferOrderKey            string `avp:"Offer-Order-Key"`
+	EffectiveTime            string `avp:"Effective-Time"`
+	ExpireTime               string `avp:"Expire-Time"`
+	Status                   string `avp:"Status"`
+	CurrentCycle             int32  `avp:"Current-Cycle"`
+	TotalCycle               int32  `avp:"Total-Cycle"`
+	OfferOrderIntegrationKey string `avp:"Offer-Order-Integration-Key"`
+	ExternalOfferCode        string `avp:"External-Offer-Code"`
+}
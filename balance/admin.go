@@ -0,0 +1,96 @@
+package balance
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ant0ine/go-json-rest/rest"
+
+	"server/diameter"
+)
+
+// adminEnv marks a request authenticated with the admin token.
+const adminEnv = "ADMIN"
+
+// captureDir is the configured capture directory, the only one
+// PutCapture writes to.
+var captureDir = os.TempDir()
+
+// setCaptureDir records dir, or the temporary directory when it is
+// empty, as the capture directory.
+func setCaptureDir(dir string) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	captureDir = filepath.Clean(dir)
+}
+
+// AdminMiddleware requires the admin token on every /admin/ request.
+// The /admin/ endpoints are refused while the token is empty.
+type AdminMiddleware struct {
+	mu    sync.RWMutex
+	token string
+}
+
+// SetToken replaces the admin token for new requests.
+func (mw *AdminMiddleware) SetToken(token string) {
+	mw.mu.Lock()
+	mw.token = token
+	mw.mu.Unlock()
+}
+
+func (mw *AdminMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, req *rest.Request) {
+		if !strings.HasPrefix(req.URL.Path, "/admin/") {
+			h(w, req)
+			return
+		}
+		mw.mu.RLock()
+		want := mw.token
+		mw.mu.RUnlock()
+		if want == "" {
+			rest.Error(w, "admin endpoints need an admin token", http.StatusForbidden)
+			return
+		}
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(want)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			rest.Error(w, "admin token required", http.StatusUnauthorized)
+			return
+		}
+		req.Env[adminEnv] = true
+		h(w, req)
+	}
+}
+
+// GetCapture returns the current capture settings.
+func GetCapture(w rest.ResponseWriter, req *rest.Request) {
+	w.WriteJson(diameter.Capturing())
+}
+
+// PutCapture switches the Diameter capture at runtime. Dir may be
+// left out, but not point anywhere else than the configured directory,
+// since rotating deletes old capture files there.
+func PutCapture(w rest.ResponseWriter, req *rest.Request) {
+	var cfg diameter.CaptureConfig
+	if err := req.DecodeJsonPayload(&cfg); err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = captureDir
+	}
+	if filepath.Clean(cfg.Dir) != captureDir {
+		rest.Error(w, "dir must be the configured capture directory", http.StatusBadRequest)
+		return
+	}
+	if err := diameter.SetCapture(cfg); err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteJson(diameter.Capturing())
+}
@@ -0,0 +1,87 @@
+package balance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+// serveAdmin sends req through AdminMiddleware with token to the
+// capture endpoints.
+func serveAdmin(t *testing.T, token string, req *http.Request) *httptest.ResponseRecorder {
+	mw := &AdminMiddleware{}
+	mw.SetToken(token)
+	return serveAdminWith(t, mw, req)
+}
+
+// serveAdminWith sends req through mw to the capture endpoints.
+func serveAdminWith(t *testing.T, mw *AdminMiddleware, req *http.Request) *httptest.ResponseRecorder {
+	api := rest.NewApi()
+	api.Use(mw)
+	router, err := rest.MakeRouter(
+		&rest.Route{HttpMethod: "GET", PathExp: "/admin/capture", Func: GetCapture},
+		&rest.Route{HttpMethod: "PUT", PathExp: "/admin/capture", Func: PutCapture},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.SetApp(router)
+	w := httptest.NewRecorder()
+	api.MakeHandler().ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminRefusedWithoutToken(t *testing.T) {
+	r, _ := http.NewRequest("GET", "http://localhost/admin/capture", nil)
+	if w := serveAdmin(t, "", r); w.Code != http.StatusForbidden {
+		t.Error("It should be 403 but was ", w.Code)
+	}
+}
+
+func TestAdminBadToken(t *testing.T) {
+	r, _ := http.NewRequest("GET", "http://localhost/admin/capture", nil)
+	r.Header.Set("Authorization", "Bearer wrong")
+	w := serveAdmin(t, "secret", r)
+	if w.Code != http.StatusUnauthorized {
+		t.Error("It should be 401 but was ", w.Code)
+	}
+	if v := w.Header().Get("WWW-Authenticate"); v != "Bearer" {
+		t.Error("It should ask for a bearer token but was ", v)
+	}
+}
+
+func TestAdminSetToken(t *testing.T) {
+	mw := &AdminMiddleware{}
+	mw.SetToken("old")
+	mw.SetToken("new")
+	for token, want := range map[string]int{"old": http.StatusUnauthorized, "new": http.StatusOK} {
+		r, _ := http.NewRequest("GET", "http://localhost/admin/capture", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		if w := serveAdminWith(t, mw, r); w.Code != want {
+			t.Error("It should be ", want, " for the "+token+" token but was ", w.Code)
+		}
+	}
+}
+
+func TestPutCaptureDir(t *testing.T) {
+	setCaptureDir("")
+	defer setCaptureDir("")
+
+	for body, want := range map[string]int{
+		`{"mode":"off"}`: http.StatusOK,
+		`{"mode":"off","dir":"` + os.TempDir() + `"}`:        http.StatusOK,
+		`{"mode":"off","dir":"/etc"}`:                        http.StatusBadRequest,
+		`{"mode":"off","dir":"` + os.TempDir() + `/../etc"}`: http.StatusBadRequest,
+	} {
+		r, _ := http.NewRequest("PUT", "http://localhost/admin/capture", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Authorization", "Bearer secret")
+		if w := serveAdmin(t, "secret", r); w.Code != want {
+			t.Error("It should be ", want, " for "+body+" but was ", w.Code)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package balance
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/ant0ine/go-json-rest/rest"
+
+	"server/logger"
+)
+
+// maxBatch is the largest number of subscribers accepted by one batch
+// request.
+const maxBatch = 500
+
+type BatchRequest struct {
+	Subscribers []string `json:"subscribers"`
+}
+
+// BatchResult is the balance of a subscriber, or the error of its
+// query with the HTTP status a single query would have been answered
+// with and, when the OCS refused it, the Result-Code.
+type BatchResult struct {
+	Subscriber string       `json:"subscriber"`
+	Balance    *BalanceInfo `json:"balance,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	Status     int          `json:"status,omitempty"`
+	ResultCode uint32       `json:"resultCode,omitempty"`
+}
+
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// Batch queries the balance of every subscriber in the request body.
+// A failed subscriber is reported in its own result and does not fail
+// the batch.
+func Batch(w rest.ResponseWriter, req *rest.Request) {
+	corp := req.PathParam("corp")
+
+	var body BatchRequest
+	if err := req.DecodeJsonPayload(&body); err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(body.Subscribers) == 0 {
+		rest.Error(w, "subscribers is required", http.StatusBadRequest)
+		return
+	}
+	if len(body.Subscribers) > maxBatch {
+		rest.Error(w, "too many subscribers", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	w.WriteJson(queryAll(logger.RequestID(req), corp, body.Subscribers, noCache(req.Request)))
+}
+
+// queryAll queries subrs with at most maxOutstanding queries in
+// flight. Every query also waits for a batch slot of the peer, so that
+// concurrent batches together do not run into the per-peer cap.
+func queryAll(reqID, corp string, subrs []string, noCache bool) BatchResponse {
+	resp := BatchResponse{Results: make([]BatchResult, len(subrs))}
+
+	stateLock.RLock()
+	workers := maxOutstanding
+	stateLock.RUnlock()
+	if workers > len(subrs) {
+		workers = len(subrs)
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for n := 0; n < workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				r := &resp.Results[i]
+				r.Subscriber = subrs[i]
+				b, err := batchGet(reqID, corp, subrs[i], noCache)
+				if err != nil {
+					r.Error = err.Error()
+					r.Status = errorStatus(err)
+					if re, ok := err.(ResultError); ok {
+						r.ResultCode = re.Code
+					}
+					continue
+				}
+				r.Balance = &b
+			}
+		}()
+	}
+	for i := range subrs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	return resp
+}
+
+// batchGet gets the balance of subr holding a batch slot of the peer
+// of corp.
+func batchGet(reqID, corp, subr string, noCache bool) (BalanceInfo, error) {
+	if p := peerFor(corp); p != nil {
+		p.batch <- struct{}{}
+		defer func() { <-p.batch }()
+	}
+	b, _, err := balances.get(reqID, corp, subr, noCache)
+	return b, err
+}
@@ -0,0 +1,100 @@
+package balance
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQueryAllBoundsConcurrency(t *testing.T) {
+	saved, savedMax := balances, maxOutstanding
+	defer func() { balances, maxOutstanding = saved, savedMax }()
+	maxOutstanding = 4
+
+	var running, peak int32
+	balances = newCache(cacheConfig(0, 0, 0), func(reqID, corp, subr string) (BalanceInfo, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return BalanceInfo{SessionId: subr}, nil
+	})
+
+	subrs := make([]string, 50)
+	for i := range subrs {
+		subrs[i] = fmt.Sprintf("66812%06d", i)
+	}
+	resp := queryAll("", "dtac", subrs, false)
+	if peak > 4 {
+		t.Error("It should run at most 4 queries at once but ran ", peak)
+	}
+	for i, r := range resp.Results {
+		if r.Subscriber != subrs[i] || r.Balance == nil || r.Balance.SessionId != subrs[i] {
+			t.Error("It should answer "+subrs[i]+" in order but got ", r)
+		}
+	}
+}
+
+func TestQueryAllSharesPeerSlots(t *testing.T) {
+	defer saveState()()
+	saved, savedMax := balances, maxOutstanding
+	defer func() { balances, maxOutstanding = saved, savedMax }()
+	maxOutstanding = 4
+	stateLock.Lock()
+	corps = map[string][]*peer{"dtac": {newPeer("127.0.0.1:3868")}}
+	stateLock.Unlock()
+
+	var running, peak int32
+	balances = newCache(cacheConfig(0, 0, 0), func(reqID, corp, subr string) (BalanceInfo, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		if subr == "66811111111" {
+			return BalanceInfo{}, ResultError{UserUnknown}
+		}
+		return BalanceInfo{SessionId: subr}, nil
+	})
+
+	done := make(chan BatchResponse)
+	for n := 0; n < 2; n++ {
+		subrs := make([]string, 20)
+		for i := range subrs {
+			subrs[i] = fmt.Sprintf("6681%d%06d", n, i)
+		}
+		go func() { done <- queryAll("", "dtac", subrs, false) }()
+	}
+	<-done
+	<-done
+	if peak > 2 {
+		t.Error("It should run at most 2 batch queries at once on the peer but ran ", peak)
+	}
+
+	r := queryAll("", "dtac", []string{"66811111111"}, false).Results[0]
+	if r.Status != http.StatusNotFound || r.ResultCode != UserUnknown || r.Error == "" {
+		t.Error("It should report 404 and Result-Code 5030 but was ", r)
+	}
+}
+
+func TestNewSessionIDUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 20000; i++ {
+		id := newSessionID()
+		if seen[id] {
+			t.Fatal("It should not repeat a Session-Id but repeated ", id)
+		}
+		seen[id] = true
+	}
+}
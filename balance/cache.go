@@ -0,0 +1,181 @@
+package balance
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+
+	"server/config"
+	"server/metrics"
+)
+
+// OpStats names the cache statistics endpoint for authorization.
+const OpStats = "stats"
+
+// Cache outcomes reported in the X-Cache response header.
+const (
+	cacheHit   = "HIT"
+	cacheMiss  = "MISS"
+	cacheStale = "STALE"
+)
+
+type cacheEntry struct {
+	info    BalanceInfo
+	fetched time.Time
+}
+
+// call is a query in flight that identical requests wait on.
+type call struct {
+	done chan struct{}
+	info BalanceInfo
+	err  error
+}
+
+// cache keeps recent balances per corp and subscriber and coalesces
+// concurrent queries for the same key into a single CCR.
+type cache struct {
+	ttl          time.Duration
+	swr          time.Duration
+	staleIfError time.Duration
+	fetch        func(reqID, corp, subr string) (BalanceInfo, error)
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	calls   map[string]*call
+
+	hits, misses, stale uint64
+}
+
+func newCache(c config.Cache, fetch func(reqID, corp, subr string) (BalanceInfo, error)) *cache {
+	return &cache{
+		ttl:          c.TTL.Duration,
+		swr:          c.StaleWhileRevalidate.Duration,
+		staleIfError: c.StaleIfError.Duration,
+		fetch:        fetch,
+		entries:      make(map[string]cacheEntry),
+		calls:        make(map[string]*call),
+	}
+}
+
+// get returns the balance of subr and how it was served. When noCache
+// is set the entry is revalidated with the OCS before it is returned.
+func (c *cache) get(reqID, corp, subr string, noCache bool) (BalanceInfo, string, error) {
+	info, how, err := c.lookup(reqID, corp, subr, noCache)
+	metrics.CacheLookups.WithLabelValues(how).Inc()
+	return info, how, err
+}
+
+func (c *cache) lookup(reqID, corp, subr string, noCache bool) (BalanceInfo, string, error) {
+	key := corp + "/" + subr
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if ok && !noCache {
+		age := time.Since(e.fetched)
+		if age < c.ttl {
+			atomic.AddUint64(&c.hits, 1)
+			return e.info, cacheHit, nil
+		}
+		if age < c.ttl+c.swr {
+			atomic.AddUint64(&c.stale, 1)
+			go c.do(key, reqID, corp, subr)
+			return e.info, cacheStale, nil
+		}
+	}
+
+	atomic.AddUint64(&c.misses, 1)
+	info, err := c.do(key, reqID, corp, subr)
+	if err != nil && ok && time.Since(e.fetched) < c.ttl+c.staleIfError {
+		atomic.AddUint64(&c.stale, 1)
+		return e.info, cacheStale, nil
+	}
+	return info, cacheMiss, err
+}
+
+// do queries the OCS for key unless a query for it is already in
+// flight, in which case it waits for that one.
+func (c *cache) do(key, reqID, corp, subr string) (BalanceInfo, error) {
+	c.mu.Lock()
+	if cl, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		<-cl.done
+		return cl.info, cl.err
+	}
+	cl := &call{done: make(chan struct{})}
+	c.calls[key] = cl
+	c.mu.Unlock()
+
+	cl.info, cl.err = c.fetch(reqID, corp, subr)
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	if cl.err == nil && c.ttl > 0 {
+		c.entries[key] = cacheEntry{info: cl.info, fetched: time.Now()}
+	}
+	c.mu.Unlock()
+	close(cl.done)
+
+	return cl.info, cl.err
+}
+
+// sweep drops entries that can no longer be served.
+func (c *cache) sweep() {
+	keep := c.ttl + c.swr
+	if c.staleIfError > c.swr {
+		keep = c.ttl + c.staleIfError
+	}
+
+	c.mu.Lock()
+	for k, e := range c.entries {
+		if time.Since(e.fetched) >= keep {
+			delete(c.entries, k)
+		}
+	}
+	c.mu.Unlock()
+}
+
+func (c *cache) expire() {
+	for range time.Tick(time.Minute) {
+		c.sweep()
+	}
+}
+
+type CacheStats struct {
+	Entries int    `json:"entries"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Stale   uint64 `json:"stale"`
+}
+
+func (c *cache) stats() CacheStats {
+	c.mu.Lock()
+	n := len(c.entries)
+	c.mu.Unlock()
+	return CacheStats{
+		Entries: n,
+		Hits:    atomic.LoadUint64(&c.hits),
+		Misses:  atomic.LoadUint64(&c.misses),
+		Stale:   atomic.LoadUint64(&c.stale),
+	}
+}
+
+// Stats reports the balance cache counters.
+func Stats(w rest.ResponseWriter, req *rest.Request) {
+	w.WriteJson(balances.stats())
+}
+
+// noCache reports whether the client asked to bypass the cache.
+func noCache(r *http.Request) bool {
+	for _, v := range r.Header["Cache-Control"] {
+		if strings.Contains(strings.ToLower(v), "no-cache") {
+			return true
+		}
+	}
+	return r.Header.Get("Pragma") == "no-cache"
+}
@@ -0,0 +1,110 @@
+package balance
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"server/config"
+)
+
+func cacheConfig(ttl, swr, staleIfError time.Duration) config.Cache {
+	return config.Cache{
+		TTL:                  config.Duration{Duration: ttl},
+		StaleWhileRevalidate: config.Duration{Duration: swr},
+		StaleIfError:         config.Duration{Duration: staleIfError},
+	}
+}
+
+func TestCacheHit(t *testing.T) {
+	var n int32
+	c := newCache(cacheConfig(time.Minute, 0, 0), func(reqID, corp, subr string) (BalanceInfo, error) {
+		atomic.AddInt32(&n, 1)
+		return BalanceInfo{SessionId: subr}, nil
+	})
+
+	c.get("", "dtac", "0812345678", false)
+	b, how, err := c.get("", "dtac", "0812345678", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if how != cacheHit {
+		t.Error("It should be HIT but was ", how)
+	}
+	if b.SessionId != "0812345678" {
+		t.Error("It should be 0812345678 but was ", b.SessionId)
+	}
+	if n != 1 {
+		t.Error("It should query once but queried ", n)
+	}
+}
+
+func TestCacheNoCacheBypass(t *testing.T) {
+	var n int32
+	c := newCache(cacheConfig(time.Minute, 0, 0), func(reqID, corp, subr string) (BalanceInfo, error) {
+		atomic.AddInt32(&n, 1)
+		return BalanceInfo{}, nil
+	})
+
+	c.get("", "dtac", "0812345678", false)
+	_, how, _ := c.get("", "dtac", "0812345678", true)
+	if how != cacheMiss {
+		t.Error("It should be MISS but was ", how)
+	}
+	if n != 2 {
+		t.Error("It should query twice but queried ", n)
+	}
+}
+
+func TestCacheCoalesce(t *testing.T) {
+	var n int32
+	release := make(chan struct{})
+	c := newCache(cacheConfig(0, 0, 0), func(reqID, corp, subr string) (BalanceInfo, error) {
+		atomic.AddInt32(&n, 1)
+		<-release
+		return BalanceInfo{}, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.get("", "dtac", "0812345678", false)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n != 1 {
+		t.Error("It should query once but queried ", n)
+	}
+}
+
+func TestCacheStaleIfError(t *testing.T) {
+	fail := false
+	c := newCache(cacheConfig(time.Nanosecond, 0, time.Minute), func(reqID, corp, subr string) (BalanceInfo, error) {
+		if fail {
+			return BalanceInfo{}, errors.New("OCS down")
+		}
+		return BalanceInfo{SessionId: "old"}, nil
+	})
+
+	c.get("", "dtac", "0812345678", false)
+	fail = true
+	time.Sleep(time.Millisecond)
+
+	b, how, err := c.get("", "dtac", "0812345678", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if how != cacheStale || b.SessionId != "old" {
+		t.Error("It should serve the stale entry but got ", how, b.SessionId)
+	}
+	if s := c.stats(); s.Stale != 1 || s.Misses != 2 {
+		t.Error("Unexpected stats ", s)
+	}
+}
@@ -0,0 +1,228 @@
+package balance
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
+	"github.com/fiorix/go-diameter/diam/datatype"
+
+	"server/logger"
+)
+
+// chargeServiceContext is the Service-Context-Id of debit and refund
+// CCRs.
+const chargeServiceContext = "32274@3gpp.org"
+
+// Money is an amount of Value×10^Exponent in the ISO 4217 currency
+// Currency, as carried in CC-Money.
+type Money struct {
+	Value    int64  `json:"value"`
+	Exponent int32  `json:"exponent"`
+	Currency uint32 `json:"currency"`
+}
+
+// unitValue is Unit-Value as read from an answer.
+type unitValue struct {
+	ValueDigits int64 `avp:"Value-Digits"`
+	Exponent    int32 `avp:"Exponent"`
+}
+
+func (m Money) avp() *diam.AVP {
+	return diam.NewAVP(avp.CCMoney, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			m.unitValue(),
+			diam.NewAVP(avp.CurrencyCode, avp.Mbit, 0, datatype.Unsigned32(m.Currency)),
+		},
+	})
+}
+
+func (m Money) unitValue() *diam.AVP {
+	return diam.NewAVP(avp.UnitValue, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(avp.ValueDigits, avp.Mbit, 0, datatype.Integer64(m.Value)),
+			diam.NewAVP(avp.Exponent, avp.Mbit, 0, datatype.Integer32(m.Exponent)),
+		},
+	})
+}
+
+// Cost is the Cost-Information of an answer.
+type Cost struct {
+	Money
+	Unit string `json:"unit,omitempty"`
+}
+
+type chargeCCA struct {
+	ResultCode uint32 `avp:"Result-Code"`
+	Granted    struct {
+		CCMoney struct {
+			UnitValue    unitValue `avp:"Unit-Value"`
+			CurrencyCode uint32    `avp:"Currency-Code"`
+		} `avp:"CC-Money"`
+	} `avp:"Granted-Service-Unit"`
+	CostInformation struct {
+		UnitValue    unitValue `avp:"Unit-Value"`
+		CurrencyCode uint32    `avp:"Currency-Code"`
+		CostUnit     string    `avp:"Cost-Unit"`
+	} `avp:"Cost-Information"`
+}
+
+func (a chargeCCA) granted() *Money {
+	g := a.Granted.CCMoney
+	if g.CurrencyCode == 0 && g.UnitValue.ValueDigits == 0 {
+		return nil
+	}
+	return &Money{Value: g.UnitValue.ValueDigits, Exponent: g.UnitValue.Exponent, Currency: g.CurrencyCode}
+}
+
+func (a chargeCCA) cost() *Cost {
+	c := a.CostInformation
+	if c.CurrencyCode == 0 && c.UnitValue.ValueDigits == 0 {
+		return nil
+	}
+	return &Cost{
+		Money: Money{Value: c.UnitValue.ValueDigits, Exponent: c.UnitValue.Exponent, Currency: c.CurrencyCode},
+		Unit:  c.CostUnit,
+	}
+}
+
+type ChargeRequest struct {
+	Amount            Money  `json:"amount"`
+	ServiceIdentifier uint32 `json:"serviceIdentifier"`
+	RatingGroup       uint32 `json:"ratingGroup"`
+}
+
+type ChargeResult struct {
+	SessionID  string `json:"sessionId"`
+	ResultCode uint32 `json:"resultCode"`
+	Granted    *Money `json:"granted,omitempty"`
+	Cost       *Cost  `json:"cost,omitempty"`
+}
+
+// newChargeRequest builds the event CCR for a debit or refund of
+// body.Amount.
+func newChargeRequest(sessionID, subr string, action int32, body ChargeRequest) *diam.Message {
+	r := newCCR(sessionID, subr, EventRequest)
+	r.NewAVP(avp.ServiceContextID, avp.Mbit, 0, datatype.UTF8String(chargeServiceContext))
+	r.NewAVP(avp.RequestedAction, avp.Mbit, 0, datatype.Enumerated(action))
+	r.NewAVP(avp.CCRequestNumber, avp.Mbit, 0, datatype.Unsigned32(0))
+	r.NewAVP(avp.EventTimestamp, avp.Mbit, 0, datatype.Time(time.Now()))
+	r.NewAVP(avp.RequestedServiceUnit, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{body.Amount.avp()},
+	})
+	if body.ServiceIdentifier > 0 {
+		r.NewAVP(avp.ServiceIdentifier, avp.Mbit, 0, datatype.Unsigned32(body.ServiceIdentifier))
+	}
+	if body.RatingGroup > 0 {
+		r.NewAVP(avp.RatingGroup, avp.Mbit, 0, datatype.Unsigned32(body.RatingGroup))
+	}
+	r.NewAVP(avp.DestinationHost, avp.Mbit, 0, datatype.OctetString("cbp211"))
+	return r
+}
+
+// amountVars returns the template placeholders for amount.
+func amountVars(subr string, amount Money) map[string]string {
+	return map[string]string{
+		"subscriber": subr,
+		"amount":     strconv.FormatInt(amount.Value, 10),
+		"exponent":   strconv.Itoa(int(amount.Exponent)),
+		"currency":   strconv.FormatUint(uint64(amount.Currency), 10),
+	}
+}
+
+// charge sends a debit or refund of body.Amount for subr and returns
+// what the OCS granted and charged. A retry of an earlier attempt is
+// flagged as a retransmission, with the End-to-End Id of the first, so
+// the OCS can detect the duplicate.
+func charge(reqID, corp, subr string, action int32, body ChargeRequest, a *attempt) (ChargeResult, error) {
+	op := OpDebit
+	if action == RefundAccount {
+		op = OpRefund
+	}
+	vars := amountVars(subr, body.Amount)
+	vars["serviceIdentifier"] = strconv.FormatUint(uint64(body.ServiceIdentifier), 10)
+	vars["ratingGroup"] = strconv.FormatUint(uint64(body.RatingGroup), 10)
+	b := requestBuilder(corp, op, vars, func(sessionID string) *diam.Message {
+		return newChargeRequest(sessionID, subr, action, body)
+	})
+
+	m, err := exchangeSent(reqID, corp, a.sessionID, func(sessionID string) *diam.Message {
+		r := b(sessionID)
+		if r != nil && a.retry {
+			r.Header.CommandFlags |= diam.RetransmittedFlag
+			r.Header.EndToEndID = a.endToEnd
+		}
+		return r
+	}, func(r *diam.Message) {
+		a.sent = true
+		a.endToEnd = r.Header.EndToEndID
+	})
+	if m == nil {
+		return ChargeResult{}, err
+	}
+
+	var cca chargeCCA
+	m.Unmarshal(&cca)
+	return ChargeResult{
+		SessionID:  a.sessionID,
+		ResultCode: cca.ResultCode,
+		Granted:    cca.granted(),
+		Cost:       cca.cost(),
+	}, err
+}
+
+// Debit charges the amount in the request body to a subscriber.
+func Debit(w rest.ResponseWriter, req *rest.Request) {
+	chargeHandler(w, req, DirectDebiting)
+}
+
+// Refund credits the amount in the request body to a subscriber.
+func Refund(w rest.ResponseWriter, req *rest.Request) {
+	chargeHandler(w, req, RefundAccount)
+}
+
+func chargeHandler(w rest.ResponseWriter, req *rest.Request, action int32) {
+	corp := req.PathParam("corp")
+	subr := req.PathParam("subr")
+
+	key := req.Header.Get(IdempotencyHeader)
+	if key == "" {
+		rest.Error(w, IdempotencyHeader+" header is required", http.StatusBadRequest)
+		return
+	}
+	var body ChargeRequest
+	if err := req.DecodeJsonPayload(&body); err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Amount.Value <= 0 || body.Amount.Currency == 0 {
+		rest.Error(w, "amount value and currency are required", http.StatusBadRequest)
+		return
+	}
+
+	reqID := logger.RequestID(req)
+	res, replayed, err := charges.do(corp+"/"+subr+"/"+key, action, body, func(a *attempt) (ChargeResult, error) {
+		return charge(reqID, corp, subr, action, body, a)
+	})
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	if err != nil {
+		logger.Error("charge failed", "request_id", reqID, "corp", corp, "action", action, "err", err)
+		if err == ErrIdempotencyMismatch {
+			rest.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if _, ok := err.(ResultError); ok {
+			w.WriteHeader(errorStatus(err))
+			w.WriteJson(res)
+			return
+		}
+		httpError(w, err)
+		return
+	}
+	w.WriteJson(res)
+}
@@ -0,0 +1,58 @@
+package balance
+
+import (
+	"testing"
+
+	"github.com/fiorix/go-diameter/diam"
+)
+
+// sentCharge is the part of a debit or refund CCR checked by the tests.
+type sentCharge struct {
+	SessionID         string `avp:"Session-Id"`
+	CCRequestType     int    `avp:"CC-Request-Type"`
+	ServiceContextID  string `avp:"Service-Context-Id"`
+	RequestedAction   int    `avp:"Requested-Action"`
+	ServiceIdentifier uint32 `avp:"Service-Identifier"`
+	RatingGroup       uint32 `avp:"Rating-Group"`
+	SubscriptionID    struct {
+		Data string `avp:"Subscription-Id-Data"`
+	} `avp:"Subscription-Id"`
+	Requested struct {
+		CCMoney struct {
+			UnitValue    unitValue `avp:"Unit-Value"`
+			CurrencyCode uint32    `avp:"Currency-Code"`
+		} `avp:"CC-Money"`
+	} `avp:"Requested-Service-Unit"`
+}
+
+func TestNewChargeRequest(t *testing.T) {
+	for _, action := range []int32{DirectDebiting, RefundAccount} {
+		body := ChargeRequest{
+			Amount:            Money{Value: 1250, Exponent: -2, Currency: 764},
+			ServiceIdentifier: 7,
+			RatingGroup:       10,
+		}
+		r := newChargeRequest("dtac.co.th;OMR1", "66812345678", action, body)
+		if r.Header.CommandCode != diam.CreditControl || r.Header.CommandFlags&diam.RequestFlag == 0 {
+			t.Fatal("It should be a CCR but was ", r.Header)
+		}
+
+		var c sentCharge
+		if err := r.Unmarshal(&c); err != nil {
+			t.Fatal(err)
+		}
+		if c.SessionID != "dtac.co.th;OMR1" || c.SubscriptionID.Data != "66812345678" {
+			t.Error("Unexpected session or subscriber ", c.SessionID, c.SubscriptionID.Data)
+		}
+		if c.CCRequestType != EventRequest || c.ServiceContextID != chargeServiceContext || c.RequestedAction != int(action) {
+			t.Errorf("Unexpected request for action %d: %+v", action, c)
+		}
+		m := c.Requested.CCMoney
+		if m.UnitValue.ValueDigits != 1250 || m.UnitValue.Exponent != -2 || m.CurrencyCode != 764 {
+			t.Errorf("Unexpected CC-Money %+v", m)
+		}
+		if c.ServiceIdentifier != 7 || c.RatingGroup != 10 {
+			t.Error("It should carry the service and rating group but was ", c.ServiceIdentifier, c.RatingGroup)
+		}
+	}
+}
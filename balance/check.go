@@ -0,0 +1,111 @@
+package balance
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
+	"github.com/fiorix/go-diameter/diam/datatype"
+
+	"server/logger"
+)
+
+// Check-Balance-Result values (RFC 4006).
+const (
+	EnoughCredit = 0
+	NoCredit     = 1
+)
+
+var errBadAmount = errors.New("balance: amount must be a positive decimal number")
+
+type CheckResult struct {
+	Enough     bool   `json:"enough"`
+	ResultCode uint32 `json:"resultCode"`
+}
+
+type checkCCA struct {
+	ResultCode         uint32 `avp:"Result-Code"`
+	CheckBalanceResult int    `avp:"Check-Balance-Result"`
+}
+
+// parseAmount reads a decimal amount such as "12.50" as Value-Digits
+// and Exponent.
+func parseAmount(s string, currency uint32) (Money, error) {
+	var exp int32
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		exp = -int32(len(s) - i - 1)
+		s = s[:i] + s[i+1:]
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || v <= 0 {
+		return Money{}, errBadAmount
+	}
+	return Money{Value: v, Exponent: exp, Currency: currency}, nil
+}
+
+// newCheckRequest builds the CHECK_BALANCE event CCR asking whether
+// subr can pay amount.
+func newCheckRequest(sessionID, subr string, amount Money) *diam.Message {
+	r := newCCR(sessionID, subr, EventRequest)
+	r.NewAVP(avp.ServiceContextID, avp.Mbit, 0, datatype.UTF8String(chargeServiceContext))
+	r.NewAVP(avp.RequestedAction, avp.Mbit, 0, datatype.Enumerated(CheckBalance))
+	r.NewAVP(avp.CCRequestNumber, avp.Mbit, 0, datatype.Unsigned32(0))
+	r.NewAVP(avp.EventTimestamp, avp.Mbit, 0, datatype.Time(time.Now()))
+	r.NewAVP(avp.RequestedServiceUnit, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{amount.avp()},
+	})
+	r.NewAVP(avp.DestinationHost, avp.Mbit, 0, datatype.OctetString("cbp211"))
+	return r
+}
+
+// check asks the OCS of corp whether subr has at least amount.
+func check(reqID, corp, subr string, amount Money) (CheckResult, error) {
+	m, err := exchange(reqID, corp, newSessionID(), requestBuilder(corp, OpCheck, amountVars(subr, amount), func(sessionID string) *diam.Message {
+		return newCheckRequest(sessionID, subr, amount)
+	}))
+	if err != nil {
+		return CheckResult{}, err
+	}
+
+	if _, err = m.FindAVP(avp.CheckBalanceResult); err != nil {
+		return CheckResult{}, errors.New("balance: OCS answered without Check-Balance-Result")
+	}
+	var a checkCCA
+	if err = m.Unmarshal(&a); err != nil {
+		return CheckResult{}, err
+	}
+	return CheckResult{Enough: a.CheckBalanceResult == EnoughCredit, ResultCode: a.ResultCode}, nil
+}
+
+// Check tells whether a subscriber has at least the amount given in the
+// amount and currency query parameters.
+func Check(w rest.ResponseWriter, req *rest.Request) {
+	corp := req.PathParam("corp")
+	subr := req.PathParam("subr")
+
+	q := req.URL.Query()
+	currency, err := strconv.ParseUint(q.Get("currency"), 10, 32)
+	if err != nil {
+		rest.Error(w, "currency must be an ISO 4217 numeric code", http.StatusBadRequest)
+		return
+	}
+	amount, err := parseAmount(q.Get("amount"), uint32(currency))
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reqID := logger.RequestID(req)
+	resp, err := check(reqID, corp, subr, amount)
+	if err != nil {
+		logger.Error("balance check failed", "request_id", reqID, "corp", corp, "err", err)
+		httpError(w, err)
+		return
+	}
+	w.WriteJson(resp)
+}
@@ -0,0 +1,24 @@
+package balance
+
+import "testing"
+
+func TestParseAmount(t *testing.T) {
+	m, err := parseAmount("12.50", 764)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Value != 1250 || m.Exponent != -2 || m.Currency != 764 {
+		t.Error("It should be 1250e-2 THB but was ", m)
+	}
+
+	m, _ = parseAmount("30", 764)
+	if m.Value != 30 || m.Exponent != 0 {
+		t.Error("It should be 30e0 but was ", m)
+	}
+
+	for _, s := range []string{"", "-5", "0", "1.2.3", "abc"} {
+		if _, err := parseAmount(s, 764); err == nil {
+			t.Error("It should reject ", s)
+		}
+	}
+}
@@ -9,16 +9,22 @@
 package balance
 
 import (
-	"log"
+	"fmt"
 	"server/dictionary"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/fiorix/go-diameter/diam"
-	// "github.com/fiorix/go-diameter/diam/avp"
+	"github.com/fiorix/go-diameter/diam/avp"
 	"github.com/fiorix/go-diameter/diam/datatype"
 	"github.com/fiorix/go-diameter/diam/dict"
 
+	"server/config"
 	"server/diameter"
+	"server/logger"
+	"server/metrics"
 )
 
 const (
@@ -26,45 +32,301 @@ const (
 	realm       = datatype.DiameterIdentity("dtac.co.th")
 	vendorID    = datatype.Unsigned32(0)
 	productName = datatype.UTF8String("omr")
-
-	dtacAddr = "10.89.111.12:6553"
-	dtnAddr  = "10.89.111.40:6573"
 )
 
+// reconnectDelay is the pause before dialing a peer again.
+const reconnectDelay = 5 * time.Second
+
+type peer struct {
+	addr     string
+	inflight chan struct{}
+	// batch caps the batch queries on the peer, shared by every batch,
+	// at half its outstanding CCRs so that single queries keep the
+	// rest.
+	batch chan struct{}
+	// stop is closed when the peer is removed from the configuration.
+	stop chan struct{}
+
+	mu   sync.RWMutex
+	conn diam.Conn
+	// busyUntil is set when the peer answers DIAMETER_TOO_BUSY.
+	busyUntil time.Time
+}
+
+func newPeer(addr string) *peer {
+	return &peer{
+		addr:     addr,
+		inflight: make(chan struct{}, maxOutstanding),
+		batch:    make(chan struct{}, (maxOutstanding+1)/2),
+		stop:     make(chan struct{}),
+	}
+}
+
+// throttle keeps new CCRs off p for d.
+func (p *peer) throttle(d time.Duration) {
+	p.mu.Lock()
+	p.busyUntil = time.Now().Add(d)
+	p.mu.Unlock()
+}
+
+// busy returns how long p is still throttled.
+func (p *peer) busy() time.Duration {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if d := time.Until(p.busyUntil); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Conn returns the current connection to the peer, or nil while it is
+// disconnected.
+func (p *peer) Conn() diam.Conn {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.conn
+}
+
+func (p *peer) setConn(c diam.Conn) {
+	p.mu.Lock()
+	p.conn = c
+	p.mu.Unlock()
+}
+
+// run keeps a connection to the peer open, dialing it again whenever
+// it drops, until the peer is retired.
+func (p *peer) run() {
+	defer p.forget()
+	for i := 0; ; i++ {
+		if i > 0 {
+			select {
+			case <-p.stop:
+				return
+			case <-time.After(reconnectDelay):
+			}
+			metrics.Reconnects.WithLabelValues(p.addr).Inc()
+		}
+
+		c, err := diam.Dial(p.addr, nil, nil)
+		if err != nil {
+			logger.Error("dial failed", "peer", p.addr, "err", err)
+			continue
+		}
+		p.setConn(c)
+		select {
+		case <-p.stop:
+			// Retired while dialing.
+			c.Close()
+		default:
+		}
+		diameter.Serve(c, p.addr, identity, realm, vendorID, productName)
+		p.setConn(nil)
+	}
+}
+
+// forget drops the status of the address of p, unless a reload has
+// configured a peer at the same address meanwhile.
+func (p *peer) forget() {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	for _, ps := range corps {
+		if findPeer(ps, p.addr) != nil {
+			return
+		}
+	}
+	diameter.Forget(p.addr)
+}
+
+// retire stops redialing p and, once its outstanding CCRs are answered
+// or drain has passed, disconnects it with a DPR.
+func (p *peer) retire(drain time.Duration) {
+	close(p.stop)
+	deadline := time.Now().Add(drain)
+	for len(p.inflight) > 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if c := p.Conn(); c != nil {
+		diameter.Disconnect(c, identity, realm)
+	}
+}
+
 var (
-	cdtac             diam.Conn
-	cdtn              diam.Conn
+	// stateLock guards the settings replaced by Reload: corps,
+	// defaultCorp, templates, mappings, answerTimeout and notifyConfig.
+	stateLock         sync.RWMutex
+	corps             map[string][]*peer
+	defaultCorp       string
 	DefaultRestWriter rest.ResponseWriter
+	balances          *cache
+
+	// replayAddr, when set, replaces every configured peer.
+	replayAddr string
 )
 
-func Start() {
-	var err error
+// peerFor returns the OCS connection serving corp, preferring a peer
+// whose watchdog is OKAY and that is not throttled.
+func peerFor(corp string) *peer {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	ps, ok := corps[corp]
+	if !ok {
+		ps = corps[defaultCorp]
+	}
+	if len(ps) == 0 {
+		return nil
+	}
+	var fallback *peer
+	for _, p := range ps {
+		if p.busy() > 0 {
+			continue
+		}
+		if diameter.Status(p.addr).State == diameter.StateOkay {
+			return p
+		}
+		if fallback == nil {
+			fallback = p
+		}
+	}
+	if fallback != nil {
+		return fallback
+	}
+	return ps[0]
+}
+
+// corpOrDefault returns corp, or the default corp when it is empty.
+func corpOrDefault(corp string) string {
+	if corp != "" {
+		return corp
+	}
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	return defaultCorp
+}
 
-	response = make(map[string]chan BalanceInfo)
-	dict.Default = dictionary.Load()
+func Start(cfg *config.Config) {
+	response = make(map[string]chan answer)
+	balances = newCache(cfg.Cache, query)
+	go balances.expire()
+	go charges.expire()
+	go expireLimits()
+	go expireSessions()
+	dp, err := dictionary.New(cfg.Dictionaries...)
+	if err != nil {
+		logger.Fatal("dictionary load failed", "err", err)
+	}
+	dict.Default = dp
+	t, err := loadTemplates(dp, cfg.Corps)
+	if err != nil {
+		logger.Fatal("bad request template", "err", err)
+	}
+	ms, err := loadMappings(dp, cfg.Corps)
+	if err != nil {
+		logger.Fatal("bad response mapping", "err", err)
+	}
 	diam.HandleFunc("CEA", diameter.OnCEA)
+	diam.HandleFunc("DWA", diameter.OnDWA)
+	diam.HandleFunc("DPA", diameter.OnDPA)
 	diam.HandleFunc("CCA", OnCCA)
+	diam.HandleFunc("RAR", OnRAR)
+	diam.HandleFunc("ASR", OnASR)
+	if err := diameter.SetValidation(cfg.Validation); err != nil {
+		logger.Fatal("bad validation mode", "err", err)
+	}
 
-	cdtac, err = diam.Dial(dtacAddr, nil, nil)
-	if err != nil {
-		log.Fatal(err)
-		return
+	setCaptureDir(cfg.Capture.Dir)
+	if err := diameter.SetCapture(diameter.CaptureConfig(cfg.Capture)); err != nil {
+		logger.Error("capture disabled", "err", err)
 	}
-	go diameter.Serve(cdtac, identity, realm, vendorID, productName)
 
-	cdtn, err = diam.Dial(dtnAddr, nil, nil)
-	if err != nil {
-		log.Fatal(err)
-		return
+	if replayAddr, err = startReplay(cfg.Replay, cfg.AnswerTimeout.Duration); err != nil {
+		logger.Fatal("replay setup failed", "mode", cfg.Replay.Mode, "file", cfg.Replay.File, "err", err)
+	}
+
+	stateLock.Lock()
+	templates = t
+	mappings = ms
+	answerTimeout = cfg.AnswerTimeout.Duration
+	notifyConfig = cfg.Notify
+	setLimits(cfg.Limits)
+	corps = make(map[string][]*peer)
+	defaultCorp = cfg.DefaultCorp
+	setPeers(cfg.Corps)
+	stateLock.Unlock()
+}
+
+// setPeers dials the peers of cfg that are not connected yet and
+// returns the ones that are no longer configured, removed from corps
+// but still to be retired. stateLock must be held.
+func setPeers(cfg map[string]config.Corp) (added, removed []PeerChange) {
+	next := make(map[string][]*peer)
+	for name, corp := range cfg {
+		addrs := corp.Peers
+		if replayAddr != "" {
+			addrs = []string{replayAddr}
+		}
+		for _, addr := range addrs {
+			if p := findPeer(corps[name], addr); p != nil {
+				next[name] = append(next[name], p)
+				continue
+			}
+			p := newPeer(addr)
+			next[name] = append(next[name], p)
+			diameter.SetCorp(addr, name)
+			go p.run()
+			added = append(added, PeerChange{Corp: name, Addr: addr, peer: p})
+		}
+	}
+	for name, ps := range corps {
+		for _, p := range ps {
+			if findPeer(next[name], p.addr) == nil {
+				removed = append(removed, PeerChange{Corp: name, Addr: p.addr, peer: p})
+			}
+		}
 	}
-	go diameter.Serve(cdtn, identity, realm, vendorID, productName)
+	corps = next
+	names := make([]string, 0, len(next))
+	for name := range next {
+		names = append(names, name)
+	}
+	metrics.SetCorps(names)
+	return added, removed
+}
+
+func findPeer(ps []*peer, addr string) *peer {
+	for _, p := range ps {
+		if p.addr == addr {
+			return p
+		}
+	}
+	return nil
 }
 
 func OnCCA(c diam.Conn, m *diam.Message) {
-	log.Printf("Receiving message from %s", c.RemoteAddr().String())
 	if m.Header.CommandCode == 272 {
-		var balance BalanceInfo
-		m.Unmarshal(&balance)
-		response[balance.SessionId] <- balance
+		a := answer{msg: m}
+		code := "unknown"
+		if rc, err := m.FindAVP(avp.ResultCode); err == nil {
+			if v, ok := rc.Data.(datatype.Unsigned32); ok {
+				a.resultCode = uint32(v)
+				code = strconv.Itoa(int(v))
+			}
+		}
+		if sid, err := m.FindAVP(avp.SessionID); err == nil {
+			a.sessionID = fmt.Sprint(diameter.Value(sid.Data))
+		}
+		metrics.CCAReceived.WithLabelValues(diameter.PeerAddr(c), code).Inc()
+
+		logger.Debug("received cca",
+			"peer", diameter.PeerAddr(c),
+			"session_id", a.sessionID,
+			"hop_by_hop", m.Header.HopByHopID,
+			"result_code", code,
+		)
+		if logger.Tracing() {
+			logger.Debug("cca", "session_id", a.sessionID, "message", diameter.Format(m))
+		}
+		diameter.Capture(c, m, false)
+		a.invalid = diameter.Check(m, false)
+		deliver(a)
 	}
 }
@@ -1 +1,942 @@
 package balance_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/diamtest"
+
+	"server/avps"
+	"server/balance"
+	"server/config"
+	"server/diameter"
+	"server/dictionary"
+	"server/logger"
+	"server/sim"
+)
+
+// sentCCR is the part of the balance CCR checked by the tests.
+type sentCCR struct {
+	SessionID         string `avp:"Session-Id"`
+	AuthApplicationID int    `avp:"Auth-Application-Id"`
+	DestinationRealm  string `avp:"Destination-Realm"`
+	DestinationHost   string `avp:"Destination-Host"`
+	OriginHost        string `avp:"Origin-Host"`
+	OriginRealm       string `avp:"Origin-Realm"`
+	CCRequestType     int    `avp:"CC-Request-Type"`
+	CCRequestNumber   int    `avp:"CC-Request-Number"`
+	ServiceContextID  string `avp:"Service-Context-Id"`
+	RequestedAction   int    `avp:"Requested-Action"`
+	SubscriptionID    struct {
+		Type int    `avp:"Subscription-Id-Type"`
+		Data string `avp:"Subscription-Id-Data"`
+	} `avp:"Subscription-Id"`
+	MSCC struct {
+		Used struct {
+			Time uint32 `avp:"CC-Time"`
+		} `avp:"Used-Service-Unit"`
+	} `avp:"Multiple-Services-Credit-Control"`
+	ServiceInformation struct {
+		BalanceInformation struct {
+			CallingPartyAddress string `avp:"Calling-Party-Address"`
+			AccessMethod        int    `avp:"Access-Method"`
+			AccountQueryMethod  int    `avp:"Account-Query-Method"`
+		} `avp:"Balance-Information"`
+	} `avp:"Service-Information"`
+}
+
+var (
+	api *httptest.Server
+	ocs *sim.Simulator
+
+	sentLock sync.Mutex
+	sent     []sentCCR
+
+	notesLock sync.Mutex
+	notes     chan balance.Notification
+)
+
+func TestMain(m *testing.M) {
+	logger.SetLevel(logger.LevelError)
+
+	fx, err := sim.LoadFixture("../sim/testdata/subscribers.json")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fx.Latency = config.Duration{}
+	fx.ValidityTime = sessionValidity
+	fx.UnitPrice = 25
+	fx.Currency = 764
+	// Subscribers told apart by their balance, for the concurrent test.
+	for i := 0; i < concurrentSubscribers; i++ {
+		s := *fx.Subscribers["66812345678"]
+		s.Balance = concurrentBalance(i)
+		fx.Subscribers[concurrentSubscriber(i)] = &s
+	}
+	busy := *fx.Subscribers["66812345678"]
+	busy.ResultCode = diam.TooBusy
+	fx.Subscribers[busySubscriber] = &busy
+	ocs = sim.New(fx)
+	ocs.Observe = func(m *diam.Message) {
+		var r sentCCR
+		m.Unmarshal(&r)
+		sentLock.Lock()
+		sent = append(sent, r)
+		sentLock.Unlock()
+	}
+	srv := diamtest.NewServer(ocs.Handler(), dictionary.Load())
+
+	balance.Notify = func(n balance.Notification) {
+		notesLock.Lock()
+		defer notesLock.Unlock()
+		select {
+		case notes <- n:
+		default:
+		}
+	}
+
+	cfg := config.Default()
+	cfg.Corps = map[string]config.Corp{
+		"dtac": {Peers: []string{srv.Address}},
+		"down": {Peers: []string{"127.0.0.1:1"}},
+	}
+	cfg.DefaultCorp = "dtac"
+	cfg.Cache = config.Cache{}
+	cfg.AnswerTimeout = config.Duration{Duration: 300 * time.Millisecond}
+	cfg.Limits.BusyBackoff = config.Duration{Duration: busyBackoff}
+	balance.Start(cfg)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for diameter.Status(srv.Address).State != diameter.StateOkay {
+		if time.Now().After(deadline) {
+			fmt.Println("Timed out: no CEA from the simulator")
+			os.Exit(1)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	admin := &balance.AdminMiddleware{}
+	admin.SetToken(adminToken)
+	a := rest.NewApi()
+	a.Use(&logger.RequestMiddleware{})
+	a.Use(admin)
+	router, err := rest.MakeRouter(
+		&rest.Route{"GET", "/balance/:corp/:subr", balance.Balance},
+		&rest.Route{"POST", "/balance/:corp/batch", balance.Batch},
+		&rest.Route{"GET", "/price/:corp/:subr", balance.GetPrice},
+		&rest.Route{"POST", "/debit/:corp/:subr", balance.Debit},
+		&rest.Route{"POST", "/refund/:corp/:subr", balance.Refund},
+		&rest.Route{"POST", "/sessions/:corp/:subr", balance.CreateSession},
+		&rest.Route{"GET", "/sessions/:id", balance.GetSession},
+		&rest.Route{"PUT", "/sessions/:id", balance.UpdateSession},
+		&rest.Route{"DELETE", "/sessions/:id", balance.DeleteSession},
+		&rest.Route{"POST", "/admin/raw", balance.PostRaw},
+	)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	a.SetApp(router)
+	api = httptest.NewServer(a.MakeHandler())
+
+	code := m.Run()
+	api.Close()
+	srv.Close()
+	os.Exit(code)
+}
+
+func resetSent() {
+	sentLock.Lock()
+	sent = nil
+	sentLock.Unlock()
+}
+
+func sentCCRs() []sentCCR {
+	sentLock.Lock()
+	defer sentLock.Unlock()
+	return append([]sentCCR(nil), sent...)
+}
+
+func fetch(path string) (*http.Response, []byte, error) {
+	req, _ := http.NewRequest("GET", api.URL+path, nil)
+	req.Header.Set("Cache-Control", "no-cache")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	var body json.RawMessage
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp, body, nil
+}
+
+func get(t *testing.T, path string) (*http.Response, []byte) {
+	resp, body, err := fetch(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, body
+}
+
+func TestBalanceSuccess(t *testing.T) {
+	resetSent()
+	resp, body := get(t, "/balance/dtac/66812345678")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("It should be 200 but was ", resp.StatusCode, string(body))
+	}
+
+	var b balance.BalanceInfo
+	if err := json.Unmarshal(body, &b); err != nil {
+		t.Fatal(err)
+	}
+	bi := b.ServiceInformation.BalanceInformation
+	if bi.Balance != 12550 {
+		t.Error("It should be 12550 but was ", bi.Balance)
+	}
+	if len(bi.AccountChangeInfo) != 1 || bi.AccountChangeInfo[0].AccountTypeDesc != "Main Balance" {
+		t.Error("Unexpected Account-Change-Info ", bi.AccountChangeInfo)
+	}
+	if len(bi.OfferInformation) != 1 || bi.OfferInformation[0].OfferInfo[0].ExternalOfferCode != "DATA_PASS_1GB" {
+		t.Error("Unexpected Offer-Information ", bi.OfferInformation)
+	}
+
+	ccrs := sentCCRs()
+	if len(ccrs) != 1 {
+		t.Fatal("It should send 1 CCR but sent ", len(ccrs))
+	}
+	r := ccrs[0]
+	if !strings.HasPrefix(r.SessionID, "dtac.co.th;OMR") || r.SessionID != b.SessionId {
+		t.Error("Unexpected Session-Id ", r.SessionID, " answered ", b.SessionId)
+	}
+	want := sentCCR{
+		SessionID:         r.SessionID,
+		AuthApplicationID: 4,
+		DestinationRealm:  "www.huawei.com",
+		DestinationHost:   "cbp211",
+		OriginHost:        "jenkin13_OMR_TEST01",
+		OriginRealm:       "dtac.co.th",
+		CCRequestType:     4,
+		CCRequestNumber:   0,
+		ServiceContextID:  "QueryBalance@huawei.com",
+		RequestedAction:   2,
+	}
+	want.SubscriptionID.Type = 0
+	want.SubscriptionID.Data = "66812345678"
+	want.ServiceInformation.BalanceInformation.CallingPartyAddress = "66812345678"
+	want.ServiceInformation.BalanceInformation.AccessMethod = 9
+	want.ServiceInformation.BalanceInformation.AccountQueryMethod = 1
+	if r != want {
+		t.Errorf("Unexpected CCR\nwant %+v\nhave %+v", want, r)
+	}
+}
+
+func TestBalanceInformationDecoded(t *testing.T) {
+	resp, body := get(t, "/balance/dtac/66812345678")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("It should be 200 but was ", resp.StatusCode, string(body))
+	}
+	var b balance.BalanceInfo
+	if err := json.Unmarshal(body, &b); err != nil {
+		t.Fatal(err)
+	}
+
+	want := avps.BalanceInformationGroup{
+		FirstActiveDate: "20150101000000",
+		SubscriberState: 1,
+		ActivePeriod:    "20261231235959",
+		GracePeriod:     "20270131235959",
+		DisablePeriod:   "20270228235959",
+		Balance:         12550,
+		LanguageIVR:     1,
+		LanguageSMS:     1,
+		LanguageUSSD:    1,
+		AccountChangeInfo: []avps.AccountChangeInfoGroup{{
+			AccountId:             "2000",
+			AccountType:           2000,
+			AccountTypeDesc:       "Main Balance",
+			AccountBeginDate:      "20150101000000",
+			CurrentAccountBalance: 12550,
+			AccountEndDate:        "20371231235959",
+			MeasureType:           1,
+		}},
+		// Both offers in one Offer-Information.
+		OfferInformation: []avps.OfferInformationGroup{{OfferInfo: []avps.OfferInfoGroup{
+			{
+				OfferId:           "50001",
+				OfferOrderKey:     "1001",
+				EffectiveTime:     "20260101000000",
+				ExpireTime:        "20261231235959",
+				Status:            "2",
+				CurrentCycle:      1,
+				TotalCycle:        12,
+				ExternalOfferCode: "DATA_PASS_1GB",
+			},
+			{
+				OfferId:           "50002",
+				OfferOrderKey:     "1002",
+				EffectiveTime:     "20260601000000",
+				ExpireTime:        "20260701000000",
+				Status:            "2",
+				CurrentCycle:      1,
+				TotalCycle:        1,
+				ExternalOfferCode: "VOICE_100MIN",
+			},
+		}}},
+	}
+	if got := b.ServiceInformation.BalanceInformation; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected Balance-Information\nwant %+v\nhave %+v", want, got)
+	}
+	for _, field := range []string{`"ShareFlag"`, `"OfferId"`, `"ExpireTime"`} {
+		if !strings.Contains(string(body), field) {
+			t.Error("It should write " + field + " in the JSON")
+		}
+	}
+}
+
+func TestBalanceUnknownSubscriber(t *testing.T) {
+	resp, body := get(t, "/balance/dtac/66811111111")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Error("It should be 404 but was ", resp.StatusCode, string(body))
+	}
+	if !strings.Contains(string(body), "5030") {
+		t.Error("It should report Result-Code 5030 but was ", string(body))
+	}
+}
+
+func TestBalanceTimeout(t *testing.T) {
+	start := time.Now()
+	resp, body := get(t, "/balance/dtac/66800000000")
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Error("It should be 504 but was ", resp.StatusCode, string(body))
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Error("It should time out after the answer timeout but took ", d)
+	}
+}
+
+func TestBalancePeerDown(t *testing.T) {
+	resp, body := get(t, "/balance/down/66812345678")
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Error("It should be 503 but was ", resp.StatusCode, string(body))
+	}
+}
+
+const concurrentSubscribers = 20
+
+func concurrentSubscriber(i int) string {
+	return fmt.Sprintf("668200000%02d", i)
+}
+
+func concurrentBalance(i int) int64 {
+	return int64(1000 + i)
+}
+
+func TestBalanceConcurrent(t *testing.T) {
+	resetSent()
+	const n = concurrentSubscribers
+
+	type result struct {
+		code int
+		b    balance.BalanceInfo
+	}
+	results := make([]result, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, body, err := fetch("/balance/dtac/" + concurrentSubscriber(i))
+			if err != nil {
+				return
+			}
+			results[i].code = resp.StatusCode
+			json.Unmarshal(body, &results[i].b)
+		}(i)
+	}
+	wg.Wait()
+
+	sessions := make(map[string]bool)
+	for i, r := range results {
+		if r.code != http.StatusOK {
+			t.Error("It should be 200 but was ", r.code)
+			continue
+		}
+		// Each answer must be the one for its own subscriber, not one
+		// delivered to the wrong waiting request.
+		if v := r.b.ServiceInformation.BalanceInformation.Balance; v != concurrentBalance(i) {
+			t.Error("It should be the balance of "+concurrentSubscriber(i)+" but was ", v)
+		}
+		if sessions[r.b.SessionId] {
+			t.Error("It should answer each request on its own session but repeated ", r.b.SessionId)
+		}
+		sessions[r.b.SessionId] = true
+	}
+	if len(sentCCRs()) > n {
+		t.Error("It should send at most one CCR per request but sent ", len(sentCCRs()))
+	}
+}
+
+func TestBatchPartialFailure(t *testing.T) {
+	body := strings.NewReader(`{"subscribers": ["66812345678", "66811111111"]}`)
+	resp, err := http.Post(api.URL+"/balance/dtac/batch", "application/json", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("It should be 200 but was ", resp.StatusCode)
+	}
+
+	var br balance.BatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&br); err != nil {
+		t.Fatal(err)
+	}
+	if len(br.Results) != 2 {
+		t.Fatal("It should have 2 results but had ", len(br.Results))
+	}
+	if br.Results[0].Balance == nil || br.Results[0].Error != "" {
+		t.Error("It should return the first balance but got ", br.Results[0])
+	}
+	if br.Results[1].Balance != nil || br.Results[1].Error == "" {
+		t.Error("It should fail the second subscriber but got ", br.Results[1])
+	}
+	if br.Results[1].Status != http.StatusNotFound || br.Results[1].ResultCode != balance.UserUnknown {
+		t.Error("It should report 404 and Result-Code 5030 but got ", br.Results[1])
+	}
+}
+
+// sessionValidity is the Validity-Time the simulator grants, in seconds.
+const sessionValidity = 600
+
+// send makes a request with a JSON body and the given headers, as
+// name/value pairs, to the API.
+func send(t *testing.T, method, path, body string, headers ...string) (*http.Response, []byte) {
+	req, _ := http.NewRequest(method, api.URL+path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var b json.RawMessage
+	json.NewDecoder(resp.Body).Decode(&b)
+	return resp, b
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	resetSent()
+	s := &balance.Session{
+		Corp:             "dtac",
+		Subscriber:       "66812345678",
+		ServiceContextID: "32251@3gpp.org",
+		RatingGroup:      10,
+	}
+	if err := balance.OpenSession("", s, balance.Units{Time: 60}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range []struct {
+		name    string
+		do      func() error
+		state   string
+		granted uint32
+		used    uint32
+		number  uint32
+	}{
+		{"initial", func() error { return nil }, balance.SessionOpen, 60, 0, 1},
+		{"update", func() error { return s.Update("", balance.Units{Time: 30}, balance.Units{Time: 45}) }, balance.SessionOpen, 30, 45, 2},
+		{"terminate", func() error { return s.Terminate("", balance.Units{Time: 10}) }, balance.SessionClosed, 0, 55, 3},
+	} {
+		if err := step.do(); err != nil {
+			t.Fatal(step.name, ": ", err)
+		}
+		if s.State != step.state || s.Granted.Time != step.granted || s.Used.Time != step.used || s.RequestNumber != step.number {
+			t.Errorf("Unexpected session after %s: %+v", step.name, s)
+		}
+	}
+	if s.ValidityTime != sessionValidity {
+		t.Error("It should keep the Validity-Time but was ", s.ValidityTime)
+	}
+	if err := s.Update("", balance.Units{}, balance.Units{}); err != balance.ErrSessionClosed {
+		t.Error("It should refuse to update a closed session but was ", err)
+	}
+	if err := s.Terminate("", balance.Units{}); err != balance.ErrSessionClosed {
+		t.Error("It should refuse to terminate a closed session but was ", err)
+	}
+
+	ccrs := sentCCRs()
+	if len(ccrs) != 3 {
+		t.Fatal("It should send 3 CCRs but sent ", len(ccrs))
+	}
+	for i, r := range ccrs {
+		if r.SessionID != s.ID || r.CCRequestType != i+1 || r.CCRequestNumber != i {
+			t.Errorf("Unexpected CCR %d: %+v", i, r)
+		}
+	}
+}
+
+func TestSessionPeerDownKeepsRequestNumber(t *testing.T) {
+	s := &balance.Session{Corp: "dtac", Subscriber: "66812345678", ServiceContextID: "32251@3gpp.org"}
+	if err := balance.OpenSession("", s, balance.Units{Time: 60}); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Terminate("", balance.Units{})
+
+	resetSent()
+	s.Corp = "down"
+	if err := s.Update("", balance.Units{Time: 60}, balance.Units{Time: 30}); err != balance.ErrNoPeer {
+		t.Error("It should be ErrNoPeer but was ", err)
+	}
+	if s.RequestNumber != 1 {
+		t.Error("It should keep the request number at 1 but was ", s.RequestNumber)
+	}
+
+	s.Corp = "dtac"
+	if err := s.Update("", balance.Units{Time: 60}, balance.Units{Time: 30}); err != nil {
+		t.Fatal(err)
+	}
+	ccrs := sentCCRs()
+	if len(ccrs) != 1 || ccrs[0].CCRequestNumber != 1 {
+		t.Error("It should send the update with CC-Request-Number 1 but sent ", ccrs)
+	}
+}
+
+func TestOpenSessionUnknownSubscriber(t *testing.T) {
+	s := &balance.Session{Corp: "dtac", Subscriber: "66811111111", ServiceContextID: "32251@3gpp.org"}
+	err := balance.OpenSession("", s, balance.Units{Time: 60})
+	if re, ok := err.(balance.ResultError); !ok || re.Code != balance.UserUnknown {
+		t.Error("It should be a user unknown error but was ", err)
+	}
+	if s.State != balance.SessionClosed {
+		t.Error("It should close the session but was ", s.State)
+	}
+	if resp, _ := send(t, "GET", "/sessions/"+s.ID, ""); resp.StatusCode != http.StatusNotFound {
+		t.Error("It should not keep the session but was ", resp.StatusCode)
+	}
+}
+
+func TestSessionHandlers(t *testing.T) {
+	resp, body := send(t, "POST", "/sessions/dtac/66812345678", `{"ratingGroup": 10, "requested": {"time": 60}}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatal("It should be 201 but was ", resp.StatusCode, string(body))
+	}
+	var s balance.Session
+	if err := json.Unmarshal(body, &s); err != nil {
+		t.Fatal(err)
+	}
+	path := "/sessions/" + s.ID
+
+	for _, tt := range []struct {
+		method, path, body string
+		status             int
+		state              string
+		used               uint32
+	}{
+		{"GET", path, "", http.StatusOK, balance.SessionOpen, 0},
+		{"PUT", path, `{"requested": {"time": 30}, "used": {"time": 45}}`, http.StatusOK, balance.SessionOpen, 45},
+		{"PUT", path, `{bad json`, http.StatusBadRequest, "", 0},
+		{"DELETE", path, `{"used": {"time": 10}}`, http.StatusOK, balance.SessionClosed, 55},
+		{"GET", path, "", http.StatusNotFound, "", 0},
+		{"PUT", path, `{}`, http.StatusNotFound, "", 0},
+		{"DELETE", path, "", http.StatusNotFound, "", 0},
+		{"POST", "/sessions/dtac/66811111111", `{"requested": {"time": 60}}`, http.StatusNotFound, "", 0},
+	} {
+		resp, body := send(t, tt.method, tt.path, tt.body)
+		if resp.StatusCode != tt.status {
+			t.Error("It should be ", tt.status, " for "+tt.method+" "+tt.path+" but was ", resp.StatusCode, string(body))
+			continue
+		}
+		if tt.state == "" {
+			continue
+		}
+		var got balance.Session
+		json.Unmarshal(body, &got)
+		if got.State != tt.state || got.Used.Time != tt.used {
+			t.Errorf("Unexpected session after %s: %+v", tt.method, &got)
+		}
+	}
+}
+
+func TestCharge(t *testing.T) {
+	const amount = `{"amount": {"value": 1250, "exponent": -2, "currency": 764}}`
+	for _, tt := range []struct {
+		name, path, key, body string
+		status                int
+		resultCode            uint32
+		replayed              bool
+	}{
+		{"debit", "/debit/dtac/66812345678", "d1", amount, http.StatusOK, diam.Success, false},
+		{"debit again", "/debit/dtac/66812345678", "d1", amount, http.StatusOK, diam.Success, true},
+		{"refund", "/refund/dtac/66812345678", "r1", amount, http.StatusOK, diam.Success, false},
+		{"refund on a debit key", "/refund/dtac/66812345678", "d1", amount, http.StatusUnprocessableEntity, 0, false},
+		{"other amount", "/debit/dtac/66812345678", "d1", `{"amount": {"value": 1, "currency": 764}}`, http.StatusUnprocessableEntity, 0, false},
+		{"unknown subscriber", "/debit/dtac/66811111111", "d2", amount, http.StatusNotFound, balance.UserUnknown, false},
+		{"no key", "/debit/dtac/66812345678", "", amount, http.StatusBadRequest, 0, false},
+		{"no amount", "/debit/dtac/66812345678", "d3", `{"amount": {"currency": 764}}`, http.StatusBadRequest, 0, false},
+	} {
+		resetSent()
+		var headers []string
+		if tt.key != "" {
+			headers = []string{balance.IdempotencyHeader, tt.key}
+		}
+		resp, body := send(t, "POST", tt.path, tt.body, headers...)
+		if resp.StatusCode != tt.status {
+			t.Error("It should be ", tt.status, " for "+tt.name+" but was ", resp.StatusCode, string(body))
+			continue
+		}
+		if replayed := resp.Header.Get("Idempotent-Replayed") == "true"; replayed != tt.replayed {
+			t.Error("It should be replayed ", tt.replayed, " for "+tt.name+" but was ", replayed)
+		}
+		if replayed := len(sentCCRs()) == 0; tt.resultCode != 0 && replayed != tt.replayed {
+			t.Error("It should send a CCR for "+tt.name+" unless replayed but sent ", len(sentCCRs()))
+		}
+		if tt.resultCode == 0 {
+			continue
+		}
+		var res balance.ChargeResult
+		json.Unmarshal(body, &res)
+		if res.ResultCode != tt.resultCode || res.SessionID == "" {
+			t.Errorf("Unexpected result for %s: %+v", tt.name, res)
+		}
+	}
+}
+
+func TestPrice(t *testing.T) {
+	for _, tt := range []struct {
+		query  string
+		status int
+		value  int64
+	}{
+		{"?serviceIdentifier=7&ratingGroup=10&units=4", http.StatusOK, 100},
+		{"?serviceIdentifier=7&units=1", http.StatusOK, 25},
+		{"?units=1", http.StatusBadRequest, 0},
+		{"?serviceIdentifier=7&ratingGroup=x", http.StatusBadRequest, 0},
+		{"?serviceIdentifier=7&units=-1", http.StatusBadRequest, 0},
+	} {
+		resp, body := get(t, "/price/dtac/66812345678"+tt.query)
+		if resp.StatusCode != tt.status {
+			t.Error("It should be ", tt.status, " for "+tt.query+" but was ", resp.StatusCode, string(body))
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var p balance.Price
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Fatal(err)
+		}
+		if p.ResultCode != diam.Success || p.Cost == nil || p.Cost.Value != tt.value || p.Cost.Exponent != -2 || p.Cost.Currency != 764 {
+			t.Error("It should cost ", tt.value, " for "+tt.query+" but was ", p.ResultCode, p.Cost)
+		}
+	}
+
+	if resp, _ := get(t, "/price/dtac/66811111111?serviceIdentifier=7"); resp.StatusCode != http.StatusNotFound {
+		t.Error("It should be 404 for an unknown subscriber but was ", resp.StatusCode)
+	}
+}
+
+// openSession opens a session for 66812345678 through the API and
+// returns its id.
+func openSession(t *testing.T) string {
+	resp, body := send(t, "POST", "/sessions/dtac/66812345678", `{"requested": {"time": 60}}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatal("It should be 201 but was ", resp.StatusCode, string(body))
+	}
+	var s balance.Session
+	if err := json.Unmarshal(body, &s); err != nil {
+		t.Fatal(err)
+	}
+	return s.ID
+}
+
+// waitCCR waits for a CCR of requestType on sessionID.
+func waitCCR(t *testing.T, sessionID string, requestType int) sentCCR {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, r := range sentCCRs() {
+			if r.SessionID == sessionID && r.CCRequestType == requestType {
+				return r
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("It should send a CCR of type ", requestType, " for ", sessionID)
+	return sentCCR{}
+}
+
+// notifications collects the notifications until the returned function
+// is called.
+func notifications() (chan balance.Notification, func()) {
+	ch := make(chan balance.Notification, 10)
+	notesLock.Lock()
+	notes = ch
+	notesLock.Unlock()
+	return ch, func() {
+		notesLock.Lock()
+		notes = nil
+		notesLock.Unlock()
+	}
+}
+
+func TestServerRequests(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		send    func(sessionID string) (uint32, error)
+		notify  string
+		request int
+		// status and number are what GET answers for the session once
+		// the CCR that follows is answered.
+		status int
+		number uint32
+	}{
+		{"RAR", ocs.ReAuth, balance.NotifyReAuth, 2, http.StatusOK, 2},
+		{"ASR", ocs.Abort, balance.NotifyAbort, 3, http.StatusNotFound, 0},
+	} {
+		id := openSession(t)
+		resetSent()
+		notes, stop := notifications()
+
+		code, err := tt.send(id)
+		if err != nil {
+			t.Fatal(tt.name, ": ", err)
+		}
+		if code != diam.Success {
+			t.Error("It should answer the "+tt.name+" with 2001 but was ", code)
+		}
+		select {
+		case n := <-notes:
+			if n.Type != tt.notify || n.SessionID != id || n.Corp != "dtac" || n.Subscriber != "66812345678" {
+				t.Errorf("Unexpected notification for %s: %+v", tt.name, n)
+			}
+		case <-time.After(time.Second):
+			t.Error("It should notify the " + tt.name)
+		}
+		stop()
+
+		r := waitCCR(t, id, tt.request)
+		if r.CCRequestNumber != 1 {
+			t.Error("It should follow the "+tt.name+" with CC-Request-Number 1 but was ", r.CCRequestNumber)
+		}
+		// The CCA is applied once the CCR is answered.
+		deadline := time.Now().Add(time.Second)
+		for {
+			resp, body := send(t, "GET", "/sessions/"+id, "")
+			var s balance.Session
+			json.Unmarshal(body, &s)
+			if resp.StatusCode == tt.status && s.RequestNumber == tt.number {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Error("It should answer ", tt.status, " for the session after the "+tt.name+" but was ", resp.StatusCode, string(body))
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if tt.status == http.StatusOK {
+			send(t, "DELETE", "/sessions/"+id, "")
+		}
+	}
+}
+
+func TestReAuthReportsUsed(t *testing.T) {
+	id := openSession(t)
+	defer send(t, "DELETE", "/sessions/"+id, "")
+	if resp, body := send(t, "PUT", "/sessions/"+id, `{"requested": {"time": 30}, "used": {"time": 45}}`); resp.StatusCode != http.StatusOK {
+		t.Fatal("It should be 200 but was ", resp.StatusCode, string(body))
+	}
+	resetSent()
+
+	if _, err := ocs.ReAuth(id); err != nil {
+		t.Fatal(err)
+	}
+	r := waitCCR(t, id, 2)
+	if r.CCRequestNumber != 2 || r.MSCC.Used.Time != 45 {
+		t.Error("It should report the 45s used with CC-Request-Number 2 but was ", r.MSCC.Used.Time, r.CCRequestNumber)
+	}
+	// The used units are reported again, not added again.
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, body := send(t, "GET", "/sessions/"+id, "")
+		var s balance.Session
+		json.Unmarshal(body, &s)
+		if s.RequestNumber == 3 {
+			if s.Used.Time != 45 {
+				t.Error("It should keep 45s used but was ", s.Used.Time)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("It should apply the re-auth CCA but was ", string(body))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerRequestUnknownSession(t *testing.T) {
+	notes, stop := notifications()
+	defer stop()
+	for name, send := range map[string]func(string) (uint32, error){"RAR": ocs.ReAuth, "ASR": ocs.Abort} {
+		code, err := send("dtac.co.th;OMR;unknown")
+		if err != nil {
+			t.Fatal(name, ": ", err)
+		}
+		if code != balance.UnknownSessionID {
+			t.Error("It should answer the "+name+" with 5002 but was ", code)
+		}
+	}
+	select {
+	case n := <-notes:
+		t.Error("It should not notify unknown sessions but sent ", n)
+	default:
+	}
+}
+
+// adminToken authenticates the /admin/ endpoints in the tests.
+const adminToken = "secret"
+
+// rawBody is a raw Credit-Control event request for subr, with extra
+// top-level AVPs.
+func rawBody(subr, extra string) string {
+	return `{"avps": [` + extra + `
+		{"name": "Service-Context-Id", "value": "QueryBalance@huawei.com"},
+		{"name": "CC-Request-Type", "value": "EVENT_REQUEST"},
+		{"name": "CC-Request-Number", "value": "0"},
+		{"name": "Subscription-Id", "avps": [
+			{"name": "Subscription-Id-Type", "value": "0"},
+			{"name": "Subscription-Id-Data", "value": "` + subr + `"}
+		]}
+	]}`
+}
+
+func TestPostRaw(t *testing.T) {
+	auth := "Bearer " + adminToken
+	for _, tt := range []struct {
+		name   string
+		body   string
+		token  string
+		status int
+		failed bool
+	}{
+		{"a known subscriber", rawBody("66812345678", ""), auth, http.StatusOK, false},
+		{"an unknown subscriber", rawBody("66811111111", ""), auth, http.StatusOK, true},
+		{"a Session-Id", rawBody("66812345678", `{"name": "Session-Id", "value": "dtac.co.th;x"},`), auth, http.StatusBadRequest, false},
+		{"another command", `{"command": "Device-Watchdog"}`, auth, http.StatusBadRequest, false},
+		{"no token", rawBody("66812345678", ""), "", http.StatusUnauthorized, false},
+	} {
+		resp, body := send(t, "POST", "/admin/raw", tt.body, "Authorization", tt.token)
+		if resp.StatusCode != tt.status {
+			t.Error("It should be ", tt.status, " for "+tt.name+" but was ", resp.StatusCode, string(body))
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var r balance.RawResponse
+		if err := json.Unmarshal(body, &r); err != nil {
+			t.Fatal(err)
+		}
+		if r.SessionID == "" || r.Answer == nil || r.Answer.Command != "Credit-Control" {
+			t.Errorf("Unexpected answer for %s: %+v", tt.name, r)
+			continue
+		}
+		if (r.Error != "") != tt.failed {
+			t.Error("Unexpected error for "+tt.name+": ", r.Error)
+		}
+		for _, a := range r.Answer.AVPs {
+			if a.Name == "Session-Id" && a.Value != r.SessionID {
+				t.Error("It should answer the assigned Session-Id but was ", a.Value)
+			}
+		}
+	}
+}
+
+func TestStrictValidationRefusesRequest(t *testing.T) {
+	diameter.SetValidation(diameter.ValidateStrict)
+	defer diameter.SetValidation(diameter.ValidateOff)
+	resetSent()
+
+	// CC-Request-Type is required in a CCR.
+	body := `{"avps": [
+		{"name": "Service-Context-Id", "value": "QueryBalance@huawei.com"},
+		{"name": "CC-Request-Number", "value": "0"}
+	]}`
+	resp, b := send(t, "POST", "/admin/raw", body, "Authorization", "Bearer "+adminToken)
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Error("It should be 500 but was ", resp.StatusCode, string(b))
+	}
+	if n := len(sentCCRs()); n != 0 {
+		t.Error("It should not send the invalid CCR but sent ", n)
+	}
+}
+
+// busySubscriber is answered DIAMETER_TOO_BUSY, which throttles the
+// peer for busyBackoff.
+const (
+	busySubscriber = "66830040000"
+	busyBackoff    = 200 * time.Millisecond
+)
+
+func TestTooBusy(t *testing.T) {
+	defer time.Sleep(busyBackoff)
+
+	for _, tt := range []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		sendsCCR bool
+	}{
+		{"a 3004 answer", "GET", "/balance/dtac/" + busySubscriber, "", true},
+		{"a throttled peer", "GET", "/balance/dtac/66812345678", "", false},
+	} {
+		resetSent()
+		resp, body := send(t, tt.method, tt.path, tt.body)
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Error("It should be 429 for "+tt.name+" but was ", resp.StatusCode, string(body))
+		}
+		if v := resp.Header.Get("Retry-After"); v != "1" {
+			t.Error("It should retry after 1s for "+tt.name+" but was ", v)
+		}
+		if sent := len(sentCCRs()) > 0; sent != tt.sendsCCR {
+			t.Error("It should send a CCR for "+tt.name+" ", tt.sendsCCR, " but was ", sent)
+		}
+	}
+
+	time.Sleep(busyBackoff)
+	if resp, body := get(t, "/balance/dtac/66812345678"); resp.StatusCode != http.StatusOK {
+		t.Error("It should take CCRs again after the backoff but was ", resp.StatusCode, string(body))
+	}
+}
+
+func TestPostRawTooBusy(t *testing.T) {
+	defer time.Sleep(busyBackoff)
+
+	resp, body := send(t, "POST", "/admin/raw", rawBody(busySubscriber, ""), "Authorization", "Bearer "+adminToken)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Error("It should be 429 but was ", resp.StatusCode, string(body))
+	}
+	if v := resp.Header.Get("Retry-After"); v != "1" {
+		t.Error("It should retry after 1s but was ", v)
+	}
+	var r balance.RawResponse
+	json.Unmarshal(body, &r)
+	if r.Answer == nil || r.Error == "" {
+		t.Errorf("It should return the answer and the error but was %+v", r)
+	}
+}
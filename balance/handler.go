@@ -1,7 +1,7 @@
 package balance
 
 import (
-	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/fiorix/go-diameter/diam"
@@ -9,78 +9,108 @@ import (
 	"github.com/fiorix/go-diameter/diam/datatype"
 
 	"github.com/ant0ine/go-json-rest/rest"
-)
 
-const (
-	BalanceInformation  = 21100
-	AccessMethod        = 20340
-	AccountQueryMethod  = 20346
-	SSPTime             = 20386
-	CallingPartyAddress = 20336
+	"server/avps"
+	"server/diameter"
+	"server/logger"
 )
 
-var response map[string]chan BalanceInfo
-
+// BalanceInfo is the QueryBalance CCA. Balance-Information is
+// generated from the dictionary; see package avps. Offer-Information
+// holds every Offer-Info of the answer, and the JSON carries ShareFlag,
+// OfferId and ExpireTime along with the fields read before.
 type BalanceInfo struct {
 	SessionId          string `avp:"Session-Id"`
 	ServiceInformation struct {
-		BalanceInformation struct {
-			FirstActiveDate   string `avp:"First-Active-Date"`
-			SubscriberState   int    `avp:"Subscriber-State"`
-			ActivePeriod      string `avp:"Active-Period"`
-			GracePeriod       string `avp:"Grace-Period"`
-			DisablePeriod     string `avp:"Disable-Period"`
-			Balance           int    `avp:"Balance"`
-			LanguageIVR       int    `avp:"Language-IVR"`
-			LanguageSMS       int    `avp:"Language-SMS"`
-			LanguageUSSD      int    `avp:"Language-USSD"`
-			AccountChangeInfo []struct {
-				AccountId             string `avp:"Account-Id"`
-				AccountType           int    `avp:"Account-Type"`
-				AccountTypeDesc       string `avp:"Account-Type-Desc"`
-				AccountBeginDate      string `avp:"Account-Begin-Date"`
-				RelatedType           int    `avp:"Related-Type"`
-				RelatedObjectID       string `avp:"Related-Object-ID"`
-				CurrentAccountBalance int    `avp:"Current-Account-Balance"`
-				AccountEndDate        string `avp:"Account-End-Date"`
-				MeasureType           int    `avp:"Measure-Type"`
-			} `avp:"Account-Change-Info"`
-			OfferInformation []struct {
-				OfferInfo []struct {
-					OfferOrderKey            string `avp:"Offer-Order-Key"`
-					EffectiveTime            string `avp:"Effective-Time"`
-					Status                   string `avp:"Status"`
-					CurrentCycle             int    `avp:"Current-Cycle"`
-					TotalCycle               int    `avp:"Total-Cycle"`
-					OfferOrderIntegrationKey string `avp:"Offer-Order-Integration-Key"`
-					ExternalOfferCode        string `avp:"External-Offer-Code"`
-				} `avp:"Offer-Info"`
-			} `avp:"Offer-Information,omitempty"`
-		} `avp:"Balance-Information"`
+		BalanceInformation avps.BalanceInformationGroup `avp:"Balance-Information"`
 	} `avp:"Service-Information"`
+
+	// mapped holds the fields of a configured mapping, which replace
+	// the layout above in JSON.
+	mapped map[string]interface{}
 }
 
 func Balance(w rest.ResponseWriter, req *rest.Request) {
 	corp := req.PathParam("corp")
 	subr := req.PathParam("subr")
-	r := diam.NewRequest(diam.CreditControl, 4, nil)
 
-	sessionID := "dtac.co.th;OMR" + time.Now().Format("200601021504050000")
+	reqID := logger.RequestID(req)
+	resp, how, err := balances.get(reqID, corp, subr, noCache(req.Request))
+	if err != nil {
+		logger.Error("balance query failed", "request_id", reqID, "corp", corp, "err", err)
+		httpError(w, err)
+		return
+	}
+
+	w.Header().Set("X-Cache", how)
+	w.WriteJson(resp)
+}
+
+// errorStatus maps a query error to the HTTP status returned to the
+// client.
+func errorStatus(err error) int {
+	switch err {
+	case ErrNoPeer:
+		return http.StatusServiceUnavailable
+	case ErrTimeout:
+		return http.StatusGatewayTimeout
+	case ErrTemplate:
+		return http.StatusInternalServerError
+	}
+	if _, ok := err.(*BusyError); ok {
+		return http.StatusTooManyRequests
+	}
+	if re, ok := err.(ResultError); ok && re.Code == UserUnknown {
+		return http.StatusNotFound
+	}
+	if ve, ok := err.(*diameter.ValidationError); ok && ve.Request {
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadGateway
+}
+
+// CC-Request-Type values (RFC 4006).
+const (
+	InitialRequest     = 1
+	UpdateRequest      = 2
+	TerminationRequest = 3
+	EventRequest       = 4
+)
+
+// Requested-Action values (RFC 4006).
+const (
+	DirectDebiting = 0
+	RefundAccount  = 1
+	CheckBalance   = 2
+	PriceEnquiry   = 3
+)
+
+// newCCR starts a CCR of requestType for subr with the AVPs shared by
+// every request we send.
+func newCCR(sessionID, subr string, requestType int32) *diam.Message {
+	r := diam.NewRequest(diam.CreditControl, 4, nil)
 
 	r.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String(sessionID))
 	r.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(4))
 	r.NewAVP(avp.DestinationRealm, avp.Mbit, 0, datatype.OctetString("www.huawei.com")) //peerRealm.Data)
 	r.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.OctetString("jenkin13_OMR_TEST01"))  //identity)
 	r.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.OctetString("dtac.co.th"))          //realm)
-	r.NewAVP(avp.CCRequestType, avp.Mbit, 0, datatype.Integer32(4))
+	r.NewAVP(avp.CCRequestType, avp.Mbit, 0, datatype.Integer32(requestType))
 	r.NewAVP(avp.SubscriptionID, avp.Mbit, 0, &diam.GroupedAVP{
 		AVP: []*diam.AVP{
 			diam.NewAVP(avp.SubscriptionIDType, avp.Mbit, 0, datatype.Integer32(0)),
 			diam.NewAVP(avp.SubscriptionIDData, avp.Mbit, 0, datatype.UTF8String(subr)),
 		},
 	})
+
+	return r
+}
+
+// newBalanceRequest builds the Huawei QueryBalance CCR for subr.
+func newBalanceRequest(sessionID, subr string) *diam.Message {
+	r := newCCR(sessionID, subr, EventRequest)
 	r.NewAVP(avp.ServiceContextID, avp.Mbit, 0, datatype.UTF8String("QueryBalance@huawei.com"))
-	r.NewAVP(avp.RequestedAction, avp.Mbit, 0, datatype.Integer32(2))
+	r.NewAVP(avp.RequestedAction, avp.Mbit, 0, datatype.Integer32(CheckBalance))
 	r.NewAVP(avp.EventTimestamp, avp.Mbit, 0, datatype.Time(time.Now()))
 	r.NewAVP(avp.ServiceIdentifier, avp.Mbit, 0, datatype.Unsigned32(0))
 	r.NewAVP(avp.CCRequestNumber, avp.Mbit, 0, datatype.Unsigned32(0))
@@ -88,31 +118,16 @@ func Balance(w rest.ResponseWriter, req *rest.Request) {
 	r.NewAVP(avp.DestinationHost, avp.Mbit, 0, datatype.OctetString("cbp211"))
 	r.NewAVP(avp.ServiceInformation, avp.Mbit, 0, &diam.GroupedAVP{
 		AVP: []*diam.AVP{
-			diam.NewAVP(BalanceInformation, avp.Mbit, 0, &diam.GroupedAVP{
+			diam.NewAVP(avps.BalanceInformation, avp.Mbit, 0, &diam.GroupedAVP{
 				AVP: []*diam.AVP{
-					diam.NewAVP(CallingPartyAddress, avp.Mbit, 0, datatype.UTF8String(subr)),
-					diam.NewAVP(AccessMethod, avp.Mbit, 0, datatype.Unsigned32(9)),
-					diam.NewAVP(AccountQueryMethod, avp.Mbit, 0, datatype.Unsigned32(1)),
-					diam.NewAVP(SSPTime, avp.Mbit, 0, datatype.Time(time.Now())),
+					diam.NewAVP(avps.CallingPartyAddress, avp.Mbit, 0, datatype.UTF8String(subr)),
+					diam.NewAVP(avps.AccessMethod, avp.Mbit, 0, datatype.Unsigned32(9)),
+					diam.NewAVP(avps.AccountQueryMethod, avp.Mbit, 0, datatype.Unsigned32(1)),
+					diam.NewAVP(avps.SSPTime, avp.Mbit, 0, datatype.Time(time.Now())),
 				},
 			}),
 		},
 	})
 
-	var err error
-
-	response[sessionID] = make(chan BalanceInfo)
-	if corp == "dtac" {
-		_, err = r.WriteTo(cdtac)
-	} else {
-		_, err = r.WriteTo(cdtn)
-	}
-
-	if err != nil {
-		fmt.Println(err.Error())
-	}
-
-	resp := <-response[sessionID]
-
-	w.WriteJson(resp)
+	return r
 }
@@ -0,0 +1,30 @@
+package balance
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"server/diameter"
+)
+
+func TestErrorStatus(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want int
+	}{
+		{ErrNoPeer, http.StatusServiceUnavailable},
+		{ErrTimeout, http.StatusGatewayTimeout},
+		{ErrTemplate, http.StatusInternalServerError},
+		{busy(limitBusy, 0), http.StatusTooManyRequests},
+		{ResultError{UserUnknown}, http.StatusNotFound},
+		{ResultError{4012}, http.StatusBadGateway},
+		{&diameter.ValidationError{Request: true}, http.StatusInternalServerError},
+		{&diameter.ValidationError{}, http.StatusBadGateway},
+		{errors.New("decode failed"), http.StatusBadGateway},
+	} {
+		if s := errorStatus(tt.err); s != tt.want {
+			t.Error("It should be ", tt.want, " for ", tt.err, " but was ", s)
+		}
+	}
+}
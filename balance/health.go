@@ -0,0 +1,82 @@
+package balance
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+
+	"server/diameter"
+)
+
+type PeerHealth struct {
+	diameter.PeerStatus
+	Pending int `json:"pending"`
+}
+
+type Readiness struct {
+	Ready bool         `json:"ready"`
+	Peers []PeerHealth `json:"peers"`
+}
+
+// readiness reports the state of every configured peer. The service is
+// ready when each corp has at least one peer that completed CER/CEA and
+// whose watchdog is OKAY.
+func readiness() Readiness {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	names := make([]string, 0, len(corps))
+	for name := range corps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	r := Readiness{Ready: len(names) > 0, Peers: []PeerHealth{}}
+	for _, name := range names {
+		up := false
+		for _, p := range corps[name] {
+			st := diameter.Status(p.addr)
+			st.Corp = name
+			if st.State == diameter.StateOkay {
+				up = true
+			}
+			r.Peers = append(r.Peers, PeerHealth{
+				PeerStatus: st,
+				Pending:    len(p.inflight),
+			})
+		}
+		if !up {
+			r.Ready = false
+		}
+	}
+	return r
+}
+
+// WaitReady waits up to timeout for a peer of corp to complete CER/CEA.
+func WaitReady(corp string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if p := peerFor(corp); p != nil && p.Conn() != nil && diameter.Status(p.addr).State == diameter.StateOkay {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrNoPeer
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Healthz reports that the process is alive.
+func Healthz(w rest.ResponseWriter, req *rest.Request) {
+	w.WriteJson(map[string]string{"status": "ok"})
+}
+
+// Readyz answers 503 until every corp has a usable peer.
+func Readyz(w rest.ResponseWriter, req *rest.Request) {
+	r := readiness()
+	if !r.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.WriteJson(r)
+}
@@ -0,0 +1,35 @@
+package balance
+
+import (
+	"testing"
+
+	"server/diameter"
+)
+
+func TestReadinessWithoutCEA(t *testing.T) {
+	saved := corps
+	defer func() { corps = saved }()
+	corps = map[string][]*peer{
+		"dtac": {newPeer("127.0.0.1:6553")},
+	}
+
+	r := readiness()
+	if r.Ready {
+		t.Error("It should not be ready before CER/CEA")
+	}
+	if len(r.Peers) != 1 {
+		t.Fatal("It should list 1 peer but listed ", len(r.Peers))
+	}
+	if r.Peers[0].Corp != "dtac" || r.Peers[0].State != diameter.StateClosed {
+		t.Error("Unexpected peer ", r.Peers[0])
+	}
+}
+
+func TestReadinessWithoutCorps(t *testing.T) {
+	saved := corps
+	defer func() { corps = saved }()
+	corps = nil
+	if readiness().Ready {
+		t.Error("It should not be ready without corps")
+	}
+}
@@ -0,0 +1,125 @@
+package balance
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// IdempotencyHeader carries the client's key for a debit or refund.
+// Retries with the same key return the first result instead of
+// charging again.
+const IdempotencyHeader = "Idempotency-Key"
+
+// idempotencyTTL is how long the result of a key is remembered.
+const idempotencyTTL = 24 * time.Hour
+
+var ErrIdempotencyMismatch = errors.New("balance: idempotency key reused with a different request")
+
+// chargeCall is a debit or refund made under one idempotency key.
+type chargeCall struct {
+	done      chan struct{}
+	action    int32
+	body      ChargeRequest
+	sessionID string
+	// attempts counts the CCRs written to a peer; endToEnd is the
+	// End-to-End Id of the first one.
+	attempts int
+	endToEnd uint32
+	result   ChargeResult
+	err      error
+	created  time.Time
+}
+
+// attempt is one try at sending a chargeCall.
+type attempt struct {
+	sessionID string
+	// retry is set when an earlier CCR of the call was written; the
+	// retransmission must carry its endToEnd.
+	retry    bool
+	endToEnd uint32
+	// sent is set, with endToEnd, once the CCR is written.
+	sent bool
+}
+
+// final reports whether the outcome of the call is known, so that it
+// can be returned to retries. A call that timed out may or may not
+// have been charged and is retried as a retransmission; one that
+// never reached a peer or was refused as too busy is simply retried.
+func (c *chargeCall) final() bool {
+	_, busy := c.err.(*BusyError)
+	return c.err != ErrTimeout && c.err != ErrNoPeer && !busy
+}
+
+type idempotency struct {
+	mu    sync.Mutex
+	calls map[string]*chargeCall
+}
+
+var charges = &idempotency{calls: make(map[string]*chargeCall)}
+
+// do runs send once per key. A later call with the same key waits for
+// the first one and returns its result, with replayed set. The
+// Session-Id and End-to-End Id of the first attempt are kept for
+// retries.
+func (i *idempotency) do(key string, action int32, body ChargeRequest, send func(a *attempt) (ChargeResult, error)) (ChargeResult, bool, error) {
+	i.mu.Lock()
+	c, ok := i.calls[key]
+	if ok {
+		i.mu.Unlock()
+		<-c.done
+		if c.action != action || c.body != body {
+			return ChargeResult{}, false, ErrIdempotencyMismatch
+		}
+		if c.final() {
+			return c.result, true, c.err
+		}
+		i.mu.Lock()
+		// Another retry may already have replaced the call.
+		if cur := i.calls[key]; cur != c {
+			i.mu.Unlock()
+			return i.do(key, action, body, send)
+		}
+		c = &chargeCall{sessionID: c.sessionID, attempts: c.attempts, endToEnd: c.endToEnd}
+	} else {
+		c = &chargeCall{sessionID: newSessionID()}
+	}
+	c.done = make(chan struct{})
+	c.action = action
+	c.body = body
+	c.created = time.Now()
+	i.calls[key] = c
+	i.mu.Unlock()
+
+	a := &attempt{sessionID: c.sessionID, retry: c.attempts > 0, endToEnd: c.endToEnd}
+	c.result, c.err = send(a)
+	if a.sent {
+		if c.attempts == 0 {
+			c.endToEnd = a.endToEnd
+		}
+		c.attempts++
+	}
+	close(c.done)
+	return c.result, false, c.err
+}
+
+// sweep forgets keys older than idempotencyTTL.
+func (i *idempotency) sweep() {
+	i.mu.Lock()
+	for k, c := range i.calls {
+		select {
+		case <-c.done:
+			if time.Since(c.created) >= idempotencyTTL {
+				delete(i.calls, k)
+			}
+		default:
+		}
+	}
+	i.mu.Unlock()
+}
+
+func (i *idempotency) expire() {
+	for range time.Tick(time.Minute) {
+		i.sweep()
+	}
+}
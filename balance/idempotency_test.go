@@ -0,0 +1,127 @@
+package balance
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyReplay(t *testing.T) {
+	i := &idempotency{calls: make(map[string]*chargeCall)}
+	body := ChargeRequest{Amount: Money{Value: 100, Currency: 764}}
+
+	var n int32
+	send := func(a *attempt) (ChargeResult, error) {
+		atomic.AddInt32(&n, 1)
+		a.sent = true
+		return ChargeResult{SessionID: a.sessionID}, nil
+	}
+
+	var wg sync.WaitGroup
+	for k := 0; k < 10; k++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			i.do("dtac/0812345678/k1", DirectDebiting, body, send)
+		}()
+	}
+	wg.Wait()
+
+	_, replayed, err := i.do("dtac/0812345678/k1", DirectDebiting, body, send)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !replayed {
+		t.Error("It should be replayed")
+	}
+	if n != 1 {
+		t.Error("It should charge once but charged ", n)
+	}
+}
+
+func TestIdempotencyMismatch(t *testing.T) {
+	i := &idempotency{calls: make(map[string]*chargeCall)}
+	send := func(a *attempt) (ChargeResult, error) {
+		a.sent = true
+		return ChargeResult{}, nil
+	}
+
+	i.do("k", DirectDebiting, ChargeRequest{Amount: Money{Value: 100}}, send)
+	_, _, err := i.do("k", DirectDebiting, ChargeRequest{Amount: Money{Value: 200}}, send)
+	if err != ErrIdempotencyMismatch {
+		t.Error("It should be ErrIdempotencyMismatch but was ", err)
+	}
+	_, _, err = i.do("k", RefundAccount, ChargeRequest{Amount: Money{Value: 100}}, send)
+	if err != ErrIdempotencyMismatch {
+		t.Error("It should be ErrIdempotencyMismatch but was ", err)
+	}
+}
+
+func TestIdempotencyRetransmitAfterTimeout(t *testing.T) {
+	i := &idempotency{calls: make(map[string]*chargeCall)}
+	body := ChargeRequest{Amount: Money{Value: 100}}
+
+	var sessions []string
+	var retries []bool
+	var endToEnds []uint32
+	send := func(a *attempt) (ChargeResult, error) {
+		sessions = append(sessions, a.sessionID)
+		retries = append(retries, a.retry)
+		endToEnds = append(endToEnds, a.endToEnd)
+		a.sent = true
+		if len(sessions) == 1 {
+			a.endToEnd = 42
+			return ChargeResult{}, ErrTimeout
+		}
+		return ChargeResult{SessionID: a.sessionID}, nil
+	}
+
+	if _, _, err := i.do("k", DirectDebiting, body, send); err != ErrTimeout {
+		t.Fatal("It should be ErrTimeout but was ", err)
+	}
+	_, replayed, err := i.do("k", DirectDebiting, body, send)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed {
+		t.Error("It should send again after a timeout")
+	}
+	if len(sessions) != 2 || sessions[0] != sessions[1] {
+		t.Error("It should reuse the Session-Id but sent ", sessions)
+	}
+	if retries[0] || !retries[1] {
+		t.Error("It should flag only the retry but flagged ", retries)
+	}
+	if endToEnds[1] != 42 {
+		t.Error("It should reuse the End-to-End Id of the first attempt but was ", endToEnds[1])
+	}
+}
+
+func TestIdempotencyUnsentIsNotRetransmitted(t *testing.T) {
+	i := &idempotency{calls: make(map[string]*chargeCall)}
+	body := ChargeRequest{Amount: Money{Value: 100}}
+
+	var retries []bool
+	errs := []error{ErrNoPeer, busy(limitOutstanding, time.Second), nil}
+	send := func(a *attempt) (ChargeResult, error) {
+		retries = append(retries, a.retry)
+		err := errs[len(retries)-1]
+		a.sent = err == nil
+		return ChargeResult{}, err
+	}
+
+	for _, want := range errs {
+		if _, _, err := i.do("k", DirectDebiting, body, send); err != want {
+			t.Fatal("It should be ", want, " but was ", err)
+		}
+	}
+	for n, r := range retries {
+		if r {
+			t.Error("It should not flag attempt ", n, " as a retransmission when nothing was sent")
+		}
+	}
+	if c := i.calls["k"]; c.attempts != 1 {
+		t.Error("It should count only the sent attempt but counted ", c.attempts)
+	}
+}
@@ -0,0 +1,203 @@
+package balance
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+
+	"server/auth"
+	"server/config"
+	"server/metrics"
+)
+
+// Limits a request can run into, as reported in BusyError and metrics.
+const (
+	limitClient      = "client"
+	limitCorp        = "corp"
+	limitOutstanding = "outstanding"
+	limitBusy        = "busy"
+)
+
+// outstandingRetry is the Retry-After sent when a peer has too many
+// CCRs outstanding.
+const outstandingRetry = time.Second
+
+// defaultBusyBackoff is used when no BusyBackoff is configured.
+const defaultBusyBackoff = time.Second
+
+var (
+	clientLimits = newLimiter(config.Rate{}, nil)
+	corpLimits   = newLimiter(config.Rate{}, nil)
+
+	// maxOutstanding caps the CCRs awaiting an answer on a peer
+	// connection dialed from now on.
+	maxOutstanding = 32
+	// busyBackoff is how long a peer that answered
+	// DIAMETER_TOO_BUSY takes no new CCRs.
+	busyBackoff = defaultBusyBackoff
+)
+
+// BusyError is returned when a request is refused to protect the OCS.
+// It is served as 429 with a Retry-After of RetryAfter.
+type BusyError struct {
+	Limit      string
+	RetryAfter time.Duration
+}
+
+func (e *BusyError) Error() string {
+	return fmt.Sprintf("balance: %s limit reached, retry after %s", e.Limit, e.RetryAfter)
+}
+
+// busy counts a request refused by limit and returns its error.
+func busy(limit string, retry time.Duration) *BusyError {
+	metrics.RateLimited.WithLabelValues(limit).Inc()
+	return &BusyError{Limit: limit, RetryAfter: retry}
+}
+
+// httpError writes err with the status errorStatus maps it to. A
+// BusyError also tells the client when to retry.
+func httpError(w rest.ResponseWriter, err error) {
+	setRetryAfter(w, err)
+	rest.Error(w, err.Error(), errorStatus(err))
+}
+
+func setRetryAfter(w rest.ResponseWriter, err error) {
+	if be, ok := err.(*BusyError); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(be.RetryAfter.Seconds()))))
+	}
+}
+
+// bucket is a token bucket.
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens earned since the last call.
+func (b *bucket) refill(now time.Time) {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// take removes a token and returns 0, or returns how long until one is
+// available.
+func (b *bucket) take(now time.Time) time.Duration {
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// limiter keeps a token bucket per key, with the rate of the key in
+// rates or def.
+type limiter struct {
+	def   config.Rate
+	rates map[string]config.Rate
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func newLimiter(def config.Rate, rates map[string]config.Rate) *limiter {
+	return &limiter{def: def, rates: rates, buckets: make(map[string]*bucket)}
+}
+
+// take returns 0 when key may make a request now, or how long it has
+// to wait.
+func (l *limiter) take(key string, now time.Time) time.Duration {
+	r, ok := l.rates[key]
+	if !ok {
+		r = l.def
+	}
+	if r.Rate <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		burst := float64(r.Burst)
+		if burst < 1 {
+			burst = math.Max(r.Rate, 1)
+		}
+		b = &bucket{rate: r.Rate, burst: burst, tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+	return b.take(now)
+}
+
+// sweep drops the buckets that are full again, which behave like new
+// ones.
+func (l *limiter) sweep(now time.Time) {
+	l.mu.Lock()
+	for k, b := range l.buckets {
+		if b.refill(now); b.tokens >= b.burst {
+			delete(l.buckets, k)
+		}
+	}
+	l.mu.Unlock()
+}
+
+func expireLimits() {
+	for now := range time.Tick(time.Minute) {
+		stateLock.RLock()
+		cl, co := clientLimits, corpLimits
+		stateLock.RUnlock()
+		cl.sweep(now)
+		co.sweep(now)
+	}
+}
+
+// setLimits installs the limits of cfg. stateLock must be held.
+func setLimits(cfg config.Limits) {
+	clientLimits = newLimiter(cfg.Client, cfg.Clients)
+	corpLimits = newLimiter(cfg.Corp, cfg.Corps)
+	if cfg.MaxOutstanding > 0 {
+		maxOutstanding = cfg.MaxOutstanding
+	}
+	busyBackoff = cfg.BusyBackoff.Duration
+	if busyBackoff <= 0 {
+		busyBackoff = defaultBusyBackoff
+	}
+}
+
+// LimitMiddleware applies the per-client rate to every request but the
+// health probes and the /admin/ endpoints. Clients are told apart by
+// their authenticated name, or by address while authentication is off.
+type LimitMiddleware struct{}
+
+func (mw *LimitMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, req *rest.Request) {
+		if unlimited(req.URL.Path) {
+			h(w, req)
+			return
+		}
+		key := auth.ClientName(req)
+		if key == "" {
+			key, _, _ = net.SplitHostPort(req.RemoteAddr)
+		}
+		stateLock.RLock()
+		l := clientLimits
+		stateLock.RUnlock()
+		if wait := l.take(key, time.Now()); wait > 0 {
+			httpError(w, busy(limitClient, wait))
+			return
+		}
+		h(w, req)
+	}
+}
+
+func unlimited(path string) bool {
+	return path == "/healthz" || path == "/readyz" || strings.HasPrefix(path, "/admin/")
+}
@@ -0,0 +1,138 @@
+package balance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+
+	"server/config"
+)
+
+func TestLimiterBucket(t *testing.T) {
+	l := newLimiter(config.Rate{Rate: 2, Burst: 3}, map[string]config.Rate{"batch": {Rate: 0}})
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if wait := l.take("crm", now); wait != 0 {
+			t.Fatal("It should allow the burst but waited ", wait)
+		}
+	}
+	if wait := l.take("crm", now); wait != 500*time.Millisecond {
+		t.Error("It should wait 500ms but waited ", wait)
+	}
+	if wait := l.take("crm", now.Add(500*time.Millisecond)); wait != 0 {
+		t.Error("It should refill 1 token in 500ms but waited ", wait)
+	}
+	if wait := l.take("other", now); wait != 0 {
+		t.Error("It should keep a bucket per key but waited ", wait)
+	}
+	for i := 0; i < 10; i++ {
+		if wait := l.take("batch", now); wait != 0 {
+			t.Fatal("It should not limit a zero rate but waited ", wait)
+		}
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l := newLimiter(config.Rate{Rate: 1}, nil)
+	now := time.Now()
+	l.take("crm", now)
+
+	l.sweep(now)
+	if len(l.buckets) != 1 {
+		t.Error("It should keep a bucket that is not full")
+	}
+	l.sweep(now.Add(time.Second))
+	if len(l.buckets) != 0 {
+		t.Error("It should drop a full bucket")
+	}
+}
+
+func TestPeerForSkipsBusyPeers(t *testing.T) {
+	saved := corps
+	defer func() { corps = saved }()
+	first, second := newPeer("127.0.0.1:6553"), newPeer("127.0.0.1:6554")
+	corps = map[string][]*peer{"dtac": {first, second}}
+
+	first.throttle(time.Minute)
+	if p := peerFor("dtac"); p != second {
+		t.Error("It should skip the throttled peer but got ", p.addr)
+	}
+	second.throttle(time.Minute)
+	if p := peerFor("dtac"); p != first {
+		t.Error("It should fall back to the first peer when all are busy but got ", p.addr)
+	}
+}
+
+func TestBusyErrorStatus(t *testing.T) {
+	err := busy(limitCorp, 1500*time.Millisecond)
+	if s := errorStatus(err); s != http.StatusTooManyRequests {
+		t.Error("It should be 429 but was ", s)
+	}
+	if (&chargeCall{err: err}).final() {
+		t.Error("It should retry a charge refused as busy")
+	}
+}
+
+func TestSetLimitsDefaultBackoff(t *testing.T) {
+	saved := busyBackoff
+	defer func() { busyBackoff = saved }()
+
+	for backoff, want := range map[time.Duration]time.Duration{
+		0:                      defaultBusyBackoff,
+		-time.Second:           defaultBusyBackoff,
+		250 * time.Millisecond: 250 * time.Millisecond,
+	} {
+		stateLock.Lock()
+		setLimits(config.Limits{BusyBackoff: config.Duration{Duration: backoff}})
+		got := busyBackoff
+		stateLock.Unlock()
+		if got != want {
+			t.Error("It should back off ", want, " for ", backoff, " but was ", got)
+		}
+	}
+}
+
+func TestLimitMiddleware(t *testing.T) {
+	saved := clientLimits
+	defer func() { clientLimits = saved }()
+	stateLock.Lock()
+	clientLimits = newLimiter(config.Rate{Rate: 0.5, Burst: 1}, nil)
+	stateLock.Unlock()
+
+	api := rest.NewApi()
+	api.Use(&LimitMiddleware{})
+	ok := func(w rest.ResponseWriter, req *rest.Request) { w.WriteJson(map[string]string{"status": "ok"}) }
+	router, err := rest.MakeRouter(
+		&rest.Route{HttpMethod: "GET", PathExp: "/balance/:corp/:subr", Func: ok},
+		&rest.Route{HttpMethod: "GET", PathExp: "/healthz", Func: ok},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.SetApp(router)
+	get := func(path string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", "http://localhost"+path, nil)
+		r.RemoteAddr = "10.0.0.1:40000"
+		w := httptest.NewRecorder()
+		api.MakeHandler().ServeHTTP(w, r)
+		return w
+	}
+
+	if w := get("/balance/dtac/66812345678"); w.Code != http.StatusOK {
+		t.Error("It should allow the burst but was ", w.Code)
+	}
+	w := get("/balance/dtac/66812345678")
+	if w.Code != http.StatusTooManyRequests {
+		t.Error("It should be 429 but was ", w.Code)
+	}
+	if v := w.Header().Get("Retry-After"); v != "2" {
+		t.Error("It should retry after 2s but was ", v)
+	}
+	if w := get("/healthz"); w.Code != http.StatusOK {
+		t.Error("It should not limit the health probes but was ", w.Code)
+	}
+}
@@ -0,0 +1,194 @@
+package balance
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/dict"
+
+	"server/config"
+	"server/diameter"
+)
+
+// step is one AVP of a mapping path.
+type step struct {
+	code   uint32
+	vendor uint32
+	all    bool
+	index  int
+}
+
+type field struct {
+	name  string
+	steps []step
+	list  bool
+	typ   string
+}
+
+// mapping turns an answer into JSON fields.
+type mapping []field
+
+// mappings maps a corp to the mapping of its balance answers. Corps
+// without one use BalanceInfo.
+var mappings map[string]mapping
+
+// mappingFor returns the mapping of corp, falling back to the default
+// corp like peerFor.
+func mappingFor(corp string) mapping {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	if m, ok := mappings[corp]; ok {
+		return m
+	}
+	if _, ok := corps[corp]; !ok {
+		return mappings[defaultCorp]
+	}
+	return nil
+}
+
+// compileMapping resolves the AVP names of fms with find.
+func compileMapping(fms []config.FieldMapping, find func(name string) (*dict.AVP, error)) (mapping, error) {
+	m := make(mapping, 0, len(fms))
+	for _, fm := range fms {
+		switch fm.Type {
+		case "", "string", "int", "float", "bool", "time":
+		default:
+			return nil, fmt.Errorf("mapping %s: unknown type %q", fm.Field, fm.Type)
+		}
+		f := field{name: fm.Field, typ: fm.Type}
+		for _, name := range strings.Split(fm.Path, ">") {
+			s := step{}
+			name = strings.TrimSpace(name)
+			if i := strings.IndexByte(name, '['); i >= 0 && strings.HasSuffix(name, "]") {
+				idx := name[i+1 : len(name)-1]
+				name = name[:i]
+				if idx == "*" {
+					s.all = true
+					f.list = true
+				} else {
+					n, err := strconv.Atoi(idx)
+					if err != nil || n < 0 {
+						return nil, fmt.Errorf("mapping %s: bad index [%s]", fm.Field, idx)
+					}
+					s.index = n
+				}
+			}
+			a, err := find(name)
+			if err != nil {
+				return nil, fmt.Errorf("mapping %s: %v", fm.Field, err)
+			}
+			s.code, s.vendor = a.Code, a.VendorID
+			f.steps = append(f.steps, s)
+		}
+		m = append(m, f)
+	}
+	return m, nil
+}
+
+// loadMappings compiles the mapping of every corp that has one against
+// the dictionary dp.
+func loadMappings(dp *dict.Parser, cfg map[string]config.Corp) (map[string]mapping, error) {
+	find := func(name string) (*dict.AVP, error) {
+		return dp.FindAVP(creditControlApp, name)
+	}
+	ms := make(map[string]mapping)
+	for name, corp := range cfg {
+		if len(corp.Mapping) == 0 {
+			continue
+		}
+		m, err := compileMapping(corp.Mapping, find)
+		if err != nil {
+			return nil, fmt.Errorf("corp %s: %v", name, err)
+		}
+		ms[name] = m
+	}
+	return ms, nil
+}
+
+// apply extracts the fields of m from avps. Fields whose AVP is missing
+// are left out.
+func (m mapping) apply(avps []*diam.AVP) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(m))
+	for _, f := range m {
+		found := walk(avps, f.steps)
+		vals := make([]interface{}, 0, len(found))
+		for _, a := range found {
+			v, err := convertValue(diameter.Value(a.Data), f.typ)
+			if err != nil {
+				return nil, fmt.Errorf("mapping %s: %v", f.name, err)
+			}
+			vals = append(vals, v)
+		}
+		switch {
+		case f.list:
+			out[f.name] = vals
+		case len(vals) > 0:
+			out[f.name] = vals[0]
+		}
+	}
+	return out, nil
+}
+
+// walk returns the AVPs reached from avps by steps. An AVP matches a
+// step by code and vendor, since vendors reuse each other's codes.
+func walk(avps []*diam.AVP, steps []step) []*diam.AVP {
+	var matched []*diam.AVP
+	n := 0
+	for _, a := range avps {
+		if a.Code != steps[0].code || a.VendorID != steps[0].vendor {
+			continue
+		}
+		if steps[0].all || n == steps[0].index {
+			matched = append(matched, a)
+		}
+		n++
+	}
+	if len(steps) == 1 {
+		return matched
+	}
+
+	var found []*diam.AVP
+	for _, a := range matched {
+		if g, ok := a.Data.(*diam.GroupedAVP); ok {
+			found = append(found, walk(g.AVP, steps[1:])...)
+		}
+	}
+	return found
+}
+
+func convertValue(v interface{}, typ string) (interface{}, error) {
+	switch typ {
+	case "string":
+		if t, ok := v.(time.Time); ok {
+			return t.Format(time.RFC3339), nil
+		}
+		return fmt.Sprint(v), nil
+	case "int":
+		return strconv.ParseInt(fmt.Sprint(v), 10, 64)
+	case "float":
+		return strconv.ParseFloat(fmt.Sprint(v), 64)
+	case "bool":
+		s := fmt.Sprint(v)
+		return s != "0" && s != "" && s != "false", nil
+	case "time":
+		if t, ok := v.(time.Time); ok {
+			return t.Format(time.RFC3339), nil
+		}
+		return nil, fmt.Errorf("%v is not a time", v)
+	}
+	return v, nil
+}
+
+// MarshalJSON writes the mapped fields when the corp has a mapping and
+// the built-in layout otherwise.
+func (b BalanceInfo) MarshalJSON() ([]byte, error) {
+	if b.mapped != nil {
+		return json.Marshal(b.mapped)
+	}
+	type layout BalanceInfo
+	return json.Marshal(layout(b))
+}
@@ -0,0 +1,87 @@
+package balance
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/datatype"
+	"github.com/fiorix/go-diameter/diam/dict"
+
+	"server/config"
+)
+
+var testAVPs = map[string]*dict.AVP{
+	"Session-Id":              {Code: 263},
+	"Service-Information":     {Code: 873, VendorID: 10415},
+	"Balance-Information":     {Code: 21100, VendorID: 2011},
+	"Balance":                 {Code: 30841, VendorID: 2011},
+	"Account-Change-Info":     {Code: 20349, VendorID: 2011},
+	"Current-Account-Balance": {Code: 20350, VendorID: 2011},
+}
+
+func testAVP(name string) (*dict.AVP, error) {
+	if a, ok := testAVPs[name]; ok {
+		return a, nil
+	}
+	return nil, fmt.Errorf("unknown AVP %s", name)
+}
+
+func group(code, vendor uint32, avps ...*diam.AVP) *diam.AVP {
+	return &diam.AVP{Code: code, VendorID: vendor, Data: &diam.GroupedAVP{AVP: avps}}
+}
+
+func TestMappingApply(t *testing.T) {
+	m, err := compileMapping([]config.FieldMapping{
+		{Field: "session", Path: "Session-Id"},
+		{Field: "balance", Path: "Service-Information>Balance-Information>Balance", Type: "string"},
+		{Field: "accounts", Path: "Service-Information>Balance-Information>Account-Change-Info[*]>Current-Account-Balance", Type: "int"},
+		{Field: "second", Path: "Service-Information>Balance-Information>Account-Change-Info[1]>Current-Account-Balance"},
+	}, testAVP)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	avps := []*diam.AVP{
+		{Code: 263, Data: datatype.UTF8String("s1")},
+		group(873, 10415, group(21100, 2011,
+			// Balance's code, but of another vendor.
+			&diam.AVP{Code: 30841, VendorID: 9, Data: datatype.Integer64(-1)},
+			&diam.AVP{Code: 30841, VendorID: 2011, Data: datatype.Integer64(12550)},
+			group(20349, 2011, &diam.AVP{Code: 20350, VendorID: 2011, Data: datatype.Integer64(100)}),
+			group(20349, 2011, &diam.AVP{Code: 20350, VendorID: 2011, Data: datatype.Integer64(200)}),
+		)),
+	}
+	out, err := m.apply(avps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := json.Marshal(out)
+	want := `{"accounts":[100,200],"balance":"12550","second":200,"session":"s1"}`
+	if string(b) != want {
+		t.Error("It should be ", want, " but was ", string(b))
+	}
+}
+
+func TestMappingUnknownAVP(t *testing.T) {
+	_, err := compileMapping([]config.FieldMapping{{Field: "x", Path: "Service-Information>Nope"}}, testAVP)
+	if err == nil {
+		t.Error("It should reject an unknown AVP")
+	}
+}
+
+func TestBalanceInfoMarshal(t *testing.T) {
+	b, _ := json.Marshal(BalanceInfo{SessionId: "s1"})
+	var v map[string]interface{}
+	json.Unmarshal(b, &v)
+	if v["SessionId"] != "s1" {
+		t.Error("It should keep the built-in layout but was ", string(b))
+	}
+
+	b, _ = json.Marshal(BalanceInfo{mapped: map[string]interface{}{"balance": 1}})
+	if string(b) != `{"balance":1}` {
+		t.Error("It should write the mapped fields but was ", string(b))
+	}
+}
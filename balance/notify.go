@@ -0,0 +1,151 @@
+package balance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
+	"github.com/fiorix/go-diameter/diam/datatype"
+
+	"server/config"
+	"server/diameter"
+	"server/logger"
+)
+
+// UnknownSessionID is the Result-Code of an answer to a RAR or ASR for
+// a session we do not know (DIAMETER_UNKNOWN_SESSION_ID, RFC 6733).
+const UnknownSessionID = 5002
+
+// Notification types.
+const (
+	NotifyReAuth = "RE_AUTH"
+	NotifyAbort  = "ABORT"
+)
+
+// Notification tells the product using a session that the OCS asked to
+// re-authorize or abort it.
+type Notification struct {
+	Type       string    `json:"type"`
+	SessionID  string    `json:"sessionId"`
+	Corp       string    `json:"corp"`
+	Subscriber string    `json:"subscriber"`
+	Time       time.Time `json:"time"`
+}
+
+// Notify, when set, is called with every notification in addition to
+// the configured webhook.
+var Notify func(Notification)
+
+var notifyConfig config.Notify
+
+// serverRequest is the part of a RAR or ASR we need.
+type serverRequest struct {
+	SessionID string `avp:"Session-Id"`
+}
+
+// OnRAR answers a Re-Auth-Request and sends CCR-Update for the session,
+// as RFC 4006 asks of the client.
+func OnRAR(c diam.Conn, m *diam.Message) {
+	s := onServerRequest(c, m, NotifyReAuth)
+	if s == nil {
+		return
+	}
+	go func() {
+		if err := s.reAuth(""); err != nil {
+			logger.Warn("re-auth update failed", "session_id", s.ID, "err", err)
+		}
+	}()
+}
+
+// OnASR answers an Abort-Session-Request and terminates the session.
+func OnASR(c diam.Conn, m *diam.Message) {
+	s := onServerRequest(c, m, NotifyAbort)
+	if s == nil {
+		return
+	}
+	go func() {
+		if err := s.Terminate("", Units{}); err != nil && err != ErrSessionClosed {
+			logger.Warn("abort termination failed", "session_id", s.ID, "err", err)
+		}
+	}()
+}
+
+// onServerRequest answers m with RAA or ASA and notifies the product
+// using the session. It returns the session, or nil when it is unknown.
+func onServerRequest(c diam.Conn, m *diam.Message, typ string) *Session {
+	diameter.OnMSG(c, m)
+
+	var r serverRequest
+	if err := m.Unmarshal(&r); err != nil {
+		logger.Error("bad server request", "err", err, "command", m.Header.CommandCode)
+	}
+	s := lookupSession(r.SessionID)
+
+	code := uint32(diam.Success)
+	if s == nil {
+		code = UnknownSessionID
+	}
+	a := serverAnswer(m, r.SessionID, code)
+	if _, err := a.WriteTo(c); err != nil {
+		logger.Error("answer write failed", "session_id", r.SessionID, "err", err)
+	}
+	diameter.Capture(c, a, true)
+	logger.Info("server request", "type", typ, "session_id", r.SessionID, "result_code", code)
+
+	if s == nil {
+		return nil
+	}
+	n := Notification{
+		Type:       typ,
+		SessionID:  s.ID,
+		Corp:       s.Corp,
+		Subscriber: s.Subscriber,
+		Time:       time.Now(),
+	}
+	if Notify != nil {
+		Notify(n)
+	}
+	stateLock.RLock()
+	cfg := notifyConfig
+	stateLock.RUnlock()
+	if cfg.Webhook != "" {
+		go post(cfg, n)
+	}
+	return s
+}
+
+// serverAnswer builds the RAA or ASA to m. Session-Id comes first, as
+// RFC 6733 requires, so it cannot be built with m.Answer, which starts
+// with Result-Code.
+func serverAnswer(m *diam.Message, sessionID string, code uint32) *diam.Message {
+	a := diam.NewMessage(m.Header.CommandCode, m.Header.CommandFlags&^diam.RequestFlag, m.Header.ApplicationID,
+		m.Header.HopByHopID, m.Header.EndToEndID, m.Dictionary())
+	a.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String(sessionID))
+	a.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(code))
+	a.NewAVP(avp.OriginHost, avp.Mbit, 0, identity)
+	a.NewAVP(avp.OriginRealm, avp.Mbit, 0, realm)
+	return a
+}
+
+// post sends n to the webhook of cfg.
+func post(cfg config.Notify, n Notification) {
+	b, err := json.Marshal(n)
+	if err != nil {
+		return
+	}
+	client := &http.Client{Timeout: cfg.Timeout.Duration}
+	resp, err := client.Post(cfg.Webhook, "application/json", bytes.NewReader(b))
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			err = fmt.Errorf("webhook answered %s", resp.Status)
+		}
+	}
+	if err != nil {
+		logger.Warn("notification failed", "type", n.Type, "session_id", n.SessionID, "err", err)
+	}
+}
@@ -0,0 +1,56 @@
+package balance
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
+
+	"server/config"
+)
+
+func TestPostNotification(t *testing.T) {
+	got := make(chan Notification, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n Notification
+		json.NewDecoder(r.Body).Decode(&n)
+		got <- n
+	}))
+	defer srv.Close()
+
+	post(config.Notify{Webhook: srv.URL, Timeout: config.Duration{Duration: time.Second}}, Notification{
+		Type:      NotifyAbort,
+		SessionID: "dtac.co.th;OMR1",
+		Corp:      "dtac",
+	})
+
+	select {
+	case n := <-got:
+		if n.Type != NotifyAbort || n.SessionID != "dtac.co.th;OMR1" {
+			t.Error("It should be the ABORT notification but was ", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("It should post the notification")
+	}
+}
+
+func TestServerAnswer(t *testing.T) {
+	rar := diam.NewMessage(diam.ReAuth, diam.RequestFlag|diam.ProxiableFlag, creditControlApp, 7, 8, nil)
+	a := serverAnswer(rar, "dtac.co.th;OMR1", diam.Success)
+	if a.Header.CommandFlags&diam.RequestFlag != 0 || a.Header.HopByHopID != 7 || a.Header.EndToEndID != 8 {
+		t.Error("It should answer the RAR but was ", a.Header)
+	}
+	want := []uint32{avp.SessionID, avp.ResultCode, avp.OriginHost, avp.OriginRealm}
+	if len(a.AVP) != len(want) {
+		t.Fatal("It should have ", len(want), " AVPs but had ", len(a.AVP))
+	}
+	for i, code := range want {
+		if a.AVP[i].Code != code {
+			t.Error("It should have AVP ", code, " at ", i, " but had ", a.AVP[i].Code)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package balance
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
+	"github.com/fiorix/go-diameter/diam/datatype"
+
+	"server/logger"
+)
+
+type PriceRequest struct {
+	ServiceIdentifier uint32
+	RatingGroup       uint32
+	// Units is the number of service specific units to price.
+	Units uint64
+}
+
+type Price struct {
+	ResultCode uint32 `json:"resultCode"`
+	Cost       *Cost  `json:"cost"`
+}
+
+// newPriceRequest builds the PRICE_ENQUIRY event CCR for subr.
+func newPriceRequest(sessionID, subr string, p PriceRequest) *diam.Message {
+	r := newCCR(sessionID, subr, EventRequest)
+	r.NewAVP(avp.ServiceContextID, avp.Mbit, 0, datatype.UTF8String(chargeServiceContext))
+	r.NewAVP(avp.RequestedAction, avp.Mbit, 0, datatype.Enumerated(PriceEnquiry))
+	r.NewAVP(avp.CCRequestNumber, avp.Mbit, 0, datatype.Unsigned32(0))
+	r.NewAVP(avp.EventTimestamp, avp.Mbit, 0, datatype.Time(time.Now()))
+	r.NewAVP(avp.ServiceIdentifier, avp.Mbit, 0, datatype.Unsigned32(p.ServiceIdentifier))
+	if p.RatingGroup > 0 {
+		r.NewAVP(avp.RatingGroup, avp.Mbit, 0, datatype.Unsigned32(p.RatingGroup))
+	}
+	if p.Units > 0 {
+		r.NewAVP(avp.RequestedServiceUnit, avp.Mbit, 0, &diam.GroupedAVP{
+			AVP: []*diam.AVP{
+				diam.NewAVP(avp.CCServiceSpecificUnits, avp.Mbit, 0, datatype.Unsigned64(p.Units)),
+			},
+		})
+	}
+	r.NewAVP(avp.DestinationHost, avp.Mbit, 0, datatype.OctetString("cbp211"))
+	return r
+}
+
+// price asks the OCS of corp what p would cost subr.
+func price(reqID, corp, subr string, p PriceRequest) (Price, error) {
+	vars := map[string]string{
+		"subscriber":        subr,
+		"serviceIdentifier": strconv.FormatUint(uint64(p.ServiceIdentifier), 10),
+		"ratingGroup":       strconv.FormatUint(uint64(p.RatingGroup), 10),
+		"units":             strconv.FormatUint(p.Units, 10),
+	}
+	m, err := exchange(reqID, corp, newSessionID(), requestBuilder(corp, OpPrice, vars, func(sessionID string) *diam.Message {
+		return newPriceRequest(sessionID, subr, p)
+	}))
+	if m == nil {
+		return Price{}, err
+	}
+
+	var a chargeCCA
+	m.Unmarshal(&a)
+	return Price{ResultCode: a.ResultCode, Cost: a.cost()}, err
+}
+
+// priceRequest reads the serviceIdentifier, ratingGroup and units
+// query parameters of a price enquiry.
+func priceRequest(q url.Values) (PriceRequest, error) {
+	var p PriceRequest
+	sid, err := strconv.ParseUint(q.Get("serviceIdentifier"), 10, 32)
+	if err != nil {
+		return p, errors.New("serviceIdentifier is required")
+	}
+	p.ServiceIdentifier = uint32(sid)
+	if v := q.Get("ratingGroup"); v != "" {
+		rg, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return p, errors.New("bad ratingGroup")
+		}
+		p.RatingGroup = uint32(rg)
+	}
+	if v := q.Get("units"); v != "" {
+		if p.Units, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return p, errors.New("bad units")
+		}
+	}
+	return p, nil
+}
+
+// GetPrice returns what a service would cost a subscriber, given by the
+// serviceIdentifier, ratingGroup and units query parameters.
+func GetPrice(w rest.ResponseWriter, req *rest.Request) {
+	corp := req.PathParam("corp")
+	subr := req.PathParam("subr")
+
+	p, err := priceRequest(req.URL.Query())
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reqID := logger.RequestID(req)
+	resp, err := price(reqID, corp, subr, p)
+	if err != nil {
+		logger.Error("price enquiry failed", "request_id", reqID, "corp", corp, "err", err)
+		httpError(w, err)
+		return
+	}
+	if resp.Cost == nil {
+		rest.Error(w, "OCS answered without Cost-Information", http.StatusBadGateway)
+		return
+	}
+	w.WriteJson(resp)
+}
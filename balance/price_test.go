@@ -0,0 +1,64 @@
+package balance
+
+import (
+	"net/url"
+	"testing"
+)
+
+// sentPrice is the part of a price enquiry CCR checked by the tests.
+type sentPrice struct {
+	CCRequestType     int    `avp:"CC-Request-Type"`
+	ServiceContextID  string `avp:"Service-Context-Id"`
+	RequestedAction   int    `avp:"Requested-Action"`
+	ServiceIdentifier uint32 `avp:"Service-Identifier"`
+	RatingGroup       uint32 `avp:"Rating-Group"`
+	Requested         struct {
+		Units uint64 `avp:"CC-Service-Specific-Units"`
+	} `avp:"Requested-Service-Unit"`
+}
+
+func TestNewPriceRequest(t *testing.T) {
+	for _, p := range []PriceRequest{
+		{ServiceIdentifier: 7, RatingGroup: 10, Units: 3},
+		{ServiceIdentifier: 7},
+	} {
+		r := newPriceRequest("dtac.co.th;OMR1", "66812345678", p)
+		var c sentPrice
+		if err := r.Unmarshal(&c); err != nil {
+			t.Fatal(err)
+		}
+		if c.CCRequestType != EventRequest || c.ServiceContextID != chargeServiceContext || c.RequestedAction != PriceEnquiry {
+			t.Error("It should be a price enquiry event but was ", c)
+		}
+		if c.ServiceIdentifier != p.ServiceIdentifier || c.RatingGroup != p.RatingGroup || c.Requested.Units != p.Units {
+			t.Error("It should carry ", p, " but was ", c)
+		}
+	}
+}
+
+func TestPriceRequestQuery(t *testing.T) {
+	for _, tt := range []struct {
+		query string
+		want  PriceRequest
+		err   string
+	}{
+		{"serviceIdentifier=7&ratingGroup=10&units=3", PriceRequest{ServiceIdentifier: 7, RatingGroup: 10, Units: 3}, ""},
+		{"serviceIdentifier=7", PriceRequest{ServiceIdentifier: 7}, ""},
+		{"ratingGroup=10", PriceRequest{}, "serviceIdentifier is required"},
+		{"serviceIdentifier=x", PriceRequest{}, "serviceIdentifier is required"},
+		{"serviceIdentifier=7&ratingGroup=-1", PriceRequest{}, "bad ratingGroup"},
+		{"serviceIdentifier=7&units=many", PriceRequest{}, "bad units"},
+	} {
+		q, _ := url.ParseQuery(tt.query)
+		p, err := priceRequest(q)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Error("It should be ", tt.err, " for "+tt.query+" but was ", err)
+			}
+			continue
+		}
+		if err != nil || p != tt.want {
+			t.Error("It should be ", tt.want, " for "+tt.query+" but was ", p, err)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package balance
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
+	"github.com/fiorix/go-diameter/diam/datatype"
+	"github.com/fiorix/go-diameter/diam/dict"
+
+	"server/config"
+	"server/diameter"
+	"server/logger"
+)
+
+// RawRequest describes a Diameter request by dictionary names, as sent
+// to the raw passthrough endpoint. Only Credit-Control requests can be
+// passed through, since answers are matched to their request by
+// Session-Id and only CCAs are delivered; Command may name it or be
+// left out. Application defaults to the Credit-Control application.
+// The Session-Id is assigned by the server, and Origin-Host,
+// Origin-Realm and Auth-Application-Id are added when missing.
+type RawRequest struct {
+	Corp        string               `json:"corp"`
+	Command     string               `json:"command"`
+	Application uint32               `json:"application"`
+	AVPs        []config.TemplateAVP `json:"avps"`
+}
+
+// RawResponse carries the answer, or the Result-Code error, of a raw
+// request.
+type RawResponse struct {
+	SessionID string            `json:"sessionId"`
+	Answer    *diameter.Decoded `json:"answer,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+var (
+	errRawCommand   = errors.New("balance: only Credit-Control requests can be passed through")
+	errRawSessionID = errors.New("balance: the Session-Id of a raw request is assigned by the server")
+)
+
+// commandCode resolves a command given by name, short name or code.
+func commandCode(dp *dict.Parser, name string) (uint32, error) {
+	if name == "" {
+		return diam.CreditControl, nil
+	}
+	if n, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(n), nil
+	}
+	if dp != nil {
+		for _, app := range dp.Apps() {
+			for _, c := range app.Command {
+				if strings.EqualFold(c.Name, name) || strings.EqualFold(c.Short+"R", name) {
+					return c.Code, nil
+				}
+			}
+		}
+	}
+	return 0, fmt.Errorf("balance: unknown command %q", name)
+}
+
+// hasAVP reports whether t names the AVP name at its top level.
+func hasAVP(t []config.TemplateAVP, name string) bool {
+	for _, a := range t {
+		if a.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// buildRaw returns a new Session-Id and a function building r with it.
+// r may not set its own Session-Id, which could steal the answer of
+// another request or session.
+func buildRaw(r RawRequest) (string, func(string) *diam.Message, error) {
+	cmd, err := commandCode(dict.Default, r.Command)
+	if err != nil {
+		return "", nil, err
+	}
+	if cmd != diam.CreditControl {
+		return "", nil, errRawCommand
+	}
+	app := r.Application
+	if app == 0 {
+		app = creditControlApp
+	}
+
+	if hasAVP(r.AVPs, "Session-Id") {
+		return "", nil, errRawSessionID
+	}
+	sessionID := newSessionID()
+
+	// Render once up front so a bad request is reported as such.
+	vars := map[string]string{"sessionId": sessionID}
+	t := append([]config.TemplateAVP{{Name: "Session-Id", Value: "{{sessionId}}"}}, r.AVPs...)
+	if _, err = render(dict.Default, cmd, app, t, vars); err != nil {
+		return "", nil, err
+	}
+
+	return sessionID, func(sessionID string) *diam.Message {
+		m, _ := render(dict.Default, cmd, app, t, map[string]string{"sessionId": sessionID})
+		if !hasAVP(r.AVPs, "Origin-Host") {
+			m.NewAVP(avp.OriginHost, avp.Mbit, 0, identity)
+		}
+		if !hasAVP(r.AVPs, "Origin-Realm") {
+			m.NewAVP(avp.OriginRealm, avp.Mbit, 0, realm)
+		}
+		if !hasAVP(r.AVPs, "Auth-Application-Id") {
+			m.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(app))
+		}
+		return m
+	}, nil
+}
+
+// SendRaw sends r to the OCS of its corp and returns the answer. An
+// answer whose Result-Code is not DIAMETER_SUCCESS is returned together
+// with a ResultError.
+func SendRaw(reqID string, r RawRequest) (*diam.Message, error) {
+	r.Corp = corpOrDefault(r.Corp)
+	sessionID, build, err := buildRaw(r)
+	if err != nil {
+		return nil, err
+	}
+	return exchange(reqID, r.Corp, sessionID, build)
+}
+
+// PostRaw passes a Diameter request described in JSON through to the
+// OCS and returns the answer decoded via the dictionary. A
+// DIAMETER_TOO_BUSY answer is served as 429 with Retry-After. It is only
+// served to requests authenticated by AdminMiddleware.
+func PostRaw(w rest.ResponseWriter, req *rest.Request) {
+	if req.Env[adminEnv] != true {
+		rest.Error(w, "raw passthrough needs an admin token", http.StatusForbidden)
+		return
+	}
+	var r RawRequest
+	if err := req.DecodeJsonPayload(&r); err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.Corp = corpOrDefault(r.Corp)
+
+	reqID := logger.RequestID(req)
+	sessionID, build, err := buildRaw(r)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logger.Info("raw request", "request_id", reqID, "corp", r.Corp, "session_id", sessionID)
+
+	m, err := exchange(reqID, r.Corp, sessionID, build)
+	resp := RawResponse{SessionID: sessionID}
+	if m != nil {
+		d := diameter.Decode(m)
+		resp.Answer = &d
+	}
+	if err != nil {
+		resp.Error = err.Error()
+		if _, isBusy := err.(*BusyError); m == nil || isBusy {
+			setRetryAfter(w, err)
+			w.WriteHeader(errorStatus(err))
+		}
+	}
+	w.WriteJson(resp)
+}
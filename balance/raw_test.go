@@ -0,0 +1,56 @@
+package balance
+
+import (
+	"testing"
+
+	"github.com/fiorix/go-diameter/diam/avp"
+	"github.com/fiorix/go-diameter/diam/datatype"
+
+	"server/config"
+)
+
+// rawAVPs is a Credit-Control event request for 66812345678.
+var rawAVPs = []config.TemplateAVP{
+	{Name: "CC-Request-Type", Value: "EVENT_REQUEST"},
+	{Name: "CC-Request-Number", Value: "0"},
+	{Name: "Subscription-Id", AVPs: []config.TemplateAVP{
+		{Name: "Subscription-Id-Type", Value: "0"},
+		{Name: "Subscription-Id-Data", Value: "66812345678"},
+	}},
+}
+
+func TestBuildRaw(t *testing.T) {
+	sessionID, build, err := buildRaw(RawRequest{Command: "CCR", AVPs: rawAVPs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sessionID == "" {
+		t.Fatal("It should assign a Session-Id")
+	}
+	m := build(sessionID)
+	sid, err := m.FindAVP(avp.SessionID)
+	if err != nil || sid.Data != datatype.UTF8String(sessionID) {
+		t.Error("It should send the assigned Session-Id but was ", sid)
+	}
+	for _, code := range []uint32{avp.OriginHost, avp.OriginRealm, avp.AuthApplicationID} {
+		if _, err := m.FindAVP(code); err != nil {
+			t.Error("It should add the missing AVP ", code)
+		}
+	}
+
+	for name, r := range map[string]RawRequest{
+		"a Session-Id":       {AVPs: append([]config.TemplateAVP{{Name: "Session-Id", Value: "dtac.co.th;other"}}, rawAVPs...)},
+		"another command":    {Command: "Device-Watchdog", AVPs: rawAVPs},
+		"a command code":     {Command: "280", AVPs: rawAVPs},
+		"an unknown command": {Command: "No-Such", AVPs: rawAVPs},
+		"an unknown AVP":     {AVPs: []config.TemplateAVP{{Name: "No-Such-AVP", Value: "1"}}},
+		"a bad value":        {AVPs: []config.TemplateAVP{{Name: "CC-Request-Number", Value: "x"}}},
+	} {
+		if _, _, err := buildRaw(r); err == nil {
+			t.Error("It should refuse a request with ", name)
+		}
+	}
+	if _, _, err := buildRaw(RawRequest{AVPs: append([]config.TemplateAVP{{Name: "Session-Id", Value: "x"}}, rawAVPs...)}); err != errRawSessionID {
+		t.Error("It should be errRawSessionID but was ", err)
+	}
+}
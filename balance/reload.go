@@ -0,0 +1,105 @@
+package balance
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/fiorix/go-diameter/diam/dict"
+
+	"server/config"
+	"server/diameter"
+	"server/dictionary"
+	"server/logger"
+)
+
+// PeerChange is a peer dialed or retired by a reload.
+type PeerChange struct {
+	Corp string `json:"corp"`
+	Addr string `json:"addr"`
+
+	peer *peer
+}
+
+// ReloadResult reports what a reload changed.
+type ReloadResult struct {
+	Added   []PeerChange `json:"added"`
+	Removed []PeerChange `json:"removed"`
+}
+
+// reloadLock serializes reloads.
+var reloadLock sync.Mutex
+
+// Reload applies cfg to the running service. The dictionaries are read
+// into a new parser and the templates and mappings checked against it
+// before anything changes, so a reload that fails keeps the current
+// state. The new parser then replaces dict.Default.
+// New peers are dialed; removed peers take no new requests and get a
+// DPR once their outstanding ones are answered. Rate limits start from
+// full buckets and a new MaxOutstanding applies to new peers. The
+// admin token and API clients are reloaded by the caller. The cache,
+// capture, replay and CORS settings and the listen address still need
+// a restart.
+func Reload(cfg *config.Config) (ReloadResult, error) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	dp, err := dictionary.New(cfg.Dictionaries...)
+	if err != nil {
+		return ReloadResult{}, err
+	}
+	t, err := loadTemplates(dp, cfg.Corps)
+	if err != nil {
+		return ReloadResult{}, err
+	}
+	ms, err := loadMappings(dp, cfg.Corps)
+	if err != nil {
+		return ReloadResult{}, err
+	}
+	mode, err := diameter.ValidationMode(cfg.Validation)
+	if err != nil {
+		return ReloadResult{}, err
+	}
+
+	diameter.SetValidation(mode)
+	stateLock.Lock()
+	drain := answerTimeout
+	dict.Default = dp
+	templates = t
+	mappings = ms
+	answerTimeout = cfg.AnswerTimeout.Duration
+	notifyConfig = cfg.Notify
+	defaultCorp = cfg.DefaultCorp
+	setLimits(cfg.Limits)
+	added, removed := setPeers(cfg.Corps)
+	stateLock.Unlock()
+
+	for _, pc := range removed {
+		logger.Info("retiring peer", "corp", pc.Corp, "peer", pc.Addr)
+		go pc.peer.retire(drain)
+	}
+	for _, pc := range added {
+		logger.Info("added peer", "corp", pc.Corp, "peer", pc.Addr)
+	}
+	return ReloadResult{Added: added, Removed: removed}, nil
+}
+
+// Reloader, when set, reads the configuration again and applies it
+// with Reload. It is called by PostReload.
+var Reloader func() (ReloadResult, error)
+
+// PostReload reloads the configuration and dictionaries. A reload that
+// fails answers 422 with the error and leaves the service unchanged.
+func PostReload(w rest.ResponseWriter, req *rest.Request) {
+	if Reloader == nil {
+		rest.Error(w, "reload is not available", http.StatusNotImplemented)
+		return
+	}
+	r, err := Reloader()
+	if err != nil {
+		logger.Error("reload failed", "request_id", logger.RequestID(req), "err", err)
+		rest.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	w.WriteJson(r)
+}
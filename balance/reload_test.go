@@ -0,0 +1,199 @@
+package balance
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/fiorix/go-diameter/diam/dict"
+
+	"server/config"
+	"server/diameter"
+	"server/dictionary"
+)
+
+func TestRetireWaitsForInflight(t *testing.T) {
+	p := newPeer("127.0.0.1:3868")
+	p.inflight <- struct{}{}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		<-p.inflight
+	}()
+
+	start := time.Now()
+	p.retire(time.Second)
+	if d := time.Since(start); d < 100*time.Millisecond || d >= time.Second {
+		t.Error("It should wait for the outstanding request but took ", d)
+	}
+	select {
+	case <-p.stop:
+	default:
+		t.Error("It should stop redialing the peer")
+	}
+}
+
+func TestRetireGivesUpAfterDrain(t *testing.T) {
+	p := newPeer("127.0.0.1:3868")
+	p.inflight <- struct{}{}
+
+	start := time.Now()
+	p.retire(100 * time.Millisecond)
+	if d := time.Since(start); d >= time.Second {
+		t.Error("It should give up after the drain time but took ", d)
+	}
+}
+
+// saveState saves the state replaced by Reload and returns a function
+// restoring it.
+func saveState() func() {
+	stateLock.Lock()
+	c, d, a, tm, ms := corps, defaultCorp, answerTimeout, templates, mappings
+	stateLock.Unlock()
+	return func() {
+		stateLock.Lock()
+		corps, defaultCorp, answerTimeout, templates, mappings = c, d, a, tm, ms
+		stateLock.Unlock()
+		diameter.SetValidation(diameter.ValidateOff)
+	}
+}
+
+func TestReloadFailureKeepsState(t *testing.T) {
+	defer saveState()()
+	p := newPeer("127.0.0.1:3868")
+	stateLock.Lock()
+	corps = map[string][]*peer{"dtac": {p}}
+	defaultCorp = "dtac"
+	answerTimeout = 7 * time.Second
+	stateLock.Unlock()
+	diameter.SetValidation(diameter.ValidateLog)
+	defer func(dp *dict.Parser) { dict.Default = dp }(dict.Default)
+	dp := dict.Default
+
+	badTemplate := config.Default()
+	badTemplate.Corps = map[string]config.Corp{"other": {
+		Peers:     []string{"127.0.0.1:3869"},
+		Templates: map[string][]config.TemplateAVP{"balance": {{Name: "No-Such-AVP", Value: "1"}}},
+	}}
+	badDictionary := config.Default()
+	// The first file loads, so only a reload that waits for every file
+	// keeps the current dictionary.
+	badDictionary.Dictionaries = []string{dictionary.Files()[2], "/nonexistent/dictionary.xml"}
+	badValidation := config.Default()
+	badValidation.Validation = "loud"
+
+	for name, cfg := range map[string]*config.Config{
+		"template":   badTemplate,
+		"dictionary": badDictionary,
+		"validation": badValidation,
+	} {
+		cfg.DefaultCorp = "other"
+		cfg.AnswerTimeout.Duration = time.Second
+		r, err := Reload(cfg)
+		if err == nil {
+			t.Error("It should report the bad " + name)
+		}
+		if len(r.Added) != 0 || len(r.Removed) != 0 {
+			t.Error("It should not change peers on a bad "+name+" but was ", r)
+		}
+		stateLock.RLock()
+		if len(corps) != 1 || corps["dtac"][0] != p || defaultCorp != "dtac" || answerTimeout != 7*time.Second {
+			t.Error("It should keep the current state on a bad " + name)
+		}
+		stateLock.RUnlock()
+		if mode := diameter.Validation(); mode != diameter.ValidateLog {
+			t.Error("It should keep the validation mode on a bad "+name+" but was ", mode)
+		}
+		if dict.Default != dp {
+			t.Error("It should keep the dictionary on a bad " + name)
+		}
+	}
+}
+
+func TestSetPeers(t *testing.T) {
+	defer saveState()()
+	stateLock.Lock()
+	corps = make(map[string][]*peer)
+	stateLock.Unlock()
+
+	apply := func(cfg map[string]config.Corp) (added, removed []PeerChange) {
+		stateLock.Lock()
+		added, removed = setPeers(cfg)
+		stateLock.Unlock()
+		for _, pc := range removed {
+			pc.peer.retire(0)
+		}
+		return added, removed
+	}
+	defer apply(nil)
+
+	added, removed := apply(map[string]config.Corp{"dtac": {Peers: []string{"127.0.0.1:1"}}})
+	if len(added) != 1 || len(removed) != 0 {
+		t.Error("It should add one peer but was ", added, removed)
+	}
+	first := added[0].peer
+
+	added, removed = apply(map[string]config.Corp{"dtac": {Peers: []string{"127.0.0.1:1", "127.0.0.1:2"}}})
+	if len(added) != 1 || added[0].Addr != "127.0.0.1:2" || len(removed) != 0 {
+		t.Error("It should add only the new peer but was ", added, removed)
+	}
+	if findPeer(corps["dtac"], "127.0.0.1:1") != first {
+		t.Error("It should keep the connected peer")
+	}
+
+	added, removed = apply(map[string]config.Corp{"dtac": {Peers: []string{"127.0.0.1:2"}}})
+	if len(added) != 0 || len(removed) != 1 || removed[0].Addr != "127.0.0.1:1" {
+		t.Error("It should remove only the dropped peer but was ", added, removed)
+	}
+}
+
+func TestForgetReaddedPeer(t *testing.T) {
+	defer saveState()()
+	const addr = "127.0.0.1:3870"
+	defer diameter.Forget(addr)
+
+	old := newPeer(addr)
+	stateLock.Lock()
+	corps = map[string][]*peer{"dtac": {newPeer(addr)}}
+	stateLock.Unlock()
+	diameter.SetCorp(addr, "dtac")
+
+	old.forget()
+	if c := diameter.Status(addr).Corp; c != "dtac" {
+		t.Error("It should keep the status of the re-added peer but was ", c)
+	}
+
+	stateLock.Lock()
+	corps = map[string][]*peer{}
+	stateLock.Unlock()
+	old.forget()
+	if c := diameter.Status(addr).Corp; c != "" {
+		t.Error("It should forget a peer no longer configured but was ", c)
+	}
+}
+
+func TestPostReloadFailure(t *testing.T) {
+	defer func(r func() (ReloadResult, error)) { Reloader = r }(Reloader)
+	Reloader = func() (ReloadResult, error) {
+		return ReloadResult{}, errors.New("bad template")
+	}
+
+	api := rest.NewApi()
+	router, err := rest.MakeRouter(&rest.Route{HttpMethod: "POST", PathExp: "/admin/reload", Func: PostReload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.SetApp(router)
+	r, _ := http.NewRequest("POST", "http://localhost/admin/reload", nil)
+	w := httptest.NewRecorder()
+	api.MakeHandler().ServeHTTP(w, r)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Error("It should be 422 but was ", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "bad template") {
+		t.Error("It should report the error but was ", w.Body.String())
+	}
+}
@@ -0,0 +1,46 @@
+package balance
+
+import (
+	"net"
+	"time"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/dict"
+
+	"server/config"
+	"server/diameter"
+	"server/logger"
+)
+
+// startReplay sets up the record or replay mode of cfg. In replay mode
+// it serves the recording on a local port and returns its address,
+// which then stands in for every OCS peer. Requests are recorded when
+// answered within timeout.
+func startReplay(cfg config.Replay, timeout time.Duration) (string, error) {
+	switch cfg.Mode {
+	case "record":
+		r, err := diameter.NewRecorder(cfg.File, timeout)
+		if err != nil {
+			return "", err
+		}
+		diameter.SetRecorder(r)
+		logger.Info("recording ccr/cca pairs", "file", cfg.File)
+	case "replay":
+		rp, err := diameter.LoadReplay(cfg.File, dict.Default)
+		if err != nil {
+			return "", err
+		}
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return "", err
+		}
+		srv := &diam.Server{
+			Handler: rp.Handler("replay", "www.huawei.com"),
+			Dict:    dict.Default,
+		}
+		go srv.Serve(l)
+		logger.Info("replaying recorded answers", "file", cfg.File, "requests", rp.Len(), "addr", l.Addr().String())
+		return l.Addr().String(), nil
+	}
+	return "", nil
+}
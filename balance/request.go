@@ -0,0 +1,218 @@
+package balance
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fiorix/go-diameter/diam"
+
+	"server/diameter"
+	"server/logger"
+	"server/metrics"
+)
+
+// UserUnknown is the Result-Code of a CCA for an unknown subscriber
+// (DIAMETER_USER_UNKNOWN, RFC 4006).
+const UserUnknown = 5030
+
+var (
+	ErrTimeout   = errors.New("balance: timed out waiting for CCA")
+	ErrNoPeer    = errors.New("balance: no connection to OCS")
+	ErrTemplate  = errors.New("balance: request template failed to render")
+	responseLock sync.Mutex
+	response     map[string]chan answer
+	sessionSeq   uint64
+
+	// answerTimeout is how long a request waits for its CCA.
+	answerTimeout = 10 * time.Second
+)
+
+// answer is a CCA handed from OnCCA to the waiting request.
+type answer struct {
+	msg        *diam.Message
+	sessionID  string
+	resultCode uint32
+	// invalid is set when the CCA breaks the dictionary rules and
+	// validation is strict.
+	invalid error
+}
+
+// ResultError is returned when the OCS answers with a Result-Code other
+// than DIAMETER_SUCCESS.
+type ResultError struct {
+	Code uint32
+}
+
+func (e ResultError) Error() string {
+	return fmt.Sprintf("balance: OCS answered with Result-Code %d", e.Code)
+}
+
+// newSessionID returns a unique Session-Id. The sequence never wraps,
+// so ids stay unique however many requests are issued per second.
+func newSessionID() string {
+	n := atomic.AddUint64(&sessionSeq, 1)
+	return fmt.Sprintf("dtac.co.th;OMR%s;%d", time.Now().Format("20060102150405"), n)
+}
+
+// query sends a balance CCR for subr to the OCS of corp and waits for
+// the matching CCA. reqID correlates the exchange with the HTTP request
+// in the logs.
+func query(reqID, corp, subr string) (BalanceInfo, error) {
+	a, err := QueryAnswer(reqID, corp, subr)
+	if err != nil {
+		return BalanceInfo{}, err
+	}
+
+	var b BalanceInfo
+	if err = a.Unmarshal(&b); err != nil {
+		return BalanceInfo{}, err
+	}
+	if mp := mappingFor(corp); mp != nil {
+		if b.mapped, err = mp.apply(a.AVP); err != nil {
+			return BalanceInfo{}, err
+		}
+	}
+	return b, nil
+}
+
+// QueryAnswer sends a balance CCR for subr to the OCS of corp and
+// returns the CCA undecoded.
+func QueryAnswer(reqID, corp, subr string) (*diam.Message, error) {
+	vars := map[string]string{"subscriber": subr}
+	return exchange(reqID, corp, newSessionID(), requestBuilder(corp, OpBalance, vars, func(sessionID string) *diam.Message {
+		return newBalanceRequest(sessionID, subr)
+	}))
+}
+
+// exchange sends the CCR built by build for sessionID to the OCS of
+// corp and waits for the CCA with the same Session-Id. Only one request
+// per Session-Id may be outstanding. A CCA whose Result-Code is not
+// DIAMETER_SUCCESS is returned together with a ResultError, except
+// DIAMETER_TOO_BUSY, which throttles the peer and returns a BusyError
+// like the other limits.
+func exchange(reqID, corp, sessionID string, build func(sessionID string) *diam.Message) (*diam.Message, error) {
+	return exchangeSent(reqID, corp, sessionID, build, nil)
+}
+
+// exchangeSent is exchange calling sent, when it is set, with the CCR
+// once it is written to the peer.
+func exchangeSent(reqID, corp, sessionID string, build func(sessionID string) *diam.Message, sent func(r *diam.Message)) (*diam.Message, error) {
+	p := peerFor(corp)
+	if p == nil {
+		return nil, ErrNoPeer
+	}
+	c := p.Conn()
+	if c == nil {
+		return nil, ErrNoPeer
+	}
+	if d := p.busy(); d > 0 {
+		return nil, busy(limitBusy, d)
+	}
+
+	select {
+	case p.inflight <- struct{}{}:
+	default:
+		return nil, busy(limitOutstanding, outstandingRetry)
+	}
+	defer func() { <-p.inflight }()
+
+	stateLock.RLock()
+	name := corp
+	if _, ok := corps[name]; !ok {
+		name = defaultCorp
+	}
+	wait := corpLimits.take(name, time.Now())
+	timeout := answerTimeout
+	backoff := busyBackoff
+	stateLock.RUnlock()
+	if wait > 0 {
+		return nil, busy(limitCorp, wait)
+	}
+
+	r := build(sessionID)
+	if r == nil {
+		return nil, ErrTemplate
+	}
+	if err := diameter.Check(r, true); err != nil {
+		return nil, err
+	}
+
+	ch := make(chan answer, 1)
+	responseLock.Lock()
+	response[sessionID] = ch
+	responseLock.Unlock()
+	defer forget(sessionID)
+
+	log := []interface{}{
+		"request_id", reqID,
+		"corp", corp,
+		"peer", p.addr,
+		"session_id", sessionID,
+		"hop_by_hop", r.Header.HopByHopID,
+	}
+	if logger.Tracing() {
+		logger.Debug("ccr", append(log, "message", diameter.Format(r))...)
+	}
+
+	start := time.Now()
+	if _, err := r.WriteTo(c); err != nil {
+		logger.Error("ccr write failed", append(log, "err", err)...)
+		return nil, err
+	}
+	if sent != nil {
+		sent(r)
+	}
+	metrics.CCRSent.WithLabelValues(p.addr).Inc()
+	diameter.Capture(c, r, true)
+	logger.Info("ccr sent", log...)
+
+	pending := metrics.Pending.WithLabelValues(p.addr)
+	pending.Inc()
+	defer pending.Dec()
+
+	select {
+	case a := <-ch:
+		rtt := time.Since(start)
+		metrics.RoundTrip.WithLabelValues(p.addr).Observe(rtt.Seconds())
+		logger.Info("cca received", append(log, "rtt_ms", rtt.Seconds()*1000, "result_code", a.resultCode)...)
+		if a.invalid != nil {
+			return a.msg, a.invalid
+		}
+		if a.resultCode == diam.TooBusy {
+			logger.Warn("peer too busy", append(log, "backoff", backoff.String())...)
+			p.throttle(backoff)
+			return a.msg, busy(limitBusy, backoff)
+		}
+		if a.resultCode != diam.Success {
+			return a.msg, ResultError{a.resultCode}
+		}
+		return a.msg, nil
+	case <-time.After(timeout):
+		metrics.Timeouts.WithLabelValues(p.addr).Inc()
+		logger.Warn("cca timeout", log...)
+		return nil, ErrTimeout
+	}
+}
+
+// deliver hands a CCA to the request waiting on its Session-Id.
+func deliver(a answer) {
+	responseLock.Lock()
+	ch, ok := response[a.sessionID]
+	responseLock.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case ch <- a:
+	default:
+	}
+}
+
+func forget(sessionID string) {
+	responseLock.Lock()
+	delete(response, sessionID)
+	responseLock.Unlock()
+}
@@ -0,0 +1,80 @@
+package balance
+
+import (
+	"net"
+	"testing"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
+	"github.com/fiorix/go-diameter/diam/datatype"
+	"github.com/fiorix/go-diameter/diam/dict"
+
+	"server/diameter"
+	"server/dictionary"
+)
+
+// fakeConn is a diam.Conn that only knows its addresses.
+type fakeConn struct {
+	diam.Conn
+}
+
+func (fakeConn) LocalAddr() net.Addr  { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 40000} }
+func (fakeConn) RemoteAddr() net.Addr { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 3868} }
+
+// deliverCCA hands m to OnCCA for a request waiting on sessionID and
+// returns the answer it delivered.
+func deliverCCA(t *testing.T, sessionID string, m *diam.Message) answer {
+	ch := make(chan answer, 1)
+	responseLock.Lock()
+	if response == nil {
+		response = make(map[string]chan answer)
+	}
+	response[sessionID] = ch
+	responseLock.Unlock()
+	defer forget(sessionID)
+
+	OnCCA(fakeConn{}, m)
+	select {
+	case a := <-ch:
+		return a
+	default:
+		t.Fatal("It should deliver the CCA")
+		return answer{}
+	}
+}
+
+func TestOnCCAStrict(t *testing.T) {
+	defer func(p *dict.Parser) { dict.Default = p }(dict.Default)
+	dict.Default = dictionary.Load()
+	defer diameter.SetValidation(diameter.ValidateOff)
+
+	cca := func(withResult bool) *diam.Message {
+		m := diam.NewMessage(diam.CreditControl, 0, creditControlApp, 1, 1, dict.Default)
+		m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("dtac.co.th;strict"))
+		if withResult {
+			m.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(diam.Success))
+		}
+		m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("ocs"))
+		m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("dtac.co.th"))
+		m.NewAVP(avp.CCRequestType, avp.Mbit, 0, datatype.Enumerated(EventRequest))
+		m.NewAVP(avp.CCRequestNumber, avp.Mbit, 0, datatype.Unsigned32(0))
+		return m
+	}
+
+	for _, tt := range []struct {
+		mode       string
+		withResult bool
+		invalid    bool
+	}{
+		{diameter.ValidateStrict, true, false},
+		{diameter.ValidateStrict, false, true},
+		{diameter.ValidateLog, false, false},
+		{diameter.ValidateOff, false, false},
+	} {
+		diameter.SetValidation(tt.mode)
+		a := deliverCCA(t, "dtac.co.th;strict", cca(tt.withResult))
+		if (a.invalid != nil) != tt.invalid {
+			t.Error("Unexpected validation in mode "+tt.mode+": ", a.invalid)
+		}
+	}
+}
@@ -0,0 +1,446 @@
+package balance
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
+	"github.com/fiorix/go-diameter/diam/datatype"
+
+	"server/logger"
+)
+
+// Session states.
+const (
+	SessionPending = "PENDING"
+	SessionOpen    = "OPEN"
+	SessionClosed  = "CLOSED"
+)
+
+// OpSession names the session endpoints for authorization.
+const OpSession = "session"
+
+// Termination-Cause values (RFC 6733).
+const (
+	terminationLogout  = 1
+	terminationTimeout = 8
+)
+
+// sessionIdle is how long a session without a Validity-Time may go
+// without an update before it is terminated.
+const sessionIdle = 30 * time.Minute
+
+// sessionGrace is added to the Validity-Time of a session before it is
+// terminated, leaving time for the update that is due.
+const sessionGrace = time.Minute
+
+var (
+	ErrNoSession     = errors.New("balance: no such session")
+	ErrSessionClosed = errors.New("balance: session is closed")
+)
+
+// Units is a Requested-, Granted- or Used-Service-Unit.
+type Units struct {
+	Time                 uint32 `json:"time,omitempty" avp:"CC-Time"`
+	TotalOctets          uint64 `json:"totalOctets,omitempty" avp:"CC-Total-Octets"`
+	InputOctets          uint64 `json:"inputOctets,omitempty" avp:"CC-Input-Octets"`
+	OutputOctets         uint64 `json:"outputOctets,omitempty" avp:"CC-Output-Octets"`
+	ServiceSpecificUnits uint64 `json:"serviceSpecificUnits,omitempty" avp:"CC-Service-Specific-Units"`
+}
+
+func (u Units) add(v Units) Units {
+	return Units{
+		Time:                 u.Time + v.Time,
+		TotalOctets:          u.TotalOctets + v.TotalOctets,
+		InputOctets:          u.InputOctets + v.InputOctets,
+		OutputOctets:         u.OutputOctets + v.OutputOctets,
+		ServiceSpecificUnits: u.ServiceSpecificUnits + v.ServiceSpecificUnits,
+	}
+}
+
+func (u Units) avp(code uint32) *diam.AVP {
+	g := &diam.GroupedAVP{}
+	if u.Time > 0 {
+		g.AVP = append(g.AVP, diam.NewAVP(avp.CCTime, avp.Mbit, 0, datatype.Unsigned32(u.Time)))
+	}
+	if u.TotalOctets > 0 {
+		g.AVP = append(g.AVP, diam.NewAVP(avp.CCTotalOctets, avp.Mbit, 0, datatype.Unsigned64(u.TotalOctets)))
+	}
+	if u.InputOctets > 0 {
+		g.AVP = append(g.AVP, diam.NewAVP(avp.CCInputOctets, avp.Mbit, 0, datatype.Unsigned64(u.InputOctets)))
+	}
+	if u.OutputOctets > 0 {
+		g.AVP = append(g.AVP, diam.NewAVP(avp.CCOutputOctets, avp.Mbit, 0, datatype.Unsigned64(u.OutputOctets)))
+	}
+	if u.ServiceSpecificUnits > 0 {
+		g.AVP = append(g.AVP, diam.NewAVP(avp.CCServiceSpecificUnits, avp.Mbit, 0, datatype.Unsigned64(u.ServiceSpecificUnits)))
+	}
+	return diam.NewAVP(code, avp.Mbit, 0, g)
+}
+
+// Session is a credit-control session opened with CCR-Initial.
+type Session struct {
+	ID                string    `json:"id"`
+	Corp              string    `json:"corp"`
+	Subscriber        string    `json:"subscriber"`
+	ServiceContextID  string    `json:"serviceContextId"`
+	ServiceIdentifier uint32    `json:"serviceIdentifier"`
+	RatingGroup       uint32    `json:"ratingGroup"`
+	State             string    `json:"state"`
+	RequestNumber     uint32    `json:"requestNumber"`
+	Granted           Units     `json:"granted"`
+	Used              Units     `json:"used"`
+	ValidityTime      uint32    `json:"validityTime,omitempty"`
+	ResultCode        uint32    `json:"resultCode"`
+	Created           time.Time `json:"created"`
+	Updated           time.Time `json:"updated"`
+
+	// mu serializes the requests of the session, so that only one is
+	// outstanding and CC-Request-Number grows by one each time.
+	mu sync.Mutex
+	// cause is the Termination-Cause of the CCR-Termination.
+	cause int32
+}
+
+// sessionCCA is the part of a session CCA we keep.
+type sessionCCA struct {
+	ResultCode uint32 `avp:"Result-Code"`
+	MSCC       []struct {
+		Granted      Units  `avp:"Granted-Service-Unit"`
+		RatingGroup  uint32 `avp:"Rating-Group"`
+		ResultCode   uint32 `avp:"Result-Code"`
+		ValidityTime uint32 `avp:"Validity-Time"`
+	} `avp:"Multiple-Services-Credit-Control"`
+}
+
+var (
+	sessionsLock sync.Mutex
+	sessions     = make(map[string]*Session)
+)
+
+// lookupSession returns the open session with Session-Id id.
+func lookupSession(id string) *Session {
+	sessionsLock.Lock()
+	defer sessionsLock.Unlock()
+	return sessions[id]
+}
+
+// SessionCorp returns the corp of the session named by the :id path
+// parameter, or "" when there is no such session.
+func SessionCorp(req *rest.Request) string {
+	if s := lookupSession(req.PathParam("id")); s != nil {
+		return s.Corp
+	}
+	return ""
+}
+
+func dropSession(id string) {
+	sessionsLock.Lock()
+	delete(sessions, id)
+	sessionsLock.Unlock()
+}
+
+// snapshot copies the exported state of s.
+func (s *Session) snapshot() Session {
+	return Session{
+		ID:                s.ID,
+		Corp:              s.Corp,
+		Subscriber:        s.Subscriber,
+		ServiceContextID:  s.ServiceContextID,
+		ServiceIdentifier: s.ServiceIdentifier,
+		RatingGroup:       s.RatingGroup,
+		State:             s.State,
+		RequestNumber:     s.RequestNumber,
+		Granted:           s.Granted,
+		Used:              s.Used,
+		ValidityTime:      s.ValidityTime,
+		ResultCode:        s.ResultCode,
+		Created:           s.Created,
+		Updated:           s.Updated,
+	}
+}
+
+// request builds the next CCR of the session. It is called with s.mu
+// held.
+func (s *Session) request(requestType int32, requested, used *Units) func(string) *diam.Message {
+	return func(sessionID string) *diam.Message {
+		r := newCCR(sessionID, s.Subscriber, requestType)
+		r.NewAVP(avp.ServiceContextID, avp.Mbit, 0, datatype.UTF8String(s.ServiceContextID))
+		r.NewAVP(avp.CCRequestNumber, avp.Mbit, 0, datatype.Unsigned32(s.RequestNumber))
+		r.NewAVP(avp.EventTimestamp, avp.Mbit, 0, datatype.Time(time.Now()))
+		if requestType == InitialRequest {
+			r.NewAVP(avp.MultipleServicesIndicator, avp.Mbit, 0, datatype.Enumerated(1))
+		}
+		if requestType == TerminationRequest {
+			r.NewAVP(avp.TerminationCause, avp.Mbit, 0, datatype.Enumerated(s.cause))
+		}
+
+		mscc := &diam.GroupedAVP{}
+		if requested != nil {
+			mscc.AVP = append(mscc.AVP, requested.avp(avp.RequestedServiceUnit))
+		}
+		if used != nil {
+			mscc.AVP = append(mscc.AVP, used.avp(avp.UsedServiceUnit))
+		}
+		if s.ServiceIdentifier > 0 {
+			mscc.AVP = append(mscc.AVP, diam.NewAVP(avp.ServiceIdentifier, avp.Mbit, 0, datatype.Unsigned32(s.ServiceIdentifier)))
+		}
+		if s.RatingGroup > 0 {
+			mscc.AVP = append(mscc.AVP, diam.NewAVP(avp.RatingGroup, avp.Mbit, 0, datatype.Unsigned32(s.RatingGroup)))
+		}
+		r.NewAVP(avp.MultipleServicesCreditControl, avp.Mbit, 0, mscc)
+		r.NewAVP(avp.DestinationHost, avp.Mbit, 0, datatype.OctetString("cbp211"))
+		return r
+	}
+}
+
+// send issues the next CCR of the session and applies its CCA. It is
+// called with s.mu held.
+func (s *Session) send(reqID string, requestType int32, requested, used *Units) error {
+	// CC-Request-Number only moves on when the CCR reaches the peer, so a
+	// request refused before it is written is retried with the same one.
+	m, err := exchangeSent(reqID, s.Corp, s.ID, s.request(requestType, requested, used), func(*diam.Message) {
+		s.RequestNumber++
+	})
+	s.Updated = time.Now()
+	if m == nil {
+		return err
+	}
+
+	var a sessionCCA
+	m.Unmarshal(&a)
+	s.ResultCode = a.ResultCode
+	if used != nil {
+		s.Used = s.Used.add(*used)
+	}
+	s.Granted = Units{}
+	for _, mscc := range a.MSCC {
+		s.Granted = s.Granted.add(mscc.Granted)
+		s.ValidityTime = mscc.ValidityTime
+	}
+	return err
+}
+
+// OpenSession sends CCR-Initial for a new session and keeps it in the
+// session store when the OCS accepts it.
+func OpenSession(reqID string, s *Session, requested Units) error {
+	s.ID = newSessionID()
+	s.State = SessionPending
+	s.Created = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessionsLock.Lock()
+	sessions[s.ID] = s
+	sessionsLock.Unlock()
+
+	if err := s.send(reqID, InitialRequest, &requested, nil); err != nil {
+		s.State = SessionClosed
+		dropSession(s.ID)
+		return err
+	}
+	s.State = SessionOpen
+	logger.Info("session opened", "request_id", reqID, "session_id", s.ID, "corp", s.Corp)
+	return nil
+}
+
+// Update reports used units and asks for more with CCR-Update.
+func (s *Session) Update(reqID string, requested, used Units) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.State != SessionOpen {
+		return ErrSessionClosed
+	}
+	return s.send(reqID, UpdateRequest, &requested, &used)
+}
+
+// reAuth sends the CCR-Update a Re-Auth-Request asks for, reporting the
+// units used in the session. They were counted when the client reported
+// them, so they are not added to Used again.
+func (s *Session) reAuth(reqID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.State != SessionOpen {
+		return ErrSessionClosed
+	}
+	used := s.Used
+	err := s.send(reqID, UpdateRequest, &Units{}, &used)
+	s.Used = used
+	return err
+}
+
+// Terminate reports the final used units with CCR-Termination and
+// removes the session from the store.
+func (s *Session) Terminate(reqID string, used Units) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.State != SessionOpen {
+		return ErrSessionClosed
+	}
+	return s.terminate(reqID, used, terminationLogout)
+}
+
+// terminate sends CCR-Termination with cause and closes the session. It
+// is called with s.mu held.
+func (s *Session) terminate(reqID string, used Units, cause int32) error {
+	s.cause = cause
+	err := s.send(reqID, TerminationRequest, nil, &used)
+	s.State = SessionClosed
+	dropSession(s.ID)
+	logger.Info("session closed", "request_id", reqID, "session_id", s.ID, "corp", s.Corp, "cause", cause)
+	return err
+}
+
+// expires returns when s is terminated unless it is updated. The OCS
+// expects an update within the Validity-Time it granted. It is called
+// with s.mu held.
+func (s *Session) expires() time.Time {
+	if s.ValidityTime > 0 {
+		return s.Updated.Add(time.Duration(s.ValidityTime)*time.Second + sessionGrace)
+	}
+	return s.Updated.Add(sessionIdle)
+}
+
+// expire terminates s with DIAMETER_SESSION_TIMEOUT when its client
+// stopped updating it.
+func (s *Session) expire(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.State != SessionOpen || now.Before(s.expires()) {
+		return
+	}
+	logger.Warn("session expired", "session_id", s.ID, "corp", s.Corp, "updated", s.Updated)
+	if err := s.terminate("", Units{}, terminationTimeout); err != nil {
+		logger.Warn("expired session termination failed", "session_id", s.ID, "err", err)
+	}
+}
+
+// sweepSessions terminates the sessions expired at now.
+func sweepSessions(now time.Time) {
+	sessionsLock.Lock()
+	all := make([]*Session, 0, len(sessions))
+	for _, s := range sessions {
+		all = append(all, s)
+	}
+	sessionsLock.Unlock()
+	for _, s := range all {
+		s.expire(now)
+	}
+}
+
+func expireSessions() {
+	for now := range time.Tick(time.Minute) {
+		sweepSessions(now)
+	}
+}
+
+type SessionRequest struct {
+	ServiceContextID  string `json:"serviceContextId"`
+	ServiceIdentifier uint32 `json:"serviceIdentifier"`
+	RatingGroup       uint32 `json:"ratingGroup"`
+	Requested         Units  `json:"requested"`
+	Used              Units  `json:"used"`
+}
+
+// defaultServiceContext is used when a session request names none.
+const defaultServiceContext = "32251@3gpp.org"
+
+func sessionError(w rest.ResponseWriter, err error) {
+	switch err {
+	case ErrNoSession:
+		rest.Error(w, err.Error(), http.StatusNotFound)
+	case ErrSessionClosed:
+		rest.Error(w, err.Error(), http.StatusConflict)
+	default:
+		httpError(w, err)
+	}
+}
+
+// CreateSession opens a credit-control session for a subscriber.
+func CreateSession(w rest.ResponseWriter, req *rest.Request) {
+	var body SessionRequest
+	if err := req.DecodeJsonPayload(&body); err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.ServiceContextID == "" {
+		body.ServiceContextID = defaultServiceContext
+	}
+
+	s := &Session{
+		Corp:              req.PathParam("corp"),
+		Subscriber:        req.PathParam("subr"),
+		ServiceContextID:  body.ServiceContextID,
+		ServiceIdentifier: body.ServiceIdentifier,
+		RatingGroup:       body.RatingGroup,
+	}
+	if err := OpenSession(logger.RequestID(req), s, body.Requested); err != nil {
+		sessionError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.WriteJson(s.snapshot())
+}
+
+// GetSession returns the state of an open session.
+func GetSession(w rest.ResponseWriter, req *rest.Request) {
+	s := lookupSession(req.PathParam("id"))
+	if s == nil {
+		sessionError(w, ErrNoSession)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.WriteJson(s.snapshot())
+}
+
+// UpdateSession reports used units and requests more.
+func UpdateSession(w rest.ResponseWriter, req *rest.Request) {
+	s := lookupSession(req.PathParam("id"))
+	if s == nil {
+		sessionError(w, ErrNoSession)
+		return
+	}
+	var body SessionRequest
+	if err := req.DecodeJsonPayload(&body); err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.Update(logger.RequestID(req), body.Requested, body.Used); err != nil {
+		sessionError(w, err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.WriteJson(s.snapshot())
+}
+
+// DeleteSession terminates a session, reporting the final used units
+// given in the optional body.
+func DeleteSession(w rest.ResponseWriter, req *rest.Request) {
+	s := lookupSession(req.PathParam("id"))
+	if s == nil {
+		sessionError(w, ErrNoSession)
+		return
+	}
+	var body SessionRequest
+	if req.ContentLength > 0 {
+		if err := req.DecodeJsonPayload(&body); err != nil {
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := s.Terminate(logger.RequestID(req), body.Used); err != nil {
+		sessionError(w, err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.WriteJson(s.snapshot())
+}
@@ -0,0 +1,61 @@
+package balance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+func TestSweepSessions(t *testing.T) {
+	now := time.Now()
+	for _, tt := range []struct {
+		name     string
+		state    string
+		validity uint32
+		idle     time.Duration
+		expired  bool
+	}{
+		{"idle", SessionOpen, 0, sessionIdle + time.Second, true},
+		{"active", SessionOpen, 0, sessionIdle - time.Second, false},
+		{"validity passed", SessionOpen, 60, time.Minute + sessionGrace + time.Second, true},
+		{"within validity", SessionOpen, 60, time.Minute + sessionGrace - time.Second, false},
+		{"long validity", SessionOpen, 3600, sessionIdle + time.Second, false},
+		{"pending", SessionPending, 0, 2 * sessionIdle, false},
+	} {
+		s := &Session{
+			ID:           "dtac.co.th;OMR;" + tt.name,
+			State:        tt.state,
+			ValidityTime: tt.validity,
+			Updated:      now.Add(-tt.idle),
+		}
+		sessionsLock.Lock()
+		sessions[s.ID] = s
+		sessionsLock.Unlock()
+
+		sweepSessions(now)
+
+		if got := lookupSession(s.ID) == nil; got != tt.expired {
+			t.Error("It should expire "+tt.name+" ", tt.expired, " but was ", got)
+		}
+		if tt.expired && (s.State != SessionClosed || s.cause != terminationTimeout) {
+			t.Error("It should close "+tt.name+" on timeout but was ", s.State, s.cause)
+		}
+		dropSession(s.ID)
+	}
+}
+
+func TestSessionCorp(t *testing.T) {
+	s := &Session{ID: "dtac.co.th;OMR;corp", Corp: "dtac"}
+	sessionsLock.Lock()
+	sessions[s.ID] = s
+	sessionsLock.Unlock()
+	defer dropSession(s.ID)
+
+	for id, want := range map[string]string{s.ID: "dtac", "dtac.co.th;OMR;none": ""} {
+		req := &rest.Request{PathParams: map[string]string{"id": id}}
+		if got := SessionCorp(req); got != want {
+			t.Error("It should be \""+want+"\" for "+id+" but was ", got)
+		}
+	}
+}
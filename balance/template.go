@@ -0,0 +1,253 @@
+package balance
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
+	"github.com/fiorix/go-diameter/diam/datatype"
+	"github.com/fiorix/go-diameter/diam/dict"
+
+	"server/config"
+	"server/logger"
+)
+
+// creditControlApp is the Diameter Credit-Control Application Id.
+const creditControlApp = 4
+
+// Operations that can be given a request template.
+const (
+	OpBalance = "balance"
+	OpDebit   = "debit"
+	OpRefund  = "refund"
+	OpPrice   = "price"
+	OpCheck   = "check"
+)
+
+var placeholder = regexp.MustCompile(`\{\{(\w+)\}\}`)
+
+// templates maps a corp to its request templates by operation.
+var templates map[string]map[string][]config.TemplateAVP
+
+// templateFor returns the template of op for corp, falling back to the
+// default corp like peerFor.
+func templateFor(corp, op string) []config.TemplateAVP {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	t, ok := templates[corp]
+	if !ok {
+		t = templates[defaultCorp]
+	}
+	return t[op]
+}
+
+// requestBuilder returns a function building the request of op for
+// corp. It renders the configured template with vars when there is one
+// and calls fallback otherwise. vars gets "sessionId" and "now" added.
+// A template that fails to render builds no request.
+func requestBuilder(corp, op string, vars map[string]string, fallback func(sessionID string) *diam.Message) func(string) *diam.Message {
+	t := templateFor(corp, op)
+	if t == nil {
+		return fallback
+	}
+	return func(sessionID string) *diam.Message {
+		v := map[string]string{
+			"sessionId": sessionID,
+			"now":       time.Now().Format(time.RFC3339),
+		}
+		for k, s := range vars {
+			v[k] = s
+		}
+		m, err := render(dict.Default, diam.CreditControl, creditControlApp, t, v)
+		if err != nil {
+			// Templates are checked at start-up, so only a bad
+			// value such as a non-numeric subscriber ends here.
+			logger.Error("template render failed", "corp", corp, "op", op, "err", err)
+			return nil
+		}
+		return m
+	}
+}
+
+// render builds the request cmd of application app from the AVP tree
+// t.
+func render(dp *dict.Parser, cmd, app uint32, t []config.TemplateAVP, vars map[string]string) (*diam.Message, error) {
+	m := diam.NewRequest(cmd, app, nil)
+	for _, ta := range t {
+		a, err := renderAVP(dp, app, ta, vars)
+		if err != nil {
+			return nil, err
+		}
+		m.AddAVP(a)
+	}
+	return m, nil
+}
+
+func renderAVP(dp *dict.Parser, app uint32, ta config.TemplateAVP, vars map[string]string) (*diam.AVP, error) {
+	d, err := dp.FindAVP(app, ta.Name)
+	if err != nil {
+		return nil, fmt.Errorf("template: %s: %v", ta.Name, err)
+	}
+	var flags uint8
+	if strings.Contains(d.Must, "M") {
+		flags |= avp.Mbit
+	}
+	if d.VendorID != 0 {
+		flags |= avp.Vbit
+	}
+
+	if d.Data.Type == datatype.GroupedType {
+		g := &diam.GroupedAVP{}
+		for _, c := range ta.AVPs {
+			a, err := renderAVP(dp, app, c, vars)
+			if err != nil {
+				return nil, err
+			}
+			g.AVP = append(g.AVP, a)
+		}
+		return diam.NewAVP(d.Code, flags, d.VendorID, g), nil
+	}
+
+	s, err := expand(ta.Value, vars)
+	if err != nil {
+		return nil, fmt.Errorf("template: %s: %v", ta.Name, err)
+	}
+	v, err := convert(d, s)
+	if err != nil {
+		return nil, fmt.Errorf("template: %s: %v", ta.Name, err)
+	}
+	return diam.NewAVP(d.Code, flags, d.VendorID, v), nil
+}
+
+// expand replaces the placeholders of s with vars.
+func expand(s string, vars map[string]string) (string, error) {
+	var err error
+	s = placeholder.ReplaceAllStringFunc(s, func(p string) string {
+		name := p[2 : len(p)-2]
+		v, ok := vars[name]
+		if !ok {
+			err = fmt.Errorf("unknown placeholder %s", p)
+		}
+		return v
+	})
+	return s, err
+}
+
+// convert reads s as a value of the dictionary type of d. Enumerated
+// values may be given by name.
+func convert(d *dict.AVP, s string) (datatype.Type, error) {
+	switch d.Data.Type {
+	case datatype.OctetStringType:
+		return datatype.OctetString(s), nil
+	case datatype.DiameterIdentityType:
+		return datatype.DiameterIdentity(s), nil
+	case datatype.DiameterURIType:
+		return datatype.DiameterURI(s), nil
+	case datatype.UTF8StringType:
+		return datatype.UTF8String(s), nil
+	case datatype.IPFilterRuleType:
+		return datatype.IPFilterRule(s), nil
+	case datatype.QoSFilterRuleType:
+		return datatype.QoSFilterRule(s), nil
+	case datatype.AddressType, datatype.IPv4Type:
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("bad address %q", s)
+		}
+		if d.Data.Type == datatype.IPv4Type {
+			return datatype.IPv4(ip), nil
+		}
+		return datatype.Address(ip), nil
+	case datatype.TimeType:
+		t, err := time.Parse(time.RFC3339, s)
+		return datatype.Time(t), err
+	case datatype.EnumeratedType:
+		for _, e := range d.Data.Enum {
+			if e.Name == s {
+				return datatype.Enumerated(e.Code), nil
+			}
+		}
+		n, err := strconv.ParseInt(s, 10, 32)
+		return datatype.Enumerated(n), err
+	case datatype.Integer32Type:
+		n, err := strconv.ParseInt(s, 10, 32)
+		return datatype.Integer32(n), err
+	case datatype.Integer64Type:
+		n, err := strconv.ParseInt(s, 10, 64)
+		return datatype.Integer64(n), err
+	case datatype.Unsigned32Type:
+		n, err := strconv.ParseUint(s, 10, 32)
+		return datatype.Unsigned32(n), err
+	case datatype.Unsigned64Type:
+		n, err := strconv.ParseUint(s, 10, 64)
+		return datatype.Unsigned64(n), err
+	case datatype.Float32Type:
+		f, err := strconv.ParseFloat(s, 32)
+		return datatype.Float32(f), err
+	case datatype.Float64Type:
+		f, err := strconv.ParseFloat(s, 64)
+		return datatype.Float64(f), err
+	}
+	return nil, fmt.Errorf("unsupported type %s", d.Data.TypeName)
+}
+
+// loadTemplates checks the templates of every corp against the
+// dictionary dp and returns them by corp.
+func loadTemplates(dp *dict.Parser, cfg map[string]config.Corp) (map[string]map[string][]config.TemplateAVP, error) {
+	t := make(map[string]map[string][]config.TemplateAVP)
+	for name, corp := range cfg {
+		for op, tmpl := range corp.Templates {
+			if _, ok := opVars[op]; !ok {
+				return nil, fmt.Errorf("corp %s: unknown operation %q", name, op)
+			}
+			if err := checkTemplate(dp, op, tmpl); err != nil {
+				return nil, fmt.Errorf("corp %s, %s: %v", name, op, err)
+			}
+		}
+		t[name] = corp.Templates
+	}
+	return t, nil
+}
+
+// sessionIDValue is the only Session-Id a template may send, so that
+// the CCA is delivered to the request waiting on it.
+const sessionIDValue = "{{sessionId}}"
+
+// checkTemplate renders the template t of op with dummy values to catch
+// unknown AVPs, placeholders op does not fill in and values that do not
+// fit their type, and checks that it sends the Session-Id of the
+// request.
+func checkTemplate(dp *dict.Parser, op string, t []config.TemplateAVP) error {
+	vars := map[string]string{"sessionId": "0", "now": time.Now().Format(time.RFC3339)}
+	for _, k := range opVars[op] {
+		vars[k] = "0"
+	}
+	m, err := render(dp, diam.CreditControl, creditControlApp, t, vars)
+	if err != nil {
+		return err
+	}
+	if _, err = m.FindAVP(avp.SessionID); err != nil {
+		return fmt.Errorf("template has no Session-Id")
+	}
+	for _, ta := range t {
+		if ta.Name == "Session-Id" && ta.Value != sessionIDValue {
+			return fmt.Errorf("template Session-Id must be %s, not %q", sessionIDValue, ta.Value)
+		}
+	}
+	return nil
+}
+
+// opVars lists the placeholders each operation fills in besides
+// sessionId and now.
+var opVars = map[string][]string{
+	OpBalance: {"subscriber"},
+	OpCheck:   {"subscriber", "amount", "exponent", "currency"},
+	OpDebit:   {"subscriber", "amount", "exponent", "currency", "serviceIdentifier", "ratingGroup"},
+	OpRefund:  {"subscriber", "amount", "exponent", "currency", "serviceIdentifier", "ratingGroup"},
+	OpPrice:   {"subscriber", "serviceIdentifier", "ratingGroup", "units"},
+}
@@ -0,0 +1,163 @@
+package balance
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/datatype"
+	"github.com/fiorix/go-diameter/diam/dict"
+
+	"server/config"
+	"server/dictionary"
+)
+
+func TestExpand(t *testing.T) {
+	vars := map[string]string{"subscriber": "66812345678", "sessionId": "s1"}
+
+	s, err := expand("{{sessionId}};{{subscriber}}", vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "s1;66812345678" {
+		t.Error("It should be s1;66812345678 but was ", s)
+	}
+
+	s, _ = expand("QueryBalance@huawei.com", vars)
+	if s != "QueryBalance@huawei.com" {
+		t.Error("It should keep text without placeholders but was ", s)
+	}
+
+	if _, err = expand("{{msisdn}}", vars); err == nil {
+		t.Error("It should reject an unknown placeholder")
+	}
+}
+
+func TestConvert(t *testing.T) {
+	enum := dict.Data{Type: datatype.EnumeratedType, Enum: []*dict.Enum{{Name: "EVENT_REQUEST", Code: 4}}}
+	for _, tt := range []struct {
+		data dict.Data
+		s    string
+		want datatype.Type
+		fail bool
+	}{
+		{dict.Data{Type: datatype.UTF8StringType}, "66812345678", datatype.UTF8String("66812345678"), false},
+		{dict.Data{Type: datatype.OctetStringType}, "cbp211", datatype.OctetString("cbp211"), false},
+		{dict.Data{Type: datatype.DiameterIdentityType}, "dtac.co.th", datatype.DiameterIdentity("dtac.co.th"), false},
+		{dict.Data{Type: datatype.Unsigned32Type}, "4", datatype.Unsigned32(4), false},
+		{dict.Data{Type: datatype.Unsigned32Type}, "-1", nil, true},
+		{dict.Data{Type: datatype.Unsigned64Type}, "18446744073709551615", datatype.Unsigned64(18446744073709551615), false},
+		{dict.Data{Type: datatype.Integer32Type}, "-2", datatype.Integer32(-2), false},
+		{dict.Data{Type: datatype.Integer64Type}, "x", nil, true},
+		{enum, "EVENT_REQUEST", datatype.Enumerated(4), false},
+		{enum, "2", datatype.Enumerated(2), false},
+		{enum, "UNKNOWN", nil, true},
+		{dict.Data{Type: datatype.AddressType}, "10.89.111.40", datatype.Address(net.ParseIP("10.89.111.40")), false},
+		{dict.Data{Type: datatype.AddressType}, "cbp211", nil, true},
+		{dict.Data{Type: datatype.TimeType}, "2026-10-19T00:00:00Z", datatype.Time(time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)), false},
+		{dict.Data{Type: datatype.TimeType}, "yesterday", nil, true},
+	} {
+		v, err := convert(&dict.AVP{Name: "Test", Data: tt.data}, tt.s)
+		if tt.fail {
+			if err == nil {
+				t.Error("It should refuse ", tt.s, " but was ", v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error("It should convert ", tt.s, " but failed: ", err)
+			continue
+		}
+		if !reflect.DeepEqual(v, tt.want) {
+			t.Error("It should be ", tt.want, " but was ", v)
+		}
+	}
+}
+
+// balanceTemplate is a minimal balance query template.
+func balanceTemplate(sessionID string) []config.TemplateAVP {
+	return []config.TemplateAVP{
+		{Name: "Session-Id", Value: sessionID},
+		{Name: "Auth-Application-Id", Value: "4"},
+		{Name: "CC-Request-Type", Value: "EVENT_REQUEST"},
+		{Name: "Subscription-Id", AVPs: []config.TemplateAVP{
+			{Name: "Subscription-Id-Type", Value: "0"},
+			{Name: "Subscription-Id-Data", Value: "{{subscriber}}"},
+		}},
+	}
+}
+
+func TestRender(t *testing.T) {
+	dp := dictionary.Load()
+	vars := map[string]string{"sessionId": "dtac.co.th;OMR1", "subscriber": "66812345678"}
+	m, err := render(dp, diam.CreditControl, creditControlApp, balanceTemplate("{{sessionId}}"), vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r struct {
+		SessionID      string `avp:"Session-Id"`
+		AuthAppID      uint32 `avp:"Auth-Application-Id"`
+		RequestType    int    `avp:"CC-Request-Type"`
+		SubscriptionID struct {
+			Type int    `avp:"Subscription-Id-Type"`
+			Data string `avp:"Subscription-Id-Data"`
+		} `avp:"Subscription-Id"`
+	}
+	if err := m.Unmarshal(&r); err != nil {
+		t.Fatal(err)
+	}
+	if r.SessionID != "dtac.co.th;OMR1" || r.AuthAppID != 4 || r.RequestType != EventRequest || r.SubscriptionID.Data != "66812345678" {
+		t.Errorf("Unexpected request %+v", r)
+	}
+
+	for name, tmpl := range map[string][]config.TemplateAVP{
+		"unknown AVP":         {{Name: "No-Such-AVP", Value: "1"}},
+		"bad value":           {{Name: "Auth-Application-Id", Value: "{{subscriber}}x"}},
+		"unknown placeholder": {{Name: "Session-Id", Value: "{{msisdn}}"}},
+	} {
+		if _, err := render(dp, diam.CreditControl, creditControlApp, tmpl, vars); err == nil {
+			t.Error("It should refuse a template with ", name)
+		}
+	}
+}
+
+func TestCheckTemplateSessionID(t *testing.T) {
+	dp := dictionary.Load()
+	if err := checkTemplate(dp, OpBalance, balanceTemplate("{{sessionId}}")); err != nil {
+		t.Error("It should accept the template but was ", err)
+	}
+	for _, v := range []string{"dtac.co.th;fixed", "{{sessionId}};1", "{{subscriber}}"} {
+		if err := checkTemplate(dp, OpBalance, balanceTemplate(v)); err == nil {
+			t.Error("It should refuse the Session-Id ", v)
+		}
+	}
+	if err := checkTemplate(dp, OpBalance, balanceTemplate("{{sessionId}}")[1:]); err == nil {
+		t.Error("It should refuse a template without Session-Id")
+	}
+}
+
+func TestCheckTemplateVars(t *testing.T) {
+	dp := dictionary.Load()
+	units := append(balanceTemplate("{{sessionId}}"), config.TemplateAVP{Name: "Requested-Service-Unit", AVPs: []config.TemplateAVP{
+		{Name: "CC-Service-Specific-Units", Value: "{{units}}"},
+	}})
+	if err := checkTemplate(dp, OpPrice, units); err != nil {
+		t.Error("It should accept {{units}} for price but was ", err)
+	}
+	for _, op := range []string{OpBalance, OpCheck, OpDebit} {
+		if err := checkTemplate(dp, op, units); err == nil {
+			t.Error("It should refuse {{units}} for " + op)
+		}
+	}
+}
+
+func TestLoadTemplatesUnknownOp(t *testing.T) {
+	cfg := map[string]config.Corp{"dtac": {Templates: map[string][]config.TemplateAVP{
+		"debt": balanceTemplate("{{sessionId}}"),
+	}}}
+	if _, err := loadTemplates(nil, cfg); err == nil {
+		t.Error("It should refuse the unknown operation debt")
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/fiorix/go-diameter/diam"
+
+	"server/balance"
+	"server/config"
+	"server/diameter"
+	"server/logger"
+)
+
+// Exit codes of the query and send subcommands. An answer with a
+// Result-Code other than DIAMETER_SUCCESS exits with its class: 3 for
+// protocol errors, 4 for transient and 5 for permanent failures.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+type clientFlags struct {
+	config  *string
+	format  *string
+	timeout *time.Duration
+	verbose *bool
+}
+
+func newClientFlags(fs *flag.FlagSet) clientFlags {
+	return clientFlags{
+		config:  fs.String("config", "", "path to the JSON configuration file"),
+		format:  fs.String("format", "tree", "answer output: tree or json"),
+		timeout: fs.Duration("timeout", 10*time.Second, "how long to wait for the peer to come up"),
+		verbose: fs.Bool("v", false, "log at info level"),
+	}
+}
+
+// parse parses args into fs and exits on an unknown output format.
+func (f clientFlags) parse(fs *flag.FlagSet, args []string) {
+	fs.Parse(args)
+	if *f.format != "tree" && *f.format != "json" {
+		fmt.Fprintf(os.Stderr, "unknown format %q: want tree or json\n", *f.format)
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+}
+
+// connect starts the client with cfg and waits for a peer of corp to
+// complete CER/CEA.
+func (f clientFlags) connect(cfg *config.Config, corp string) {
+	if !*f.verbose {
+		logger.SetLevel(logger.LevelWarn)
+	}
+	balance.Start(cfg)
+	if err := balance.WaitReady(corp, *f.timeout); err != nil {
+		fmt.Fprintln(os.Stderr, "no peer for", corp, "came up:", err)
+		os.Exit(exitFailure)
+	}
+}
+
+// print writes the answer m and exits with a code reflecting err.
+func (f clientFlags) print(m *diam.Message, err error) {
+	if m != nil {
+		if *f.format == "json" {
+			b, _ := json.MarshalIndent(diameter.Decode(m), "", "  ")
+			fmt.Println(string(b))
+		} else {
+			fmt.Print(diameter.Format(m))
+		}
+	}
+	if err == nil {
+		os.Exit(exitOK)
+	}
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(exitCode(err))
+}
+
+// exitCode maps err to the exit code of the query and send subcommands.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	if re, ok := err.(balance.ResultError); ok && re.Code >= 3000 && re.Code < 6000 {
+		return int(re.Code / 1000)
+	}
+	return exitFailure
+}
+
+// runQuery sends a balance CCR for one subscriber and prints the CCA.
+func runQuery(args []string) {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	f := newClientFlags(fs)
+	corp := fs.String("corp", "", "corp whose OCS is queried")
+	subr := fs.String("subr", "", "subscriber number")
+	f.parse(fs, args)
+	if *corp == "" || *subr == "" {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	f.connect(loadConfig(*f.config), *corp)
+	f.print(balance.QueryAnswer("cli", *corp, *subr))
+}
+
+// runSend sends the request described in a JSON file, in the format of
+// the /admin/raw endpoint, and prints the answer.
+func runSend(args []string) {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	f := newClientFlags(fs)
+	file := fs.String("json", "", "JSON file describing the request")
+	corp := fs.String("corp", "", "corp whose OCS receives the request, overriding the file")
+	f.parse(fs, args)
+	if *file == "" {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	var r balance.RawRequest
+	b, err := ioutil.ReadFile(*file)
+	if err == nil {
+		err = json.Unmarshal(b, &r)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bad request file:", err)
+		os.Exit(exitUsage)
+	}
+	if *corp != "" {
+		r.Corp = *corp
+	}
+
+	cfg := loadConfig(*f.config)
+	if r.Corp == "" {
+		r.Corp = cfg.DefaultCorp
+	}
+	f.connect(cfg, r.Corp)
+	f.print(balance.SendRaw("cli", r))
+}
+
+// loadConfig reads filename, or returns the defaults when it is empty,
+// and sets up logging.
+func loadConfig(filename string) *config.Config {
+	cfg, level, err := readConfig(filename)
+	if err != nil {
+		logger.Fatal("config load failed", "file", filename, "err", err)
+	}
+	logger.SetLevel(level)
+	logger.SetTrace(cfg.Log.Trace)
+	return cfg
+}
+
+// readConfig reads filename, or returns the defaults when it is empty,
+// and parses its log level.
+func readConfig(filename string) (*config.Config, logger.Level, error) {
+	cfg := config.Default()
+	if filename != "" {
+		var err error
+		if cfg, err = config.Load(filename); err != nil {
+			return nil, 0, err
+		}
+	}
+	level, err := logger.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		return nil, 0, err
+	}
+	return cfg, level, nil
+}
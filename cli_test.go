@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"server/balance"
+)
+
+func TestExitCode(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want int
+	}{
+		{nil, exitOK},
+		{balance.ResultError{Code: 2002}, exitFailure},
+		{balance.ResultError{Code: 3004}, 3},
+		{balance.ResultError{Code: 4012}, 4},
+		{balance.ResultError{Code: 5030}, 5},
+		{balance.ResultError{Code: 6000}, exitFailure},
+		{balance.ErrTimeout, exitFailure},
+	} {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Error("It should be ", tt.want, " for ", tt.err, " but was ", got)
+		}
+	}
+}
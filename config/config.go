@@ -0,0 +1,255 @@
+// Package config holds the service configuration loaded at start-up.
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+type Config struct {
+	Listen string `json:"listen"`
+
+	// Corps maps a corp name to the OCS peers serving it. Requests for
+	// a corp that is not listed go to DefaultCorp.
+	Corps       map[string]Corp `json:"corps"`
+	DefaultCorp string          `json:"defaultCorp"`
+
+	// Dictionaries lists extra dictionary files loaded after the
+	// built-in ones.
+	Dictionaries []string `json:"dictionaries"`
+
+	// AnswerTimeout is how long a request waits for its answer.
+	AnswerTimeout Duration `json:"answerTimeout"`
+
+	// Validation checks messages against the dictionary rules: "off",
+	// "log" to log violations, or "strict" to also refuse to send
+	// invalid requests and to fail requests whose answer is invalid.
+	Validation string `json:"validation"`
+
+	Cache   Cache   `json:"cache"`
+	Log     Log     `json:"log"`
+	Capture Capture `json:"capture"`
+	Replay  Replay  `json:"replay"`
+	Notify  Notify  `json:"notify"`
+	Admin   Admin   `json:"admin"`
+	Auth    Auth    `json:"auth"`
+	CORS    CORS    `json:"cors"`
+	Limits  Limits  `json:"limits"`
+}
+
+// Limits protects the OCS. Client and Corp are the request rates
+// allowed to each API client and to the CCRs of each corp, with
+// overrides by name in Clients and Corps. MaxOutstanding caps the CCRs
+// awaiting an answer on one peer connection, and a peer that answers
+// DIAMETER_TOO_BUSY (3004) takes no new CCRs for BusyBackoff. Requests
+// over a limit are refused with 429 and Retry-After.
+type Limits struct {
+	Client         Rate            `json:"client"`
+	Clients        map[string]Rate `json:"clients"`
+	Corp           Rate            `json:"corp"`
+	Corps          map[string]Rate `json:"corps"`
+	MaxOutstanding int             `json:"maxOutstanding"`
+	BusyBackoff    Duration        `json:"busyBackoff"`
+}
+
+// Rate is a token bucket refilled with Rate tokens per second up to
+// Burst, or Rate when Burst is unset. A zero Rate is unlimited.
+type Rate struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// CORS controls which browser origins may call the API. Origins lists
+// them as scheme://host[:port]; a "*." label allows every subdomain, as
+// in "https://*.example.com", and "*" alone allows any origin but not
+// together with Credentials. Requests from other origins are refused.
+type CORS struct {
+	Origins     []string `json:"origins"`
+	Methods     []string `json:"methods"`
+	Headers     []string `json:"headers"`
+	Credentials bool     `json:"credentials"`
+	MaxAge      Duration `json:"maxAge"`
+}
+
+// Auth authenticates API clients by API key, sent in the X-API-Key
+// header, or by a JWT bearer token signed with a key of the JWKS file.
+// Clients maps a client name, which is also the JWT subject, to what it
+// may call. The API is open to anyone while Clients is empty.
+type Auth struct {
+	JWKS     string            `json:"jwks"`
+	Issuer   string            `json:"issuer"`
+	Audience string            `json:"audience"`
+	Clients  map[string]Client `json:"clients"`
+}
+
+// Client lists the API keys of a client and the corps and operations
+// ("balance", "check", "price", "debit", "refund", "session" or
+// "stats") it may use. "*" allows every corp or operation.
+type Client struct {
+	Keys       []string `json:"keys"`
+	Corps      []string `json:"corps"`
+	Operations []string `json:"operations"`
+}
+
+// Admin protects the /admin/ endpoints with a bearer token. They are
+// all refused while Token is empty.
+type Admin struct {
+	Token string `json:"token"`
+}
+
+// Log selects the log level. Trace additionally logs every Diameter
+// message in full, with subscriber numbers masked, at debug level.
+type Log struct {
+	Level string `json:"level"`
+	Trace bool   `json:"trace"`
+}
+
+type Corp struct {
+	Peers []string `json:"peers"`
+
+	// Templates maps an operation ("balance", "debit", "refund",
+	// "price" or "check") to the CCR sent for it, replacing the
+	// built-in Huawei layout.
+	Templates map[string][]TemplateAVP `json:"templates"`
+
+	// Mapping, when set, declares the JSON returned for a balance
+	// query instead of the built-in Huawei Balance-Information layout.
+	Mapping []FieldMapping `json:"mapping"`
+}
+
+// FieldMapping fills the JSON field Field from the answer AVP at Path,
+// a list of dictionary AVP names separated by ">". A name followed by
+// [*] selects every instance and makes the field a list; [n] selects
+// the nth one. Type converts the value to "string", "int", "float",
+// "bool" or "time" (RFC 3339); by default it is kept as decoded.
+type FieldMapping struct {
+	Field string `json:"field"`
+	Path  string `json:"path"`
+	Type  string `json:"type,omitempty"`
+}
+
+// TemplateAVP is an AVP of a request template, named as in the
+// dictionary. Value is converted to the AVP's dictionary type after
+// placeholders such as {{subscriber}}, {{sessionId}} and {{now}} are
+// replaced; grouped AVPs list their members in AVPs instead.
+type TemplateAVP struct {
+	Name  string        `json:"name"`
+	Value string        `json:"value,omitempty"`
+	AVPs  []TemplateAVP `json:"avps,omitempty"`
+}
+
+// Cache controls the short-lived balance cache. A zero TTL ("0s")
+// disables caching; concurrent identical queries are still coalesced.
+// The default TTL is 5s, so a balance may be up to 5s old unless the
+// client sends Cache-Control: no-cache.
+type Cache struct {
+	TTL                  Duration `json:"ttl"`
+	StaleWhileRevalidate Duration `json:"staleWhileRevalidate"`
+	StaleIfError         Duration `json:"staleIfError"`
+}
+
+// Capture writes Diameter traffic to rotating pcap ("pcap") or hex
+// dump ("hex") files in Dir, optionally only for one corp or
+// subscriber. It can be switched at runtime via /admin/capture.
+type Capture struct {
+	Mode       string `json:"mode"`
+	Dir        string `json:"dir"`
+	MaxSize    int64  `json:"maxSize"`
+	MaxFiles   int    `json:"maxFiles"`
+	Corp       string `json:"corp"`
+	Subscriber string `json:"subscriber"`
+}
+
+// Replay either records CCR/CCA pairs, with subscriber numbers
+// anonymized, to File ("record") or answers every request from File
+// instead of the OCS ("replay").
+type Replay struct {
+	Mode string `json:"mode"`
+	File string `json:"file"`
+}
+
+// Notify posts a JSON notification to Webhook when the OCS sends a
+// Re-Auth-Request or Abort-Session-Request for an open session.
+type Notify struct {
+	Webhook string   `json:"webhook"`
+	Timeout Duration `json:"timeout"`
+}
+
+// Duration is a time.Duration read from a string such as "30s".
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Default returns the configuration used when no file is given.
+func Default() *Config {
+	return &Config{
+		Listen: ":8088",
+		Corps: map[string]Corp{
+			"dtac": {Peers: []string{"10.89.111.12:6553"}},
+			"dtn":  {Peers: []string{"10.89.111.40:6573"}},
+		},
+		DefaultCorp:   "dtn",
+		AnswerTimeout: Duration{10 * time.Second},
+		Validation:    "off",
+		Cache: Cache{
+			TTL:          Duration{5 * time.Second},
+			StaleIfError: Duration{time.Minute},
+		},
+		Log:     Log{Level: "info"},
+		Capture: Capture{Mode: "off"},
+		Replay:  Replay{Mode: "off"},
+		Notify:  Notify{Timeout: Duration{5 * time.Second}},
+		CORS: CORS{
+			Methods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			Headers: []string{
+				"Accept", "Content-Type", "X-Requested-With", "Origin",
+				"Idempotency-Key", "Authorization", "X-API-Key",
+			},
+			MaxAge: Duration{time.Hour},
+		},
+		Limits: Limits{
+			MaxOutstanding: 32,
+			BusyBackoff:    Duration{time.Second},
+		},
+	}
+}
+
+// Load reads a JSON configuration file on top of the defaults. A file
+// that lists corps replaces the default ones instead of adding to them.
+func Load(filename string) (*Config, error) {
+	c := Default()
+	corps := c.Corps
+	c.Corps = nil
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err = json.NewDecoder(f).Decode(c); err != nil {
+		return nil, err
+	}
+	if c.Corps == nil {
+		c.Corps = corps
+	}
+	return c, nil
+}
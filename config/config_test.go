@@ -0,0 +1,73 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLoadOverridesDefaults(t *testing.T) {
+	f, err := ioutil.TempFile("", "dccserve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"cache": {"ttl": "30s"}}`)
+	f.Close()
+
+	c, err := Load(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Cache.TTL.Duration != 30*time.Second {
+		t.Error("It should be 30s but was ", c.Cache.TTL)
+	}
+	if c.Listen != ":8088" {
+		t.Error("It should be :8088 but was ", c.Listen)
+	}
+	if c.Cache.StaleIfError.Duration != time.Minute {
+		t.Error("It should be 1m0s but was ", c.Cache.StaleIfError)
+	}
+	if len(c.Corps) != 2 {
+		t.Error("It should keep the 2 default corps but had ", len(c.Corps))
+	}
+}
+
+func TestLoadReplacesCorps(t *testing.T) {
+	f, err := ioutil.TempFile("", "dccserve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"corps": {"dtac": {"peers": ["127.0.0.1:3868"]}}}`)
+	f.Close()
+
+	c, err := Load(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Corps) != 1 || c.Corps["dtac"].Peers[0] != "127.0.0.1:3868" {
+		t.Error("It should only have the configured corp but had ", c.Corps)
+	}
+}
+
+func TestLoadRejectsBadDuration(t *testing.T) {
+	f, err := ioutil.TempFile("", "dccserve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"cache": {"ttl": "soon"}}`)
+	f.Close()
+
+	if _, err := Load(f.Name()); err == nil {
+		t.Error("It should fail on an invalid duration")
+	}
+}
+
+func TestDefaultCacheTTL(t *testing.T) {
+	if ttl := Default().Cache.TTL.Duration; ttl != 5*time.Second {
+		t.Error("It should be 5s but was ", ttl)
+	}
+}
@@ -0,0 +1,333 @@
+package diameter
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
+
+	"server/logger"
+)
+
+// Capture modes.
+const (
+	CaptureOff  = "off"
+	CapturePcap = "pcap"
+	CaptureHex  = "hex"
+)
+
+// CaptureConfig selects what is captured and where it is written.
+// Corp and Subscriber restrict the capture to one corp or subscriber
+// when set; answers are kept when their request was.
+type CaptureConfig struct {
+	Mode       string `json:"mode"`
+	Dir        string `json:"dir"`
+	MaxSize    int64  `json:"maxSize"`
+	MaxFiles   int    `json:"maxFiles"`
+	Corp       string `json:"corp"`
+	Subscriber string `json:"subscriber"`
+}
+
+// maxCaptureSessions bounds the Session-Ids remembered while waiting
+// for the answers of captured requests.
+const maxCaptureSessions = 10000
+
+// flow tracks TCP sequence numbers so Wireshark can reassemble the
+// synthesized segments.
+type flow struct {
+	seq uint32
+}
+
+type capturer struct {
+	mu       sync.Mutex
+	cfg      CaptureConfig
+	f        *os.File
+	written  int64
+	flows    map[string]*flow
+	sessions map[string]time.Time
+}
+
+var capture = &capturer{cfg: CaptureConfig{Mode: CaptureOff}}
+
+// SetCapture switches capturing according to cfg, closing the current
+// file.
+func SetCapture(cfg CaptureConfig) error {
+	switch cfg.Mode {
+	case "":
+		cfg.Mode = CaptureOff
+	case CaptureOff, CapturePcap, CaptureHex:
+	default:
+		return fmt.Errorf("diameter: unknown capture mode %q", cfg.Mode)
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = os.TempDir()
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 64 << 20
+	}
+	if cfg.MaxFiles <= 0 {
+		cfg.MaxFiles = 10
+	}
+
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+	capture.close()
+	capture.cfg = cfg
+	capture.flows = make(map[string]*flow)
+	capture.sessions = make(map[string]time.Time)
+	logger.Info("capture configured", "mode", cfg.Mode, "dir", cfg.Dir, "corp", cfg.Corp)
+	return nil
+}
+
+// Capturing returns the current capture configuration.
+func Capturing() CaptureConfig {
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+	return capture.cfg
+}
+
+// Capture hands m, sent (out) or received on c, to the capture files
+// and the replay recorder.
+func Capture(c diam.Conn, m *diam.Message, out bool) {
+	record(c, m, out)
+
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+
+	if capture.cfg.Mode == CaptureOff || !capture.match(c, m) {
+		return
+	}
+	b, err := m.Serialize()
+	if err != nil {
+		return
+	}
+
+	src, dst := c.LocalAddr(), c.RemoteAddr()
+	if !out {
+		src, dst = dst, src
+	}
+	if err = capture.write(src, dst, b, m); err != nil {
+		logger.Error("capture failed", "err", err)
+		capture.close()
+		capture.cfg.Mode = CaptureOff
+	}
+}
+
+func (cp *capturer) match(c diam.Conn, m *diam.Message) bool {
+	if cp.cfg.Corp != "" && Status(PeerAddr(c)).Corp != cp.cfg.Corp {
+		return false
+	}
+	if cp.cfg.Subscriber == "" {
+		return true
+	}
+
+	sid := ""
+	if a, err := m.FindAVP(avp.SessionID); err == nil {
+		sid = fmt.Sprint(Value(a.Data))
+	}
+	if m.Header.CommandFlags&diam.RequestFlag == 0 {
+		_, ok := cp.sessions[sid]
+		delete(cp.sessions, sid)
+		return ok
+	}
+	if subscriber(m) != cp.cfg.Subscriber {
+		return false
+	}
+	if sid != "" {
+		cp.sessions[sid] = time.Now()
+	}
+	if len(cp.sessions) > maxCaptureSessions {
+		for k, t := range cp.sessions {
+			if time.Since(t) > time.Minute {
+				delete(cp.sessions, k)
+			}
+		}
+	}
+	return true
+}
+
+// subscriber returns the first Subscription-Id-Data of m.
+func subscriber(m *diam.Message) string {
+	for _, a := range m.AVP {
+		if a.Code != avp.SubscriptionID {
+			continue
+		}
+		g, ok := a.Data.(*diam.GroupedAVP)
+		if !ok {
+			continue
+		}
+		for _, s := range g.AVP {
+			if s.Code == avp.SubscriptionIDData {
+				return fmt.Sprint(Value(s.Data))
+			}
+		}
+	}
+	return ""
+}
+
+func (cp *capturer) write(src, dst net.Addr, b []byte, m *diam.Message) error {
+	if cp.f == nil || cp.written >= cp.cfg.MaxSize {
+		if err := cp.rotate(); err != nil {
+			return err
+		}
+	}
+
+	var n int
+	var err error
+	if cp.cfg.Mode == CapturePcap {
+		n, err = cp.f.Write(pcapRecord(cp.segment(src, dst, b)))
+	} else {
+		n, err = fmt.Fprintf(cp.f, "%s %s -> %s cmd=%d flags=%#x hbh=%#x len=%d\n%s\n",
+			time.Now().Format(time.RFC3339Nano), src, dst,
+			m.Header.CommandCode, m.Header.CommandFlags, m.Header.HopByHopID,
+			len(b), hex.Dump(b))
+	}
+	cp.written += int64(n)
+	return err
+}
+
+func (cp *capturer) close() {
+	if cp.f != nil {
+		cp.f.Close()
+		cp.f = nil
+	}
+}
+
+// rotate starts a new capture file and removes the oldest ones beyond
+// MaxFiles.
+func (cp *capturer) rotate() error {
+	cp.close()
+
+	ext := ".pcap"
+	if cp.cfg.Mode == CaptureHex {
+		ext = ".hex"
+	}
+	name := filepath.Join(cp.cfg.Dir, "dccserve-"+time.Now().Format("20060102-150405.000000")+ext)
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	cp.f = f
+	cp.written = 0
+	if cp.cfg.Mode == CapturePcap {
+		n, err := f.Write(pcapHeader())
+		cp.written += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+
+	old, _ := filepath.Glob(filepath.Join(cp.cfg.Dir, "dccserve-*"+ext))
+	sort.Strings(old)
+	for len(old) > cp.cfg.MaxFiles {
+		os.Remove(old[0])
+		old = old[1:]
+	}
+	return nil
+}
+
+// linkTypeRaw marks packets that start with an IPv4 or IPv6 header.
+const linkTypeRaw = 101
+
+func pcapHeader() []byte {
+	h := make([]byte, 24)
+	binary.LittleEndian.PutUint32(h[0:], 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(h[4:], 2)
+	binary.LittleEndian.PutUint16(h[6:], 4)
+	binary.LittleEndian.PutUint32(h[16:], 65535)
+	binary.LittleEndian.PutUint32(h[20:], linkTypeRaw)
+	return h
+}
+
+func pcapRecord(pkt []byte) []byte {
+	now := time.Now()
+	h := make([]byte, 16, 16+len(pkt))
+	binary.LittleEndian.PutUint32(h[0:], uint32(now.Unix()))
+	binary.LittleEndian.PutUint32(h[4:], uint32(now.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(h[8:], uint32(len(pkt)))
+	binary.LittleEndian.PutUint32(h[12:], uint32(len(pkt)))
+	return append(h, pkt...)
+}
+
+// segment wraps payload in synthesized IP and TCP headers for the flow
+// from src to dst.
+func (cp *capturer) segment(src, dst net.Addr, payload []byte) []byte {
+	sip, sport := hostPort(src)
+	dip, dport := hostPort(dst)
+
+	key := src.String() + ">" + dst.String()
+	fl, ok := cp.flows[key]
+	if !ok {
+		fl = &flow{seq: 1}
+		cp.flows[key] = fl
+	}
+	var ack uint32 = 1
+	if rev, ok := cp.flows[dst.String()+">"+src.String()]; ok {
+		ack = rev.seq
+	}
+
+	tcp := make([]byte, 20)
+	binary.BigEndian.PutUint16(tcp[0:], sport)
+	binary.BigEndian.PutUint16(tcp[2:], dport)
+	binary.BigEndian.PutUint32(tcp[4:], fl.seq)
+	binary.BigEndian.PutUint32(tcp[8:], ack)
+	tcp[12] = 5 << 4
+	tcp[13] = 0x18 // PSH, ACK
+	binary.BigEndian.PutUint16(tcp[14:], 65535)
+	fl.seq += uint32(len(payload))
+
+	seg := append(tcp, payload...)
+	if s4, d4 := sip.To4(), dip.To4(); s4 != nil && d4 != nil {
+		ip := make([]byte, 20)
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:], uint16(20+len(seg)))
+		ip[8] = 64
+		ip[9] = 6 // TCP
+		copy(ip[12:], s4)
+		copy(ip[16:], d4)
+		binary.BigEndian.PutUint16(ip[10:], checksum(ip))
+		return append(ip, seg...)
+	}
+
+	ip := make([]byte, 40)
+	ip[0] = 0x60
+	binary.BigEndian.PutUint16(ip[4:], uint16(len(seg)))
+	ip[6] = 6 // TCP
+	ip[7] = 64
+	copy(ip[8:], sip.To16())
+	copy(ip[24:], dip.To16())
+	return append(ip, seg...)
+}
+
+func hostPort(a net.Addr) (net.IP, uint16) {
+	if t, ok := a.(*net.TCPAddr); ok {
+		return t.IP, uint16(t.Port)
+	}
+	host, port, _ := net.SplitHostPort(a.String())
+	var p int
+	fmt.Sscan(port, &p)
+	ip := net.ParseIP(host)
+	if ip == nil {
+		ip = net.IPv4zero
+	}
+	return ip, uint16(p)
+}
+
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
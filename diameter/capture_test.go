@@ -0,0 +1,48 @@
+package diameter
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+func TestPcapHeader(t *testing.T) {
+	h := pcapHeader()
+	if len(h) != 24 {
+		t.Fatal("It should be 24 bytes but was ", len(h))
+	}
+	if binary.LittleEndian.Uint32(h) != 0xa1b2c3d4 {
+		t.Error("Unexpected magic ", h[:4])
+	}
+	if binary.LittleEndian.Uint32(h[20:]) != linkTypeRaw {
+		t.Error("Unexpected link type ", h[20:])
+	}
+}
+
+func TestSegmentIPv4(t *testing.T) {
+	cp := &capturer{flows: make(map[string]*flow)}
+	src := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 40000}
+	dst := &net.TCPAddr{IP: net.ParseIP("10.89.111.12"), Port: 6553}
+
+	pkt := cp.segment(src, dst, make([]byte, 100))
+	if len(pkt) != 140 {
+		t.Fatal("It should be 140 bytes but was ", len(pkt))
+	}
+	if checksum(pkt[:20]) != 0 {
+		t.Error("It should have a valid IP header checksum")
+	}
+	if binary.BigEndian.Uint16(pkt[22:]) != 6553 {
+		t.Error("It should be sent to port 6553 but was ", binary.BigEndian.Uint16(pkt[22:]))
+	}
+
+	pkt = cp.segment(src, dst, make([]byte, 10))
+	if seq := binary.BigEndian.Uint32(pkt[24:]); seq != 101 {
+		t.Error("It should continue at seq 101 but was ", seq)
+	}
+}
+
+func TestSetCaptureRejectsUnknownMode(t *testing.T) {
+	if err := SetCapture(CaptureConfig{Mode: "tape"}); err == nil {
+		t.Error("It should reject an unknown mode")
+	}
+}
@@ -1,7 +1,6 @@
 package diameter
 
 import (
-	"log"
 	"math/rand"
 	"net"
 	"time"
@@ -9,23 +8,53 @@ import (
 	"github.com/fiorix/go-diameter/diam"
 	"github.com/fiorix/go-diameter/diam/avp"
 	"github.com/fiorix/go-diameter/diam/datatype"
+
+	"server/logger"
+	"server/metrics"
 )
 
-func Serve(c diam.Conn, identity, realm, vendorID, productName datatype.Type) {
+// watchdogInterval is the time between two DWRs on a connection.
+const watchdogInterval = 10 * time.Second
+
+// dpaTimeout is how long Disconnect waits for the DPA.
+const dpaTimeout = 5 * time.Second
+
+// doNotWantToTalkToYou is the Disconnect-Cause sent when a peer is
+// removed from the configuration (RFC 6733, 5.4.3).
+const doNotWantToTalkToYou = 2
+
+// Serve exchanges capabilities on c, the connection to the peer
+// configured as addr, and watches it until it closes. The status of the
+// peer is kept under addr.
+func Serve(c diam.Conn, addr string, identity, realm, vendorID, productName datatype.Type) {
 	var (
 		err error
 	)
 
+	register(c, addr)
+	defer unregister(c)
+
 	err = Cer(c, identity, realm, vendorID, productName)
 	if err != nil {
-		log.Println(err.Error())
+		// Cer closed the connection; the caller dials again.
+		logger.Error("cer failed", "peer", addr, "err", err)
+		setState(addr, StateClosed)
+		return
 	}
 
 	go Watchdog(c, identity, realm)
 
-	log.Println(<-diam.ErrorReports())
-	log.Println(<-c.(diam.CloseNotifier).CloseNotify())
-	log.Println("Server disconnected.")
+	closed := c.(diam.CloseNotifier).CloseNotify()
+	for {
+		select {
+		case err := <-diam.ErrorReports():
+			logger.Warn("diameter error", "peer", addr, "err", err.Error)
+		case <-closed:
+			setState(addr, StateClosed)
+			logger.Warn("peer disconnected", "peer", addr)
+			return
+		}
+	}
 }
 
 func Cer(c diam.Conn, identity, realm, vendorID, productName datatype.Type) (err error) {
@@ -43,46 +72,147 @@ func Cer(c diam.Conn, identity, realm, vendorID, productName datatype.Type) (err
 	m.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(4))
 	m.NewAVP(avp.FirmwareRevision, avp.Mbit, 0, datatype.Unsigned32(1))
 
-	log.Printf("Sending message to %s", c.RemoteAddr().String())
+	logger.Info("sending cer", "peer", PeerAddr(c))
+	trace(c, m)
 
+	setState(PeerAddr(c), StateWaitCEA)
 	if _, err = m.WriteTo(c); err != nil {
-		log.Fatal("Write failed:", err)
+		logger.Error("write failed", "peer", PeerAddr(c), "err", err)
+		c.Close()
 		return err
 	}
+	Capture(c, m, true)
 
 	return
 }
 
+// Watchdog sends a DWR every watchdogInterval until the connection
+// fails. A peer that misses one DWA becomes SUSPECT and one that misses
+// two becomes DOWN.
 func Watchdog(c diam.Conn, identity, realm datatype.Type) {
+	addr := PeerAddr(c)
 	for {
-		time.Sleep(10 * time.Second)
+		time.Sleep(watchdogInterval)
+
+		update(addr, func(p *PeerStatus) {
+			if p.State != StateOkay && p.State != StateSuspect {
+				return
+			}
+			switch silent := time.Since(p.LastDWA); {
+			case p.LastDWA.IsZero():
+			case silent > 2*watchdogInterval:
+				p.State = StateDown
+			case silent > watchdogInterval:
+				p.State = StateSuspect
+			}
+		})
+
 		m := diam.NewRequest(diam.DeviceWatchdog, 0, nil)
 		m.NewAVP(avp.OriginHost, avp.Mbit, 0, identity)
 		m.NewAVP(avp.OriginRealm, avp.Mbit, 0, realm)
 		m.NewAVP(avp.OriginStateID, avp.Mbit, 0, datatype.Unsigned32(rand.Uint32()))
-		log.Printf("Sending message to %s", c.RemoteAddr().String())
+		logger.Debug("sending dwr", "peer", addr, "hop_by_hop", m.Header.HopByHopID)
+		trace(c, m)
 
 		if _, err := m.WriteTo(c); err != nil {
-			log.Fatal("Write failed:", err)
+			logger.Error("write failed", "peer", addr, "err", err)
+			return
 		}
+		Capture(c, m, true)
+		metrics.DWRSent.WithLabelValues(addr).Inc()
 	}
 }
 
 func OnCEA(c diam.Conn, m *diam.Message) {
+	trace(c, m)
+	Capture(c, m, false)
+	// A peer that refuses us is dropped, and dialed again later, rather
+	// than taking the other peers down with the process.
+	rc, err := m.FindAVP(avp.ResultCode)
+	if err != nil {
+		logger.Error("cea without result code", "peer", PeerAddr(c), "err", err)
+		c.Close()
+		return
+	}
+	if v, _ := rc.Data.(datatype.Unsigned32); v != diam.Success {
+		logger.Error("unexpected cea", "peer", PeerAddr(c), "result_code", Value(rc.Data))
+		c.Close()
+		return
+	}
+
+	update(PeerAddr(c), func(p *PeerStatus) {
+		p.State = StateOkay
+		p.LastCEA = time.Now()
+		p.LastDWA = p.LastCEA
+	})
+	logger.Info("received cea", "peer", PeerAddr(c))
+}
+
+func OnDWA(c diam.Conn, m *diam.Message) {
+	addr := PeerAddr(c)
+	metrics.DWAReceived.WithLabelValues(addr).Inc()
+	trace(c, m)
+	Capture(c, m, false)
+
 	rc, err := m.FindAVP(avp.ResultCode)
 	if err != nil {
-		log.Fatal(err)
+		logger.Warn("dwa without result code", "peer", addr, "err", err)
 		return
 	}
 	if v, _ := rc.Data.(datatype.Unsigned32); v != diam.Success {
-		log.Fatal("Unexpected response:", rc)
+		logger.Warn("unexpected dwa", "peer", addr, "result_code", Value(rc.Data))
 		return
 	}
 
-	log.Printf("Receiving message from %s", c.RemoteAddr().String())
+	update(addr, func(p *PeerStatus) {
+		p.State = StateOkay
+		p.LastDWA = time.Now()
+	})
+}
+
+// Disconnect sends a DPR telling the peer we are leaving and closes the
+// connection when the DPA arrives, or after dpaTimeout without one.
+func Disconnect(c diam.Conn, identity, realm datatype.Type) error {
+	addr := PeerAddr(c)
+	m := diam.NewRequest(diam.DisconnectPeer, 0, nil)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, identity)
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, realm)
+	m.NewAVP(avp.DisconnectCause, avp.Mbit, 0, datatype.Enumerated(doNotWantToTalkToYou))
+	logger.Info("sending dpr", "peer", addr)
+	trace(c, m)
+
+	time.AfterFunc(dpaTimeout, c.Close)
+	if _, err := m.WriteTo(c); err != nil {
+		logger.Error("write failed", "peer", addr, "err", err)
+		c.Close()
+		return err
+	}
+	Capture(c, m, true)
+	return nil
+}
+
+// OnDPA closes the connection the DPR was sent on.
+func OnDPA(c diam.Conn, m *diam.Message) {
+	trace(c, m)
+	Capture(c, m, false)
+	logger.Info("received dpa", "peer", PeerAddr(c))
+	c.Close()
 }
 
 func OnMSG(c diam.Conn, m *diam.Message) {
-	log.Printf("-Receiving message from %s", c.RemoteAddr().String())
-	// log.Println(m)
+	logger.Debug("received message", "peer", PeerAddr(c), "command", m.Header.CommandCode)
+	trace(c, m)
+	Capture(c, m, false)
+}
+
+// trace logs the full message when trace mode is on.
+func trace(c diam.Conn, m *diam.Message) {
+	if !logger.Tracing() {
+		return
+	}
+	logger.Debug("diameter message",
+		"peer", PeerAddr(c),
+		"hop_by_hop", m.Header.HopByHopID,
+		"message", Format(m),
+	)
 }
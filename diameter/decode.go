@@ -0,0 +1,70 @@
+package diameter
+
+import (
+	"fmt"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/dict"
+)
+
+// Decoded is a message with its AVPs named after the dictionary, ready
+// to be written as JSON.
+type Decoded struct {
+	Command       string       `json:"command"`
+	Request       bool         `json:"request"`
+	ApplicationID uint32       `json:"applicationId"`
+	HopByHopID    uint32       `json:"hopByHopId"`
+	EndToEndID    uint32       `json:"endToEndId"`
+	AVPs          []DecodedAVP `json:"avps"`
+}
+
+type DecodedAVP struct {
+	Name     string       `json:"name"`
+	Code     uint32       `json:"code"`
+	VendorID uint32       `json:"vendorId,omitempty"`
+	Value    interface{}  `json:"value,omitempty"`
+	AVPs     []DecodedAVP `json:"avps,omitempty"`
+}
+
+// Decode names the command and AVPs of m. Unlike Format it does not
+// mask subscriber numbers.
+func Decode(m *diam.Message) Decoded {
+	p := m.Dictionary()
+	if p == nil {
+		p = dict.Default
+	}
+
+	d := Decoded{
+		Command:       fmt.Sprint(m.Header.CommandCode),
+		Request:       m.Header.CommandFlags&diam.RequestFlag != 0,
+		ApplicationID: m.Header.ApplicationID,
+		HopByHopID:    m.Header.HopByHopID,
+		EndToEndID:    m.Header.EndToEndID,
+	}
+	if p != nil {
+		if cmd, err := p.FindCommand(m.Header.ApplicationID, m.Header.CommandCode); err == nil {
+			d.Command = cmd.Name
+		}
+	}
+	d.AVPs = decodeAVPs(p, m.Header.ApplicationID, m.AVP)
+	return d
+}
+
+func decodeAVPs(p *dict.Parser, app uint32, avps []*diam.AVP) []DecodedAVP {
+	out := make([]DecodedAVP, 0, len(avps))
+	for _, a := range avps {
+		d := DecodedAVP{Name: fmt.Sprint(a.Code), Code: a.Code, VendorID: a.VendorID}
+		if p != nil {
+			if da := findAVP(p, app, a); da != nil {
+				d.Name = da.Name
+			}
+		}
+		if g, ok := a.Data.(*diam.GroupedAVP); ok {
+			d.AVPs = decodeAVPs(p, app, g.AVP)
+		} else {
+			d.Value = Value(a.Data)
+		}
+		out = append(out, d)
+	}
+	return out
+}
@@ -0,0 +1,32 @@
+package diameter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/datatype"
+)
+
+func TestDecode(t *testing.T) {
+	m := &diam.Message{
+		Header: &diam.Header{CommandCode: 272, ApplicationID: 4, HopByHopID: 7},
+		AVP: []*diam.AVP{
+			{Code: 268, Data: datatype.Unsigned32(2001)},
+			{Code: 443, Data: &diam.GroupedAVP{AVP: []*diam.AVP{
+				{Code: 444, Data: datatype.UTF8String("66812345678")},
+			}}},
+		},
+	}
+
+	b, err := json.Marshal(Decode(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"command":"272","request":false,"applicationId":4,"hopByHopId":7,"endToEndId":0,"avps":[` +
+		`{"name":"268","code":268,"value":2001},` +
+		`{"name":"443","code":443,"avps":[{"name":"444","code":444,"value":"66812345678"}]}]}`
+	if string(b) != want {
+		t.Error("It should be ", want, " but was ", string(b))
+	}
+}
@@ -1,12 +1,11 @@
 package diameter
 
 import (
-	"fmt"
 	"github.com/fiorix/go-diameter/diam"
 	"github.com/fiorix/go-diameter/diam/avp"
 	"github.com/fiorix/go-diameter/diam/datatype"
-	"log"
 	"reflect"
+	"server/logger"
 	"strconv"
 	"strings"
 	"time"
@@ -30,7 +29,7 @@ func (a *AVP) Encode(v interface{}) *diam.Message {
 		attr := strings.Split(tag, ",")
 		code, err := strconv.Atoi(attr[0])
 		if err != nil {
-			log.Fatal(err)
+			logger.Fatal("invalid cbs tag", "field", field.Name, "err", err)
 			continue
 		}
 		switch attr[1] {
@@ -69,7 +68,7 @@ func Encode(v interface{}) []*diam.AVP {
 	var typ reflect.Type
 	var val, inf reflect.Value
 
-	fmt.Println(reflect.ValueOf(v).Kind())
+	logger.Debug("encoding", "kind", reflect.ValueOf(v).Kind().String())
 
 	switch reflect.ValueOf(v).Kind() {
 	case reflect.Ptr:
@@ -89,7 +88,7 @@ func Encode(v interface{}) []*diam.AVP {
 
 		code, err := strconv.Atoi(dcode[0])
 		if err != nil {
-			log.Fatal(err)
+			logger.Fatal("invalid dcode tag", "field", field.Name, "err", err)
 			continue
 		}
 		switch dtype[0] {
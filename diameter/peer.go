@@ -0,0 +1,124 @@
+package diameter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/fiorix/go-diameter/diam"
+
+	"server/metrics"
+)
+
+// State is the state of a peer connection as seen by the watchdog
+// (RFC 3539).
+type State int
+
+const (
+	StateClosed State = iota
+	StateWaitCEA
+	StateOkay
+	StateSuspect
+	StateDown
+)
+
+func (s State) String() string {
+	switch s {
+	case StateWaitCEA:
+		return "WAIT_CEA"
+	case StateOkay:
+		return "OKAY"
+	case StateSuspect:
+		return "SUSPECT"
+	case StateDown:
+		return "DOWN"
+	}
+	return "CLOSED"
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// PeerStatus is a snapshot of what we know about a peer connection.
+type PeerStatus struct {
+	Addr    string    `json:"addr"`
+	Corp    string    `json:"corp"`
+	State   State     `json:"state"`
+	LastCEA time.Time `json:"lastCEA"`
+	LastDWA time.Time `json:"lastDWA"`
+}
+
+var (
+	peersLock sync.Mutex
+	peers     = make(map[string]*PeerStatus)
+
+	// conns maps each connection opened by Serve to the configured
+	// address of its peer, which a hostname peer does not share with
+	// RemoteAddr.
+	connsLock sync.RWMutex
+	conns     = make(map[diam.Conn]string)
+)
+
+func register(c diam.Conn, addr string) {
+	connsLock.Lock()
+	conns[c] = addr
+	connsLock.Unlock()
+}
+
+func unregister(c diam.Conn) {
+	connsLock.Lock()
+	delete(conns, c)
+	connsLock.Unlock()
+}
+
+// PeerAddr returns the configured address of the peer on c, or its
+// remote address for a connection not served by Serve.
+func PeerAddr(c diam.Conn) string {
+	connsLock.RLock()
+	addr, ok := conns[c]
+	connsLock.RUnlock()
+	if ok {
+		return addr
+	}
+	return c.RemoteAddr().String()
+}
+
+// update applies f to the status of the peer at addr.
+func update(addr string, f func(p *PeerStatus)) {
+	peersLock.Lock()
+	p, ok := peers[addr]
+	if !ok {
+		p = &PeerStatus{Addr: addr}
+		peers[addr] = p
+	}
+	f(p)
+	metrics.PeerState.WithLabelValues(addr).Set(float64(p.State))
+	peersLock.Unlock()
+}
+
+func setState(addr string, s State) {
+	update(addr, func(p *PeerStatus) { p.State = s })
+}
+
+// SetCorp records that the peer at addr serves corp.
+func SetCorp(addr, corp string) {
+	update(addr, func(p *PeerStatus) { p.Corp = corp })
+}
+
+// Status returns the status of the peer at addr.
+func Status(addr string) PeerStatus {
+	peersLock.Lock()
+	defer peersLock.Unlock()
+	if p, ok := peers[addr]; ok {
+		return *p
+	}
+	return PeerStatus{Addr: addr}
+}
+
+// Forget drops the status of a peer that is no longer configured.
+func Forget(addr string) {
+	peersLock.Lock()
+	delete(peers, addr)
+	peersLock.Unlock()
+	metrics.PeerState.DeleteLabelValues(addr)
+}
@@ -0,0 +1,38 @@
+package diameter
+
+import (
+	"net"
+	"testing"
+
+	"github.com/fiorix/go-diameter/diam"
+)
+
+// fakeConn is a diam.Conn that only knows its remote address.
+type fakeConn struct {
+	diam.Conn
+	remote net.Addr
+}
+
+func (c fakeConn) RemoteAddr() net.Addr { return c.remote }
+
+func TestPeerAddr(t *testing.T) {
+	c := fakeConn{remote: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 3868}}
+	if v := PeerAddr(c); v != "10.0.0.1:3868" {
+		t.Error("It should be the remote address but was ", v)
+	}
+
+	register(c, "ocs.example:3868")
+	defer Forget("ocs.example:3868")
+	if v := PeerAddr(c); v != "ocs.example:3868" {
+		t.Error("It should be the configured address but was ", v)
+	}
+	setState(PeerAddr(c), StateOkay)
+	if v := Status("ocs.example:3868").State; v != StateOkay {
+		t.Error("It should be OKAY but was ", v)
+	}
+
+	unregister(c)
+	if v := PeerAddr(c); v != "10.0.0.1:3868" {
+		t.Error("It should be the remote address again but was ", v)
+	}
+}
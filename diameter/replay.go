@@ -0,0 +1,346 @@
+package diameter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
+	"github.com/fiorix/go-diameter/diam/datatype"
+	"github.com/fiorix/go-diameter/diam/dict"
+
+	"server/logger"
+)
+
+// Recording is one request and its answer, as written by the recorder
+// one JSON object per line. Subscriber numbers are anonymized.
+type Recording struct {
+	Request []byte `json:"request"`
+	Answer  []byte `json:"answer"`
+}
+
+// ignoredAVPs change on every request and are left out when matching a
+// request against the recording.
+var ignoredAVPs = map[uint32]bool{
+	avp.SessionID:      true,
+	avp.OriginStateID:  true,
+	avp.EventTimestamp: true,
+}
+
+// Anonymize replaces a subscriber number with a stable fake number of
+// the same length, keeping the first two digits (the country code).
+func Anonymize(s string) string {
+	if len(s) <= 2 {
+		return s
+	}
+	b := []byte(s[:2])
+	h := fnv.New64a()
+	for len(b) < len(s) {
+		h.Write([]byte(s))
+		for sum := h.Sum64(); sum > 0 && len(b) < len(s); sum /= 10 {
+			b = append(b, byte('0'+sum%10))
+		}
+	}
+	return string(b)
+}
+
+// anonymize rewrites the subscriber numbers in avps in place.
+func anonymize(p *dict.Parser, app uint32, avps []*diam.AVP) {
+	for _, a := range avps {
+		if g, ok := a.Data.(*diam.GroupedAVP); ok {
+			anonymize(p, app, g.AVP)
+			continue
+		}
+		if p == nil {
+			continue
+		}
+		d := findAVP(p, app, a)
+		if d == nil || !maskedAVPs[d.Name] {
+			continue
+		}
+		switch v := a.Data.(type) {
+		case datatype.UTF8String:
+			a.Data = datatype.UTF8String(Anonymize(string(v)))
+		case datatype.OctetString:
+			a.Data = datatype.OctetString(Anonymize(string(v)))
+		}
+	}
+}
+
+// Key normalizes the AVPs of a request into a string that ignores
+// Session-Id, Origin-State-Id and timestamps.
+func Key(m *diam.Message) string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%d/%d", m.Header.ApplicationID, m.Header.CommandCode)
+	writeKey(&b, m.AVP)
+	return b.String()
+}
+
+func writeKey(b *bytes.Buffer, avps []*diam.AVP) {
+	for _, a := range avps {
+		if ignoredAVPs[a.Code] {
+			continue
+		}
+		switch d := a.Data.(type) {
+		case *diam.GroupedAVP:
+			fmt.Fprintf(b, ";%d:%d{", a.VendorID, a.Code)
+			writeKey(b, d.AVP)
+			b.WriteString("}")
+		case datatype.Time:
+		default:
+			fmt.Fprintf(b, ";%d:%d=%v", a.VendorID, a.Code, Value(d))
+		}
+	}
+}
+
+// copyMessage parses a serialized copy of m so that it can be changed
+// without touching m.
+func copyMessage(m *diam.Message, p *dict.Parser) (*diam.Message, error) {
+	b, err := m.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	return diam.ReadMessage(bytes.NewReader(b), p)
+}
+
+// pendingKey identifies a recorded request until its answer arrives.
+// Hop-by-Hop Ids are only unique per connection.
+type pendingKey struct {
+	peer     string
+	hopByHop uint32
+}
+
+type pendingRequest struct {
+	b    []byte
+	sent time.Time
+}
+
+// Recorder writes CCR/CCA pairs seen on the wire to a file. Requests
+// left unanswered for longer than the answer timeout are dropped.
+type Recorder struct {
+	mu        sync.Mutex
+	f         *os.File
+	enc       *json.Encoder
+	timeout   time.Duration
+	pending   map[pendingKey]pendingRequest
+	lastPrune time.Time
+}
+
+var (
+	recorderLock sync.Mutex
+	recorder     *Recorder
+)
+
+// NewRecorder appends to filename. timeout is how long a request waits
+// for its answer.
+func NewRecorder(filename string, timeout time.Duration) (*Recorder, error) {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		f:         f,
+		enc:       json.NewEncoder(f),
+		timeout:   timeout,
+		pending:   make(map[pendingKey]pendingRequest),
+		lastPrune: time.Now(),
+	}, nil
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+// SetRecorder starts recording to r, or stops when r is nil.
+func SetRecorder(r *Recorder) {
+	recorderLock.Lock()
+	old := recorder
+	recorder = r
+	recorderLock.Unlock()
+	if old != nil {
+		old.Close()
+	}
+}
+
+func record(c diam.Conn, m *diam.Message, out bool) {
+	recorderLock.Lock()
+	r := recorder
+	recorderLock.Unlock()
+	if r == nil || m.Header.CommandCode != diam.CreditControl {
+		return
+	}
+	if err := r.observe(PeerAddr(c), m, out, time.Now()); err != nil {
+		logger.Error("record failed", "err", err)
+	}
+}
+
+// observe records m, sent to or received from peer at now.
+func (r *Recorder) observe(peer string, m *diam.Message, out bool, now time.Time) error {
+	isRequest := m.Header.CommandFlags&diam.RequestFlag != 0
+	if out != isRequest {
+		return nil
+	}
+
+	c, err := copyMessage(m, m.Dictionary())
+	if err != nil {
+		return err
+	}
+	anonymize(c.Dictionary(), c.Header.ApplicationID, c.AVP)
+	b, err := c.Serialize()
+	if err != nil {
+		return err
+	}
+
+	k := pendingKey{peer: peer, hopByHop: m.Header.HopByHopID}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if isRequest {
+		r.prune(now)
+		r.pending[k] = pendingRequest{b: b, sent: now}
+		return nil
+	}
+	req, ok := r.pending[k]
+	if !ok {
+		return nil
+	}
+	delete(r.pending, k)
+	return r.enc.Encode(Recording{Request: req.b, Answer: b})
+}
+
+// prune drops the requests whose answer can no longer arrive in time.
+// It runs at most once per timeout. r.mu must be held.
+func (r *Recorder) prune(now time.Time) {
+	if now.Sub(r.lastPrune) < r.timeout {
+		return
+	}
+	r.lastPrune = now
+	for k, p := range r.pending {
+		if now.Sub(p.sent) > r.timeout {
+			delete(r.pending, k)
+		}
+	}
+}
+
+// ErrNotRecorded is returned when a request has no recorded answer.
+var ErrNotRecorded = errors.New("diameter: no recorded answer")
+
+// Replayer answers requests from a recording, matching them by Key.
+// Requests recorded more than once get their answers in turn.
+type Replayer struct {
+	dict *dict.Parser
+
+	mu      sync.Mutex
+	answers map[string][][]byte
+	next    map[string]int
+}
+
+// LoadReplay reads a file written by a Recorder.
+func LoadReplay(filename string, p *dict.Parser) (*Replayer, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rp := &Replayer{
+		dict:    p,
+		answers: make(map[string][][]byte),
+		next:    make(map[string]int),
+	}
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64<<10), 16<<20)
+	for s.Scan() {
+		var rec Recording
+		if err := json.Unmarshal(s.Bytes(), &rec); err != nil {
+			return nil, err
+		}
+		req, err := diam.ReadMessage(bytes.NewReader(rec.Request), p)
+		if err != nil {
+			return nil, err
+		}
+		k := Key(req)
+		rp.answers[k] = append(rp.answers[k], rec.Answer)
+	}
+	return rp, s.Err()
+}
+
+// Len returns the number of distinct requests recorded.
+func (rp *Replayer) Len() int {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	return len(rp.answers)
+}
+
+// Answer returns the recorded answer to m, carrying the identifiers
+// and Session-Id of m.
+func (rp *Replayer) Answer(m *diam.Message) (*diam.Message, error) {
+	c, err := copyMessage(m, rp.dict)
+	if err != nil {
+		return nil, err
+	}
+	anonymize(rp.dict, c.Header.ApplicationID, c.AVP)
+	k := Key(c)
+
+	rp.mu.Lock()
+	all := rp.answers[k]
+	if len(all) == 0 {
+		rp.mu.Unlock()
+		return nil, ErrNotRecorded
+	}
+	b := all[rp.next[k]%len(all)]
+	rp.next[k]++
+	rp.mu.Unlock()
+
+	a, err := diam.ReadMessage(bytes.NewReader(b), rp.dict)
+	if err != nil {
+		return nil, err
+	}
+	a.Header.HopByHopID = m.Header.HopByHopID
+	a.Header.EndToEndID = m.Header.EndToEndID
+	if sid, err := m.FindAVP(avp.SessionID); err == nil {
+		for _, x := range a.AVP {
+			if x.Code == avp.SessionID {
+				x.Data = sid.Data
+			}
+		}
+	}
+	return a, nil
+}
+
+// Handler answers CER and DWR itself and every other request from the
+// recording, or with DIAMETER_UNABLE_TO_COMPLY when it was not recorded.
+func (rp *Replayer) Handler(identity, realm datatype.DiameterIdentity) diam.Handler {
+	mux := diam.NewServeMux()
+	base := func(c diam.Conn, m *diam.Message) {
+		a := m.Answer(diam.Success)
+		a.NewAVP(avp.OriginHost, avp.Mbit, 0, identity)
+		a.NewAVP(avp.OriginRealm, avp.Mbit, 0, realm)
+		a.WriteTo(c)
+	}
+	mux.HandleFunc("CER", base)
+	mux.HandleFunc("DWR", base)
+	mux.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		a, err := rp.Answer(m)
+		if err != nil {
+			logger.Warn("replay miss", "key", Key(m), "err", err)
+			a = m.Answer(diam.UnableToComply)
+			if sid, err := m.FindAVP(avp.SessionID); err == nil {
+				a.AddAVP(sid)
+			}
+			a.NewAVP(avp.OriginHost, avp.Mbit, 0, identity)
+			a.NewAVP(avp.OriginRealm, avp.Mbit, 0, realm)
+			a.NewAVP(avp.ErrorMessage, 0, 0, datatype.UTF8String(err.Error()))
+		}
+		a.WriteTo(c)
+	})
+	return mux
+}
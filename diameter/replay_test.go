@@ -0,0 +1,150 @@
+package diameter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
+	"github.com/fiorix/go-diameter/diam/datatype"
+)
+
+func TestAnonymize(t *testing.T) {
+	a := Anonymize("66947451960")
+	if len(a) != 11 || a[:2] != "66" {
+		t.Error("It should keep the length and country code but was ", a)
+	}
+	if a == "66947451960" {
+		t.Error("It should change the number")
+	}
+	if Anonymize("66947451960") != a {
+		t.Error("It should be stable")
+	}
+}
+
+func newCCR(sessionID, subr string) *diam.Message {
+	return newCCRAt(sessionID, subr, time.Now())
+}
+
+func newCCRAt(sessionID, subr string, ts time.Time) *diam.Message {
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String(sessionID))
+	m.NewAVP(avp.EventTimestamp, avp.Mbit, 0, datatype.Time(ts))
+	m.NewAVP(avp.SubscriptionID, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(avp.SubscriptionIDType, avp.Mbit, 0, datatype.Enumerated(0)),
+			diam.NewAVP(avp.SubscriptionIDData, avp.Mbit, 0, datatype.UTF8String(subr)),
+		},
+	})
+	return m
+}
+
+func TestKeyIgnoresSessionAndTime(t *testing.T) {
+	now := time.Now()
+	a := newCCRAt("dtac.co.th;OMR1", "66947451960", now)
+	b := newCCRAt("dtac.co.th;OMR2", "66947451960", now.Add(time.Hour))
+	if Key(a) != Key(b) {
+		t.Error("It should match\n", Key(a), "\n", Key(b))
+	}
+	if Key(a) == Key(newCCR("dtac.co.th;OMR1", "66811111111")) {
+		t.Error("It should differ by subscriber")
+	}
+}
+
+// newCCA answers r, telling answers apart by their CC-Request-Number n.
+func newCCA(r *diam.Message, n uint32) *diam.Message {
+	a := r.Answer(diam.Success)
+	if sid, err := r.FindAVP(avp.SessionID); err == nil {
+		a.AddAVP(sid)
+	}
+	a.NewAVP(avp.CCRequestType, avp.Mbit, 0, datatype.Enumerated(4))
+	a.NewAVP(avp.CCRequestNumber, avp.Mbit, 0, datatype.Unsigned32(n))
+	return a
+}
+
+func tempRecorder(t *testing.T, timeout time.Duration) (*Recorder, string) {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "rec.jsonl")
+	r, err := NewRecorder(name, timeout)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return r, name
+}
+
+func TestRecordReplayRoundTrip(t *testing.T) {
+	r, name := tempRecorder(t, time.Minute)
+	defer os.RemoveAll(filepath.Dir(name))
+
+	now := time.Now()
+	// The same Hop-by-Hop Id on two peers must not mix up the answers.
+	a := newCCRAt("dtac.co.th;OMR1", "66947451960", now)
+	b := newCCRAt("dtac.co.th;OMR2", "66811111111", now)
+	b.Header.HopByHopID = a.Header.HopByHopID
+	for _, step := range []struct {
+		peer string
+		m    *diam.Message
+		out  bool
+	}{
+		{"ocs1:3868", a, true},
+		{"ocs2:3868", b, true},
+		{"ocs2:3868", newCCA(b, 200), false},
+		{"ocs1:3868", newCCA(a, 100), false},
+	} {
+		if err := r.observe(step.peer, step.m, step.out, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.Close()
+
+	rp, err := LoadReplay(name, a.Dictionary())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rp.Len() != 2 {
+		t.Fatal("It should load 2 requests but was ", rp.Len())
+	}
+	for subr, want := range map[string]uint32{"66947451960": 100, "66811111111": 200} {
+		req := newCCRAt("dtac.co.th;OMR9", subr, now.Add(time.Hour))
+		ans, err := rp.Answer(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ans.Header.HopByHopID != req.Header.HopByHopID {
+			t.Error("It should carry the Hop-by-Hop Id of the request")
+		}
+		sid, err := ans.FindAVP(avp.SessionID)
+		if err != nil || sid.Data != datatype.UTF8String("dtac.co.th;OMR9") {
+			t.Error("It should carry the Session-Id of the request but was ", sid)
+		}
+		n, err := ans.FindAVP(avp.CCRequestNumber)
+		if err != nil || n.Data != datatype.Unsigned32(want) {
+			t.Error("It should replay the answer recorded for "+subr+" but was ", n)
+		}
+	}
+}
+
+func TestRecorderPrunesUnanswered(t *testing.T) {
+	r, name := tempRecorder(t, time.Second)
+	defer os.RemoveAll(filepath.Dir(name))
+	defer r.Close()
+
+	now := time.Now()
+	if err := r.observe("ocs1:3868", newCCRAt("dtac.co.th;OMR1", "66947451960", now), true, now); err != nil {
+		t.Fatal(err)
+	}
+	later := now.Add(2 * time.Second)
+	if err := r.observe("ocs1:3868", newCCRAt("dtac.co.th;OMR2", "66947451960", later), true, later); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.pending) != 1 {
+		t.Error("It should drop the unanswered request but kept ", len(r.pending))
+	}
+}
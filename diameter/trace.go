@@ -0,0 +1,112 @@
+package diameter
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/datatype"
+	"github.com/fiorix/go-diameter/diam/dict"
+
+	"server/logger"
+)
+
+// maskedAVPs hold subscriber numbers and are masked by Format.
+var maskedAVPs = map[string]bool{
+	"Subscription-Id-Data":  true,
+	"Calling-Party-Address": true,
+	"Called-Party-Address":  true,
+	"MSISDN":                true,
+}
+
+// Mask hides all but the last four characters of a subscriber number.
+func Mask(s string) string {
+	return logger.Mask(s)
+}
+
+// Value converts AVP data to the matching Go value.
+func Value(d datatype.Type) interface{} {
+	switch v := d.(type) {
+	case datatype.UTF8String:
+		return string(v)
+	case datatype.OctetString:
+		return string(v)
+	case datatype.DiameterIdentity:
+		return string(v)
+	case datatype.DiameterURI:
+		return string(v)
+	case datatype.Unsigned32:
+		return uint32(v)
+	case datatype.Unsigned64:
+		return uint64(v)
+	case datatype.Integer32:
+		return int32(v)
+	case datatype.Integer64:
+		return int64(v)
+	case datatype.Enumerated:
+		return int32(v)
+	case datatype.Float32:
+		return float32(v)
+	case datatype.Float64:
+		return float64(v)
+	case datatype.Time:
+		return time.Time(v)
+	case datatype.Address:
+		return net.IP(v).String()
+	case datatype.IPv4:
+		return net.IP(v).String()
+	}
+	return d.String()
+}
+
+// Format renders m as an indented AVP tree named after the dictionary,
+// with subscriber numbers masked.
+func Format(m *diam.Message) string {
+	p := m.Dictionary()
+	if p == nil {
+		p = dict.Default
+	}
+
+	var b bytes.Buffer
+	name := fmt.Sprint(m.Header.CommandCode)
+	if p != nil {
+		if cmd, err := p.FindCommand(m.Header.ApplicationID, m.Header.CommandCode); err == nil {
+			name = cmd.Name
+		}
+	}
+	kind := "Answer"
+	if m.Header.CommandFlags&diam.RequestFlag != 0 {
+		kind = "Request"
+	}
+	fmt.Fprintf(&b, "%s-%s app=%d hbh=%#x e2e=%#x\n", name, kind,
+		m.Header.ApplicationID, m.Header.HopByHopID, m.Header.EndToEndID)
+	formatAVPs(&b, p, m.Header.ApplicationID, m.AVP, 1)
+	return b.String()
+}
+
+func formatAVPs(b *bytes.Buffer, p *dict.Parser, app uint32, avps []*diam.AVP, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, a := range avps {
+		name := fmt.Sprint(a.Code)
+		if p != nil {
+			if d := findAVP(p, app, a); d != nil {
+				name = d.Name
+			}
+		}
+
+		if g, ok := a.Data.(*diam.GroupedAVP); ok {
+			fmt.Fprintf(b, "%s%s\n", indent, name)
+			formatAVPs(b, p, app, g.AVP, depth+1)
+			continue
+		}
+
+		v := fmt.Sprint(Value(a.Data))
+		if maskedAVPs[name] {
+			v = Mask(v)
+		}
+		fmt.Fprintf(b, "%s%s = %s\n", indent, name, v)
+	}
+}
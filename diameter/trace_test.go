@@ -0,0 +1,70 @@
+package diameter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
+	"github.com/fiorix/go-diameter/diam/datatype"
+	"github.com/fiorix/go-diameter/diam/dict"
+)
+
+func TestMask(t *testing.T) {
+	if m := Mask("66947451960"); m != "*******1960" {
+		t.Error("It should be *******1960 but was ", m)
+	}
+	if m := Mask("123"); m != "***" {
+		t.Error("It should be *** but was ", m)
+	}
+}
+
+func TestValue(t *testing.T) {
+	if v := Value(datatype.UTF8String("omr")); v != "omr" {
+		t.Error("It should be omr but was ", v)
+	}
+	if v := Value(datatype.Unsigned32(2001)); v != uint32(2001) {
+		t.Error("It should be 2001 but was ", v)
+	}
+}
+
+// collidingXML defines MSISDN and a non-vendor AVP with the same code.
+// The later one is what FindAVP returns for the code.
+const collidingXML = `<?xml version="1.0" encoding="UTF-8"?>
+<diameter>
+  <application id="4" type="auth" name="Test">
+    <vendor id="10415" name="TGPP"/>
+    <avp name="MSISDN" code="701" must="M,V" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+      <data type="OctetString"/>
+    </avp>
+    <avp name="Test-Number" code="701" must="M" may="P" must-not="V" may-encrypt="N">
+      <data type="UTF8String"/>
+    </avp>
+  </application>
+</diameter>`
+
+func TestMaskVendorAVP(t *testing.T) {
+	p, err := dict.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Load(strings.NewReader(collidingXML)); err != nil {
+		t.Fatal(err)
+	}
+	msisdn := func() []*diam.AVP {
+		return []*diam.AVP{{Code: 701, Flags: avp.Mbit | avp.Vbit, VendorID: 10415, Data: datatype.OctetString("66947451960")}}
+	}
+
+	var b bytes.Buffer
+	formatAVPs(&b, p, 4, msisdn(), 0)
+	if s := b.String(); s != "MSISDN = *******1960\n" {
+		t.Error("It should mask the MSISDN but was ", s)
+	}
+
+	avps := msisdn()
+	anonymize(p, 4, avps)
+	if v := string(avps[0].Data.(datatype.OctetString)); v == "66947451960" {
+		t.Error("It should anonymize the MSISDN but was ", v)
+	}
+}
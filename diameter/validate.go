@@ -0,0 +1,227 @@
+package diameter
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
+	"github.com/fiorix/go-diameter/diam/dict"
+
+	"server/logger"
+	"server/metrics"
+)
+
+// Validation modes.
+const (
+	ValidateOff    = "off"
+	ValidateLog    = "log"
+	ValidateStrict = "strict"
+)
+
+var (
+	validationLock sync.RWMutex
+	validation     = ValidateOff
+)
+
+// ValidationMode checks mode, returning off for an empty one.
+func ValidationMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return ValidateOff, nil
+	case ValidateOff, ValidateLog, ValidateStrict:
+		return mode, nil
+	}
+	return "", fmt.Errorf("diameter: unknown validation mode %q", mode)
+}
+
+// SetValidation selects how Check treats messages that break the
+// dictionary rules.
+func SetValidation(mode string) error {
+	mode, err := ValidationMode(mode)
+	if err != nil {
+		return err
+	}
+	validationLock.Lock()
+	validation = mode
+	validationLock.Unlock()
+	return nil
+}
+
+// Validation returns the current validation mode.
+func Validation() string {
+	validationLock.RLock()
+	defer validationLock.RUnlock()
+	return validation
+}
+
+// Violation is a dictionary rule broken by a message. Path names the
+// grouped AVPs leading to it.
+type Violation struct {
+	Path    string `json:"path"`
+	Problem string `json:"problem"`
+}
+
+func (v Violation) String() string {
+	if v.Path == "" {
+		return v.Problem
+	}
+	return v.Path + ": " + v.Problem
+}
+
+// ValidationError lists the violations of a message.
+type ValidationError struct {
+	Command    string
+	Request    bool
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	s := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		s[i] = v.String()
+	}
+	kind := "answer"
+	if e.Request {
+		kind = "request"
+	}
+	return fmt.Sprintf("diameter: invalid %s %s: %s", e.Command, kind, strings.Join(s, "; "))
+}
+
+// rules supplies the command and AVP definitions to validate against;
+// *dict.Parser implements it.
+type rules interface {
+	FindCommand(appid, code uint32) (*dict.Command, error)
+	FindAVP(appid uint32, code interface{}) (*dict.AVP, error)
+}
+
+// defaultRules returns the dictionary Check validates against, or nil
+// when none is loaded.
+var defaultRules = func() rules {
+	if dict.Default == nil {
+		return nil
+	}
+	return dict.Default
+}
+
+// Check validates m, sent (out) or received, against dict.Default. It
+// logs violations unless validation is off, and returns them only in
+// strict mode.
+func Check(m *diam.Message, out bool) error {
+	validationLock.RLock()
+	mode := validation
+	validationLock.RUnlock()
+	dp := defaultRules()
+	if mode == ValidateOff || dp == nil {
+		return nil
+	}
+
+	err := Validate(dp, m)
+	if err == nil {
+		return nil
+	}
+	direction := "in"
+	if out {
+		direction = "out"
+	}
+	metrics.InvalidMessages.WithLabelValues(direction).Inc()
+	logger.Warn("invalid message", "direction", direction, "hop_by_hop", m.Header.HopByHopID, "err", err)
+	if mode != ValidateStrict {
+		return nil
+	}
+	return err
+}
+
+// Validate checks that m has the required AVPs of its command, no more
+// instances of an AVP than allowed, and no unknown AVP with the M-bit
+// set, recursing into grouped AVPs. A command missing from the
+// dictionary is not checked.
+func Validate(dp rules, m *diam.Message) *ValidationError {
+	app := m.Header.ApplicationID
+	cmd, err := dp.FindCommand(app, m.Header.CommandCode)
+	if err != nil || cmd == nil {
+		return nil
+	}
+
+	e := &ValidationError{
+		Command: cmd.Name,
+		Request: m.Header.CommandFlags&diam.RequestFlag != 0,
+	}
+	r := cmd.Answer.Rule
+	if e.Request {
+		r = cmd.Request.Rule
+	}
+	e.Violations = checkAVPs(dp, app, "", m.AVP, r)
+	if len(e.Violations) == 0 {
+		return nil
+	}
+	return e
+}
+
+func checkAVPs(dp rules, app uint32, path string, avps []*diam.AVP, r []*dict.Rule) []Violation {
+	var vs []Violation
+	counts := make(map[string]int)
+	for _, a := range avps {
+		d := findAVP(dp, app, a)
+		if d == nil {
+			if a.Flags&avp.Mbit != 0 {
+				vs = append(vs, Violation{path, fmt.Sprintf("unknown AVP %s has the M-bit set", avpID(a))})
+			}
+			continue
+		}
+		counts[d.Name]++
+		if g, ok := a.Data.(*diam.GroupedAVP); ok && len(d.Data.Rule) > 0 {
+			vs = append(vs, checkAVPs(dp, app, join(path, d.Name), g.AVP, d.Data.Rule)...)
+		}
+	}
+
+	for _, rule := range r {
+		n := counts[rule.AVP]
+		switch {
+		case rule.Required && n == 0:
+			vs = append(vs, Violation{path, "missing required " + rule.AVP})
+		case rule.Min > 0 && n < rule.Min:
+			vs = append(vs, Violation{path, fmt.Sprintf("%s occurs %d times, at least %d expected", rule.AVP, n, rule.Min)})
+		case rule.Max > 0 && n > rule.Max:
+			vs = append(vs, Violation{path, fmt.Sprintf("%s occurs %d times, at most %d allowed", rule.AVP, n, rule.Max)})
+		}
+	}
+	return vs
+}
+
+// findAVP returns the definition of a, or nil when there is none. The
+// dictionary finds AVPs by code alone, so when it finds one of another
+// vendor the definitions of every application are searched for the
+// vendor of a.
+func findAVP(dp rules, app uint32, a *diam.AVP) *dict.AVP {
+	d, err := dp.FindAVP(app, a.Code)
+	if err == nil && d != nil && d.VendorID == a.VendorID {
+		return d
+	}
+	if l, ok := dp.(interface{ Apps() []*dict.App }); ok {
+		for _, da := range l.Apps() {
+			for _, d := range da.AVP {
+				if d.Code == a.Code && d.VendorID == a.VendorID {
+					return d
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// avpID names an AVP by its code and, when it has one, vendor.
+func avpID(a *diam.AVP) string {
+	if a.VendorID == 0 {
+		return fmt.Sprint(a.Code)
+	}
+	return fmt.Sprintf("%d (vendor %d)", a.Code, a.VendorID)
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + ">" + name
+}
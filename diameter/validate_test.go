@@ -0,0 +1,191 @@
+package diameter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
+	"github.com/fiorix/go-diameter/diam/datatype"
+	"github.com/fiorix/go-diameter/diam/dict"
+)
+
+// testRules is a small Credit-Control dictionary.
+type testRules struct{}
+
+var testAVPs = map[uint32]*dict.AVP{
+	263: {Name: "Session-Id", Code: 263},
+	268: {Name: "Result-Code", Code: 268},
+	456: {Name: "Multiple-Services-Credit-Control", Code: 456, Data: dict.Data{
+		Rule: []*dict.Rule{{AVP: "Rating-Group", Max: 1}},
+	}},
+	432:  {Name: "Rating-Group", Code: 432},
+	1032: {Name: "RAT-Type", Code: 1032, VendorID: 10415},
+}
+
+func (testRules) FindCommand(appid, code uint32) (*dict.Command, error) {
+	if code != 272 {
+		return nil, errors.New("unknown command")
+	}
+	return &dict.Command{
+		Code: 272,
+		Name: "Credit-Control",
+		Answer: dict.CommandRule{Rule: []*dict.Rule{
+			{AVP: "Session-Id", Required: true, Max: 1},
+			{AVP: "Result-Code", Required: true, Max: 1},
+			{AVP: "Multiple-Services-Credit-Control"},
+		}},
+	}, nil
+}
+
+func (testRules) FindAVP(appid uint32, code interface{}) (*dict.AVP, error) {
+	if a, ok := testAVPs[code.(uint32)]; ok {
+		return a, nil
+	}
+	return nil, errors.New("unknown AVP")
+}
+
+func TestValidateAnswer(t *testing.T) {
+	m := &diam.Message{
+		Header: &diam.Header{CommandCode: 272, ApplicationID: 4},
+		AVP: []*diam.AVP{
+			{Code: 263, Data: datatype.UTF8String("s1")},
+			{Code: 263, Data: datatype.UTF8String("s2")},
+			{Code: 456, Data: &diam.GroupedAVP{AVP: []*diam.AVP{
+				{Code: 432, Data: datatype.Unsigned32(1)},
+				{Code: 432, Data: datatype.Unsigned32(2)},
+			}}},
+			{Code: 99999, Flags: avp.Mbit, Data: datatype.Unsigned32(0)},
+			{Code: 99998, Data: datatype.Unsigned32(0)},
+		},
+	}
+
+	err := Validate(testRules{}, m)
+	if err == nil {
+		t.Fatal("It should find violations")
+	}
+	got := make([]string, len(err.Violations))
+	for i, v := range err.Violations {
+		got[i] = v.String()
+	}
+	want := []string{
+		"Multiple-Services-Credit-Control: Rating-Group occurs 2 times, at most 1 allowed",
+		"unknown AVP 99999 has the M-bit set",
+		"Session-Id occurs 2 times, at most 1 allowed",
+		"missing required Result-Code",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Error("It should report\n", strings.Join(want, "\n"), "\nbut reported\n", strings.Join(got, "\n"))
+	}
+	if err.Request {
+		t.Error("It should be an answer")
+	}
+}
+
+func TestValidateValid(t *testing.T) {
+	m := &diam.Message{
+		Header: &diam.Header{CommandCode: 272, ApplicationID: 4},
+		AVP: []*diam.AVP{
+			{Code: 263, Data: datatype.UTF8String("s1")},
+			{Code: 268, Data: datatype.Unsigned32(2001)},
+		},
+	}
+	if err := Validate(testRules{}, m); err != nil {
+		t.Error("It should be valid but was ", err)
+	}
+}
+
+func TestSetValidation(t *testing.T) {
+	if err := SetValidation("loud"); err == nil {
+		t.Error("It should reject an unknown mode")
+	}
+	if err := SetValidation(""); err != nil || validation != ValidateOff {
+		t.Error("It should default to off but was ", validation)
+	}
+}
+
+func TestValidateVendor(t *testing.T) {
+	m := &diam.Message{
+		Header: &diam.Header{CommandCode: 272, ApplicationID: 4},
+		AVP: []*diam.AVP{
+			{Code: 263, Data: datatype.UTF8String("s1")},
+			{Code: 268, Data: datatype.Unsigned32(2001)},
+			// Session-Id's code, but of a vendor.
+			{Code: 263, Flags: avp.Mbit | avp.Vbit, VendorID: 9, Data: datatype.UTF8String("s2")},
+			{Code: 1032, Flags: avp.Mbit | avp.Vbit, VendorID: 10415, Data: datatype.Enumerated(1004)},
+			// RAT-Type's code, but without its vendor.
+			{Code: 1032, Flags: avp.Mbit, Data: datatype.Enumerated(1004)},
+		},
+	}
+	err := Validate(testRules{}, m)
+	if err == nil {
+		t.Fatal("It should find violations")
+	}
+	got := make([]string, len(err.Violations))
+	for i, v := range err.Violations {
+		got[i] = v.String()
+	}
+	want := []string{
+		"unknown AVP 263 (vendor 9) has the M-bit set",
+		"unknown AVP 1032 has the M-bit set",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Error("It should report\n", strings.Join(want, "\n"), "\nbut reported\n", strings.Join(got, "\n"))
+	}
+}
+
+func TestCheckModes(t *testing.T) {
+	defer func(r func() rules) { defaultRules = r }(defaultRules)
+	defaultRules = func() rules { return testRules{} }
+	defer SetValidation(ValidateOff)
+
+	m := &diam.Message{
+		Header: &diam.Header{CommandCode: 272, ApplicationID: 4},
+		AVP:    []*diam.AVP{{Code: 263, Data: datatype.UTF8String("s1")}},
+	}
+	for mode, fails := range map[string]bool{ValidateOff: false, ValidateLog: false, ValidateStrict: true} {
+		SetValidation(mode)
+		err := Check(m, false)
+		if (err != nil) != fails {
+			t.Error("It should fail only in strict mode but was ", err, " in mode "+mode)
+		}
+		if _, ok := err.(*ValidationError); fails && !ok {
+			t.Error("It should be a ValidationError but was ", err)
+		}
+	}
+}
+
+// appRules is testRules that also lists its AVPs by application, as
+// *dict.Parser does.
+type appRules struct {
+	testRules
+}
+
+func (appRules) Apps() []*dict.App {
+	return []*dict.App{{ID: 4, AVP: []*dict.AVP{
+		testAVPs[263],
+		{Name: "Session-Id-Vendor", Code: 263, VendorID: 9},
+	}}}
+}
+
+func TestFindAVPVendor(t *testing.T) {
+	for _, tt := range []struct {
+		rules rules
+		avp   *diam.AVP
+		want  string
+	}{
+		{testRules{}, &diam.AVP{Code: 263}, "Session-Id"},
+		{testRules{}, &diam.AVP{Code: 263, VendorID: 9}, ""},
+		{appRules{}, &diam.AVP{Code: 263, VendorID: 9}, "Session-Id-Vendor"},
+		{appRules{}, &diam.AVP{Code: 263, VendorID: 10}, ""},
+	} {
+		name := ""
+		if d := findAVP(tt.rules, 4, tt.avp); d != nil {
+			name = d.Name
+		}
+		if name != tt.want {
+			t.Error("It should find \""+tt.want+"\" for ", avpID(tt.avp), " but found ", name)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"server/dictionary"
+)
+
+const dictUsage = `usage: dccserve dict [-config file] [-files a.xml,b.xml] command
+
+commands:
+  validate          check the dictionaries, exiting 1 on errors
+  list [filter]     list AVPs whose name contains filter, or with code filter
+  show COMMAND      show the rule tree of a command such as CCR or CCA
+`
+
+// runDict inspects and validates the dictionary files.
+func runDict(args []string) {
+	fs := flag.NewFlagSet("dict", flag.ExitOnError)
+	cfgFile := fs.String("config", "", "configuration file whose dictionaries are loaded after the built-in ones")
+	files := fs.String("files", "", "comma separated dictionary files, in load order, instead of those of the configuration")
+	vendor := fs.Int("vendor", -1, "list only AVPs of this vendor id")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, dictUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	paths := strings.Split(*files, ",")
+	if *files == "" {
+		cfg, _, err := readConfig(*cfgFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "config load failed:", err)
+			os.Exit(exitFailure)
+		}
+		paths = append(dictionary.Files(), cfg.Dictionaries...)
+	}
+	d, err := dictionary.Parse(paths...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFailure)
+	}
+
+	switch fs.Arg(0) {
+	case "validate":
+		errors := 0
+		for _, p := range d.Validate() {
+			fmt.Println(p)
+			if p.Severity == dictionary.Error {
+				errors++
+			}
+		}
+		if errors > 0 {
+			os.Exit(exitFailure)
+		}
+	case "list":
+		filter := fs.Arg(1)
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "CODE\tVENDOR\tNAME\tTYPE\tFLAGS")
+		for _, a := range d.AVPs() {
+			if *vendor >= 0 && a.VendorID != uint32(*vendor) {
+				continue
+			}
+			if filter != "" && !strings.Contains(strings.ToLower(a.Name), strings.ToLower(filter)) && fmt.Sprint(a.Code) != filter {
+				continue
+			}
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\n", a.Code, a.VendorID, a.Name, a.Data.Type, a.Must)
+		}
+		w.Flush()
+	case "show":
+		cmd, answer, ok := d.FindCommand(fs.Arg(1))
+		if !ok {
+			fmt.Fprintln(os.Stderr, "unknown command", fs.Arg(1))
+			os.Exit(exitFailure)
+		}
+		d.WriteRuleTree(os.Stdout, cmd, answer)
+	default:
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+}
@@ -0,0 +1,197 @@
+// Command avpgen generates Go constants for the AVP codes and enumerated
+// values of the dictionary files, and typed structs with avp tags for
+// selected grouped AVPs, named after the AVP with a Group suffix. It is
+// run by go generate in package avps.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode"
+
+	"server/dictionary"
+)
+
+func main() {
+	files := flag.String("files", strings.Join(dictionary.Files(), ","), "comma separated dictionary files, in load order")
+	pkg := flag.String("pkg", "avps", "package of the generated file")
+	out := flag.String("out", "avps_gen.go", "file to write")
+	structs := flag.String("structs", "", "comma separated grouped AVPs to generate structs for, with their grouped members")
+	skip := flag.String("skip", "", "comma separated AVPs left out of the structs")
+	flag.Parse()
+
+	d, err := dictionary.Parse(strings.Split(*files, ",")...)
+	if err != nil {
+		fail(err)
+	}
+	src, err := generate(d, *pkg, split(*structs), split(*skip))
+	if err != nil {
+		fail(err)
+	}
+	if err = ioutil.WriteFile(*out, src, 0644); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "avpgen:", err)
+	os.Exit(1)
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// generate renders the Go source for d. Constants are emitted for
+// vendor-specific AVPs only; the base and credit-control ones are in
+// go-diameter's avp package.
+func generate(d *dictionary.Dictionary, pkg string, structs, skip []string) ([]byte, error) {
+	var b bytes.Buffer
+	byName := make(map[string]dictionary.AVP)
+	var vendor []dictionary.AVP
+	for _, a := range d.AVPs() {
+		if _, ok := byName[a.Name]; !ok && a.VendorID != 0 {
+			vendor = append(vendor, a)
+		}
+		byName[a.Name] = a
+	}
+
+	idents := make(map[string]string)
+	declare := func(ident, what string) error {
+		if prev, ok := idents[ident]; ok {
+			return fmt.Errorf("%s and %s both generate %s", prev, what, ident)
+		}
+		idents[ident] = what
+		return nil
+	}
+
+	fmt.Fprintf(&b, "// AVP codes.\nconst (\n")
+	for _, a := range vendor {
+		id := ident(a.Name, false)
+		if err := declare(id, a.Name); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, "\t%s = %d // vendor %d, %s\n", id, a.Code, a.VendorID, a.Data.Type)
+	}
+	fmt.Fprintf(&b, ")\n\n")
+
+	for _, a := range vendor {
+		if len(a.Data.Items) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "// %s values.\nconst (\n", a.Name)
+		for _, it := range a.Data.Items {
+			id := ident(a.Name, false) + ident(it.Name, true)
+			if err := declare(id, a.Name+" "+it.Name); err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&b, "\t%s = %d\n", id, it.Code)
+		}
+		fmt.Fprintf(&b, ")\n\n")
+	}
+
+	skipped := make(map[string]bool)
+	for _, s := range skip {
+		skipped[s] = true
+	}
+	usesTime := false
+	done := make(map[string]bool)
+	queue := append([]string(nil), structs...)
+	var types bytes.Buffer
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if done[name] {
+			continue
+		}
+		done[name] = true
+
+		a, ok := byName[name]
+		if !ok || a.Data.Type != "Grouped" {
+			return nil, fmt.Errorf("%s is not a grouped AVP", name)
+		}
+		typeName := ident(a.Name, false) + "Group"
+		if err := declare(typeName, a.Name+" struct"); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&types, "// %s is the grouped AVP %s (%d).\ntype %s struct {\n", typeName, a.Name, a.Code, typeName)
+		for _, r := range a.Data.Rules {
+			m, ok := byName[r.AVP]
+			if !ok || skipped[r.AVP] {
+				continue
+			}
+			typ := goType(m.Data.Type)
+			if m.Data.Type == "Grouped" {
+				typ = ident(m.Name, false) + "Group"
+				queue = append(queue, m.Name)
+			}
+			if typ == "time.Time" {
+				usesTime = true
+			}
+			if r.Max != 1 {
+				typ = "[]" + typ
+			}
+			fmt.Fprintf(&types, "\t%s %s `avp:%q`\n", ident(m.Name, false), typ, m.Name)
+		}
+		fmt.Fprintf(&types, "}\n\n")
+	}
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by avpgen from the dictionary XML. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", pkg)
+	if usesTime {
+		fmt.Fprintf(&src, "import \"time\"\n\n")
+	}
+	src.Write(b.Bytes())
+	src.Write(types.Bytes())
+	return format.Source(src.Bytes())
+}
+
+// goType maps a dictionary data type to the Go type the answer is
+// unmarshalled into.
+func goType(t string) string {
+	switch t {
+	case "Integer32", "Enumerated":
+		return "int32"
+	case "Integer64":
+		return "int64"
+	case "Unsigned32":
+		return "uint32"
+	case "Unsigned64":
+		return "uint64"
+	case "Float32":
+		return "float32"
+	case "Float64":
+		return "float64"
+	case "Time":
+		return "time.Time"
+	}
+	return "string"
+}
+
+// ident turns a dictionary name such as Related-Object-ID into a Go
+// identifier. Enumerated value names are usually upper case and are
+// title cased when title is set.
+func ident(name string, title bool) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if title && strings.ToUpper(part) == part {
+			part = strings.ToLower(part)
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	s := b.String()
+	if s == "" || unicode.IsDigit(rune(s[0])) {
+		s = "AVP" + s
+	}
+	return s
+}
@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestIdent(t *testing.T) {
+	for name, want := range map[string]string{
+		"Balance-Information": "BalanceInformation",
+		"SSP-Time":            "SSPTime",
+		"3GPP-IMSI":           "AVP3GPPIMSI",
+	} {
+		if got := ident(name, false); got != want {
+			t.Error("It should be "+want+" but was ", got)
+		}
+	}
+	if got := ident("END_USER_E164", true); got != "EndUserE164" {
+		t.Error("It should be EndUserE164 but was ", got)
+	}
+}
@@ -1,27 +1,52 @@
 package dictionary
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/fiorix/go-diameter/diam/dict"
-	"log"
-	"os"
+	"server/logger"
 )
 
 func Load() *dict.Parser {
-	var err error
-	gopath := os.Getenv("GOPATH")
-
-	parser, err := dict.NewParser(gopath + "/src/server/dictionary/base.xml")
+	files := Files()
+	parser, err := dict.NewParser(files[0])
 
 	var run = func(filename string) {
 		if err != nil {
-			log.Println(err.Error())
+			logger.Error("dictionary load failed", "file", filename, "err", err)
 			return
 		}
 		parser.LoadFile(filename)
 	}
 
-	run(gopath + "/src/server/dictionary/creditcontrol.xml")
-	run(gopath + "/src/server/dictionary/tgpp_ro_rf.xml")
+	for _, f := range files[1:] {
+		run(f)
+	}
 
 	return parser
 }
+
+// New reads the dictionary files followed by extra, failing on the
+// first file that does not load.
+func New(extra ...string) (*dict.Parser, error) {
+	return LoadFiles(append(Files(), extra...)...)
+}
+
+// LoadFiles reads the dictionary files at files, in order, failing on
+// the first file that does not load.
+func LoadFiles(files ...string) (*dict.Parser, error) {
+	if len(files) == 0 {
+		return nil, errors.New("dictionary: no files")
+	}
+	parser, err := dict.NewParser(files[0])
+	if err != nil {
+		return nil, fmt.Errorf("dictionary %s: %v", files[0], err)
+	}
+	for _, f := range files[1:] {
+		if err = parser.LoadFile(f); err != nil {
+			return nil, fmt.Errorf("dictionary %s: %v", f, err)
+		}
+	}
+	return parser, nil
+}
@@ -0,0 +1,319 @@
+package dictionary
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// The types below mirror the dictionary XML so that mistakes the
+// go-diameter parser silently accepts can be reported.
+
+type Dictionary struct {
+	Files []File
+}
+
+type File struct {
+	Path string
+	Apps []App `xml:"application"`
+}
+
+type App struct {
+	ID       uint32    `xml:"id,attr"`
+	Vendors  []Vendor  `xml:"vendor"`
+	Commands []Command `xml:"command"`
+	AVPs     []AVP     `xml:"avp"`
+}
+
+type Vendor struct {
+	ID   uint32 `xml:"id,attr"`
+	Name string `xml:"name,attr"`
+}
+
+type Command struct {
+	Code    uint32 `xml:"code,attr"`
+	Short   string `xml:"short,attr"`
+	Name    string `xml:"name,attr"`
+	Request []Rule `xml:"request>rule"`
+	Answer  []Rule `xml:"answer>rule"`
+}
+
+type Rule struct {
+	AVP      string `xml:"avp,attr"`
+	Required bool   `xml:"required,attr"`
+	Min      int    `xml:"min,attr"`
+	Max      int    `xml:"max,attr"`
+}
+
+type AVP struct {
+	Name     string `xml:"name,attr"`
+	Code     uint32 `xml:"code,attr"`
+	Must     string `xml:"must,attr"`
+	VendorID uint32 `xml:"vendor-id,attr"`
+	Data     Data   `xml:"data"`
+
+	// File and App locate the definition.
+	File string `xml:"-"`
+	App  uint32 `xml:"-"`
+}
+
+type Data struct {
+	Type  string `xml:"type,attr"`
+	Items []Item `xml:"item"`
+	Rules []Rule `xml:"rule"`
+}
+
+type Item struct {
+	Code int32  `xml:"code,attr"`
+	Name string `xml:"name,attr"`
+}
+
+var types = map[string]bool{
+	"Address": true, "DiameterIdentity": true, "DiameterURI": true,
+	"Enumerated": true, "Float32": true, "Float64": true, "Grouped": true,
+	"IPFilterRule": true, "IPv4": true, "Integer32": true, "Integer64": true,
+	"OctetString": true, "QoSFilterRule": true, "Time": true,
+	"UTF8String": true, "Unsigned32": true, "Unsigned64": true,
+}
+
+// Files returns the dictionary files loaded by Load, in order.
+func Files() []string {
+	dir := os.Getenv("GOPATH") + "/src/server/dictionary/"
+	return []string{dir + "base.xml", dir + "creditcontrol.xml", dir + "tgpp_ro_rf.xml"}
+}
+
+// Parse reads the dictionary files at paths. They are loaded with
+// LoadFiles first, so that a file go-diameter refuses is reported as
+// such and Validate only adds the checks it skips.
+func Parse(paths ...string) (*Dictionary, error) {
+	if _, err := LoadFiles(paths...); err != nil {
+		return nil, err
+	}
+	d := &Dictionary{}
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		file, err := parseFile(p, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		d.Files = append(d.Files, file)
+	}
+	return d, nil
+}
+
+func parseFile(path string, r io.Reader) (File, error) {
+	file := File{Path: path}
+	if err := xml.NewDecoder(r).Decode(&file); err != nil {
+		return file, fmt.Errorf("%s: %v", path, err)
+	}
+	for i := range file.Apps {
+		app := &file.Apps[i]
+		for j := range app.AVPs {
+			a := &app.AVPs[j]
+			a.File = path
+			a.App = app.ID
+			if a.VendorID == 0 && strings.Contains(a.Must, "V") && len(app.Vendors) > 0 {
+				a.VendorID = app.Vendors[0].ID
+			}
+		}
+	}
+	return file, nil
+}
+
+// AVPs returns every AVP definition sorted by vendor and code.
+func (d *Dictionary) AVPs() []AVP {
+	var avps []AVP
+	for _, f := range d.Files {
+		for _, app := range f.Apps {
+			avps = append(avps, app.AVPs...)
+		}
+	}
+	sort.SliceStable(avps, func(i, j int) bool {
+		if avps[i].VendorID != avps[j].VendorID {
+			return avps[i].VendorID < avps[j].VendorID
+		}
+		return avps[i].Code < avps[j].Code
+	})
+	return avps
+}
+
+// FindAVP returns the last definition of the AVP name, as the
+// go-diameter parser keeps it.
+func (d *Dictionary) FindAVP(name string) (AVP, bool) {
+	var found AVP
+	ok := false
+	for _, a := range d.AVPs() {
+		if a.Name == name {
+			found, ok = a, true
+		}
+	}
+	return found, ok
+}
+
+// FindCommand returns the command named name, or the request or answer
+// of the command with short name such as "CC" given as "CCR" or "CCA".
+// answer reports which of them was named.
+func (d *Dictionary) FindCommand(name string) (cmd Command, answer bool, ok bool) {
+	for _, f := range d.Files {
+		for _, app := range f.Apps {
+			for _, c := range app.Commands {
+				switch {
+				case strings.EqualFold(name, c.Name), strings.EqualFold(name, c.Short+"R"):
+					return c, false, true
+				case strings.EqualFold(name, c.Short+"A"):
+					return c, true, true
+				}
+			}
+		}
+	}
+	return Command{}, false, false
+}
+
+// Severity of a Problem.
+const (
+	Error   = "error"
+	Warning = "warning"
+)
+
+type Problem struct {
+	Severity string
+	File     string
+	Message  string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s: %s", p.File, p.Severity, p.Message)
+}
+
+// Validate checks the dictionary for duplicate AVP codes and names,
+// unknown data types, rules that reference undefined AVPs, enumerated
+// AVPs without values and grouped AVPs without rules. go-diameter loads
+// rules referencing undefined AVPs and ignores them, so those are
+// warnings.
+func (d *Dictionary) Validate() []Problem {
+	var problems []Problem
+	report := func(sev, file, format string, args ...interface{}) {
+		problems = append(problems, Problem{sev, file, fmt.Sprintf(format, args...)})
+	}
+
+	type key struct{ vendor, code uint32 }
+	byCode := make(map[key]AVP)
+	byName := make(map[string]AVP)
+	for _, f := range d.Files {
+		for _, app := range f.Apps {
+			for _, a := range app.AVPs {
+				k := key{a.VendorID, a.Code}
+				if prev, ok := byCode[k]; ok && prev.Name != a.Name {
+					report(Error, f.Path, "AVP %s code %d (vendor %d) is already defined as %s in %s",
+						a.Name, a.Code, a.VendorID, prev.Name, prev.File)
+				}
+				if prev, ok := byName[a.Name]; ok && (prev.Code != a.Code || prev.VendorID != a.VendorID) {
+					report(Error, f.Path, "AVP %s is defined again with code %d (vendor %d), was %d (vendor %d) in %s",
+						a.Name, a.Code, a.VendorID, prev.Code, prev.VendorID, prev.File)
+				}
+				byCode[k] = a
+				byName[a.Name] = a
+
+				switch {
+				case !types[a.Data.Type]:
+					report(Error, f.Path, "AVP %s has unknown data type %q", a.Name, a.Data.Type)
+				case a.Data.Type == "Enumerated" && len(a.Data.Items) == 0:
+					report(Warning, f.Path, "enumerated AVP %s has no values", a.Name)
+				case a.Data.Type == "Grouped" && len(a.Data.Rules) == 0:
+					report(Warning, f.Path, "grouped AVP %s has no rules", a.Name)
+				case a.Data.Type != "Grouped" && len(a.Data.Rules) > 0:
+					report(Error, f.Path, "AVP %s of type %s has rules", a.Name, a.Data.Type)
+				}
+
+				items := make(map[int32]string)
+				names := make(map[string]int32)
+				for _, it := range a.Data.Items {
+					if prev, ok := items[it.Code]; ok {
+						report(Error, f.Path, "AVP %s value %d is defined as both %s and %s", a.Name, it.Code, prev, it.Name)
+					}
+					if prev, ok := names[it.Name]; ok {
+						report(Error, f.Path, "AVP %s value name %s is used for both %d and %d", a.Name, it.Name, prev, it.Code)
+					}
+					items[it.Code] = it.Name
+					names[it.Name] = it.Code
+				}
+			}
+		}
+	}
+
+	checkRules := func(file, owner string, rules []Rule) {
+		for _, r := range rules {
+			// *[AVP] allows any AVP.
+			if r.AVP == "AVP" {
+				continue
+			}
+			if _, ok := byName[r.AVP]; !ok {
+				report(Warning, file, "%s references undefined AVP %s", owner, r.AVP)
+			}
+		}
+	}
+	for _, f := range d.Files {
+		for _, app := range f.Apps {
+			for _, c := range app.Commands {
+				checkRules(f.Path, c.Name+" request", c.Request)
+				checkRules(f.Path, c.Name+" answer", c.Answer)
+			}
+			for _, a := range app.AVPs {
+				checkRules(f.Path, a.Name, a.Data.Rules)
+			}
+		}
+	}
+	return problems
+}
+
+// WriteRuleTree writes the rules of the request, or the answer, of cmd
+// with grouped AVPs expanded to their members.
+func (d *Dictionary) WriteRuleTree(w io.Writer, cmd Command, answer bool) {
+	rules, kind := cmd.Request, "Request"
+	if answer {
+		rules, kind = cmd.Answer, "Answer"
+	}
+	fmt.Fprintf(w, "%s-%s (%d)\n", cmd.Name, kind, cmd.Code)
+	d.writeRules(w, rules, 1, map[string]bool{})
+}
+
+func (d *Dictionary) writeRules(w io.Writer, rules []Rule, depth int, seen map[string]bool) {
+	indent := strings.Repeat("  ", depth)
+	for _, r := range rules {
+		a, ok := d.FindAVP(r.AVP)
+		if r.AVP == "AVP" {
+			fmt.Fprintf(w, "%s%s\n", indent, occurrence(r))
+			continue
+		}
+		if !ok {
+			fmt.Fprintf(w, "%s%s undefined\n", indent, occurrence(r))
+			continue
+		}
+		fmt.Fprintf(w, "%s%s %d %s\n", indent, occurrence(r), a.Code, a.Data.Type)
+		if a.Data.Type == "Grouped" && !seen[a.Name] {
+			seen[a.Name] = true
+			d.writeRules(w, a.Data.Rules, depth+1, seen)
+			delete(seen, a.Name)
+		}
+	}
+}
+
+// occurrence renders a rule in the ABNF style of the RFCs: {AVP} for
+// required, [AVP] for optional, prefixed with * when it may repeat.
+func occurrence(r Rule) string {
+	s := "[" + r.AVP + "]"
+	if r.Required {
+		s = "{" + r.AVP + "}"
+	}
+	if r.Max != 1 {
+		s = "*" + s
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package dictionary
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testXML = `<?xml version="1.0" encoding="UTF-8"?>
+<diameter>
+	<application id="4">
+		<vendor id="10415" name="TGPP"/>
+		<command code="272" short="CC" name="Credit-Control">
+			<request>
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Missing-AVP" required="false" max="1"/>
+			</request>
+			<answer>
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Group" required="false"/>
+				<rule avp="AVP" required="false"/>
+			</answer>
+		</command>
+		<avp name="Session-Id" code="263" must="M" may="P" must-not="V" may-encrypt="Y">
+			<data type="UTF8String"/>
+		</avp>
+		<avp name="Copy-Of-Session-Id" code="263" must="M" may="P" must-not="V" may-encrypt="Y">
+			<data type="UTF8String"/>
+		</avp>
+		<avp name="Vendor-Session-Id" code="263" must="V,M" may="P" must-not="" may-encrypt="Y">
+			<data type="UTF8String"/>
+		</avp>
+		<avp name="Group" code="1000" must="M" may="P" must-not="V" may-encrypt="Y">
+			<data type="Grouped">
+				<rule avp="Session-Id" required="true" max="1"/>
+			</data>
+		</avp>
+		<avp name="Empty-Group" code="1001" must="M" may="P" must-not="V" may-encrypt="Y">
+			<data type="Grouped"/>
+		</avp>
+		<avp name="State" code="1002" must="M" may="P" must-not="V" may-encrypt="Y">
+			<data type="Enumerated"/>
+		</avp>
+		<avp name="Bad-Type" code="1003" must="M" may="P" must-not="V" may-encrypt="Y">
+			<data type="Unsigned31"/>
+		</avp>
+	</application>
+</diameter>`
+
+func testDictionary(t *testing.T) *Dictionary {
+	f, err := parseFile("test.xml", strings.NewReader(testXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Dictionary{Files: []File{f}}
+}
+
+func TestValidate(t *testing.T) {
+	var got []string
+	for _, p := range testDictionary(t).Validate() {
+		got = append(got, p.Severity+": "+p.Message)
+	}
+	want := []string{
+		"error: AVP Copy-Of-Session-Id code 263 (vendor 0) is already defined as Session-Id in test.xml",
+		"warning: grouped AVP Empty-Group has no rules",
+		"warning: enumerated AVP State has no values",
+		`error: AVP Bad-Type has unknown data type "Unsigned31"`,
+		"warning: Credit-Control request references undefined AVP Missing-AVP",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Error("It should report\n", strings.Join(want, "\n"), "\nbut reported\n", strings.Join(got, "\n"))
+	}
+}
+
+func TestVendorFromApplication(t *testing.T) {
+	a, ok := testDictionary(t).FindAVP("Vendor-Session-Id")
+	if !ok {
+		t.Fatal("It should find Vendor-Session-Id")
+	}
+	if a.VendorID != 10415 {
+		t.Error("It should be vendor 10415 but was ", a.VendorID)
+	}
+}
+
+func TestWriteRuleTree(t *testing.T) {
+	d := testDictionary(t)
+	cmd, answer, ok := d.FindCommand("CCA")
+	if !ok || !answer {
+		t.Fatal("It should find the CCA")
+	}
+
+	var b bytes.Buffer
+	d.WriteRuleTree(&b, cmd, answer)
+	want := "Credit-Control-Answer (272)\n" +
+		"  {Session-Id} 263 UTF8String\n" +
+		"  *[Group] 1000 Grouped\n" +
+		"    {Session-Id} 263 UTF8String\n" +
+		"  *[AVP]\n"
+	if b.String() != want {
+		t.Error("It should be\n", want, "but was\n", b.String())
+	}
+}
+
+func TestValidateDictionaries(t *testing.T) {
+	d, err := Parse("base.xml", "creditcontrol.xml", "tgpp_ro_rf.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range d.Validate() {
+		if p.Severity == Error {
+			t.Error("It should load the dictionaries without errors but reported ", p)
+		}
+	}
+}
+
+func TestParseRefusedFile(t *testing.T) {
+	if _, err := Parse("base.xml", "missing.xml"); err == nil {
+		t.Error("It should refuse a file that does not load")
+	}
+}
@@ -0,0 +1,137 @@
+// Package logger writes leveled, structured log entries as JSON lines.
+//
+//	logger.Info("ccr sent", "session_id", sid, "hop_by_hop", hbh)
+//
+// Each entry carries the time, level and message followed by the given
+// key/value pairs.
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return "info"
+}
+
+// ParseLevel converts a level name such as "debug" to a Level.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "", "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("logger: unknown level %q", s)
+}
+
+var (
+	mu    sync.Mutex
+	out   io.Writer = os.Stderr
+	level           = LevelInfo
+	trace bool
+)
+
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	out = w
+	mu.Unlock()
+}
+
+func SetLevel(l Level) {
+	mu.Lock()
+	level = l
+	mu.Unlock()
+}
+
+// SetTrace turns on logging of full Diameter messages at debug level.
+func SetTrace(on bool) {
+	mu.Lock()
+	trace = on
+	mu.Unlock()
+}
+
+// Tracing reports whether full messages should be logged.
+func Tracing() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return trace && level <= LevelDebug
+}
+
+func Enabled(l Level) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return l >= level
+}
+
+func Debug(msg string, kv ...interface{}) { write(LevelDebug, msg, kv) }
+func Info(msg string, kv ...interface{})  { write(LevelInfo, msg, kv) }
+func Warn(msg string, kv ...interface{})  { write(LevelWarn, msg, kv) }
+func Error(msg string, kv ...interface{}) { write(LevelError, msg, kv) }
+
+// Fatal logs at error level and exits.
+func Fatal(msg string, kv ...interface{}) {
+	write(LevelError, msg, kv)
+	os.Exit(1)
+}
+
+func write(l Level, msg string, kv []interface{}) {
+	if !Enabled(l) {
+		return
+	}
+
+	e := make(map[string]interface{}, 3+len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		k := fmt.Sprint(kv[i])
+		switch v := kv[i+1].(type) {
+		case error:
+			e[k] = v.Error()
+		case fmt.Stringer:
+			e[k] = v.String()
+		default:
+			e[k] = v
+		}
+	}
+	if len(kv)%2 == 1 {
+		e["extra"] = fmt.Sprint(kv[len(kv)-1])
+	}
+	e["time"] = time.Now().Format(time.RFC3339Nano)
+	e["level"] = l.String()
+	e["msg"] = msg
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		b, _ = json.Marshal(map[string]string{"level": "error", "msg": msg, "error": err.Error()})
+	}
+
+	mu.Lock()
+	out.Write(append(b, '\n'))
+	mu.Unlock()
+}
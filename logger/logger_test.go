@@ -0,0 +1,77 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+func TestWriteJSONLine(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(os.Stderr)
+
+	Info("ccr sent", "session_id", "dtac.co.th;OMR1", "err", errors.New("boom"))
+
+	var e map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e["msg"] != "ccr sent" || e["level"] != "info" {
+		t.Error("Unexpected entry ", e)
+	}
+	if e["session_id"] != "dtac.co.th;OMR1" {
+		t.Error("It should be dtac.co.th;OMR1 but was ", e["session_id"])
+	}
+	if e["err"] != "boom" {
+		t.Error("It should be boom but was ", e["err"])
+	}
+}
+
+func TestLevelFilter(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(os.Stderr)
+	SetLevel(LevelWarn)
+	defer SetLevel(LevelInfo)
+
+	Info("dropped")
+	if buf.Len() != 0 {
+		t.Error("It should drop info entries but wrote ", buf.String())
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if l, err := ParseLevel("DEBUG"); err != nil || l != LevelDebug {
+		t.Error("It should be debug but was ", l, err)
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("It should reject an unknown level")
+	}
+}
+
+func TestPath(t *testing.T) {
+	for _, tt := range []struct {
+		path   string
+		params map[string]string
+		want   string
+	}{
+		{"/balance/dtac/66812345678", map[string]string{"subr": "66812345678"}, "/balance/dtac/*******5678"},
+		{"/balance/dtac/tel:66812345678/check", map[string]string{"subr": "tel:66812345678"}, "/balance/dtac/***********5678/check"},
+		{"/debit/dtac/+66812345678", nil, "/debit/dtac/********5678"},
+		{"/sessions/dtac.co.th;OMR1;2", nil, "/sessions/dtac.co.th;OMR1;2"},
+		{"/healthz", nil, "/healthz"},
+	} {
+		u, _ := url.Parse(tt.path)
+		req := &rest.Request{Request: &http.Request{URL: u}, PathParams: tt.params}
+		if v := Path(req); v != tt.want {
+			t.Error("It should be "+tt.want+" but was ", v)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package logger
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+// RequestIDHeader carries the correlation id of an HTTP request. A
+// client may set it; otherwise one is generated.
+const RequestIDHeader = "X-Request-Id"
+
+const envRequestID = "REQUEST_ID"
+
+// RequestID returns the correlation id assigned to req by
+// RequestMiddleware.
+func RequestID(req *rest.Request) string {
+	id, _ := req.Env[envRequestID].(string)
+	return id
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Mask hides all but the last four characters of a subscriber number.
+func Mask(s string) string {
+	if len(s) <= 4 {
+		return strings.Repeat("*", len(s))
+	}
+	return strings.Repeat("*", len(s)-4) + s[len(s)-4:]
+}
+
+// Path returns the URL path of req fit for the logs: the :subr
+// parameter, and any segment that looks like a subscriber number when
+// the request has not been routed yet, are masked.
+func Path(req *rest.Request) string {
+	subr := req.PathParams["subr"]
+	segs := strings.Split(req.URL.Path, "/")
+	for i, seg := range segs {
+		if (subr != "" && seg == subr) || isNumber(seg) {
+			segs[i] = Mask(seg)
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+func isNumber(s string) bool {
+	s = strings.TrimPrefix(s, "+")
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// StatusWriter records the status code written through it.
+type StatusWriter struct {
+	rest.ResponseWriter
+	Code int
+}
+
+// NewStatusWriter wraps w, assuming 200 until a status is written.
+func NewStatusWriter(w rest.ResponseWriter) *StatusWriter {
+	return &StatusWriter{ResponseWriter: w, Code: http.StatusOK}
+}
+
+func (w *StatusWriter) WriteHeader(code int) {
+	w.Code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// RequestMiddleware assigns every HTTP request a correlation id,
+// echoes it in the response and logs the request once it completes.
+type RequestMiddleware struct{}
+
+func (mw *RequestMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, req *rest.Request) {
+		id := req.Header.Get(RequestIDHeader)
+		if id == "" {
+			id = newID()
+		}
+		req.Env[envRequestID] = id
+		w.Header().Set(RequestIDHeader, id)
+
+		start := time.Now()
+		sw := NewStatusWriter(w)
+		h(sw, req)
+
+		Info("http request",
+			"request_id", id,
+			"method", req.Method,
+			"path", Path(req),
+			"status", sw.Code,
+			"duration_ms", time.Since(start).Seconds()*1000,
+		)
+	}
+}
@@ -1,46 +1,100 @@
 package main
 
 import (
-	"fmt"
+	"flag"
 	"github.com/ant0ine/go-json-rest/rest"
-	"log"
 	"net/http"
-	"dccserve/balance"
+	"os"
+	"server/auth"
+	"server/balance"
+	"server/logger"
+	"server/metrics"
 )
 
 func main() {
-	go balance.Start()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "sim":
+			runSim(os.Args[2:])
+			return
+		case "query":
+			runQuery(os.Args[2:])
+			return
+		case "send":
+			runSend(os.Args[2:])
+			return
+		case "dict":
+			runDict(os.Args[2:])
+			return
+		}
+	}
+
+	cfgFile := flag.String("config", "", "path to the JSON configuration file")
+	flag.Parse()
+	cfg := loadConfig(*cfgFile)
+
+	policy, err := auth.Load(cfg.Auth)
+	if err != nil {
+		logger.Fatal("auth setup failed", "err", err)
+	}
+	authn := &auth.Middleware{}
+	authn.SetPolicy(policy)
+	if len(cfg.Auth.Clients) == 0 {
+		logger.Warn("no API clients configured, the API is open")
+	}
+	cors, err := auth.NewCors(cfg.CORS)
+	if err != nil {
+		logger.Fatal("cors setup failed", "err", err)
+	}
+
+	// Start only dials the peers in the background; the state the
+	// handlers use must be in place before the first request.
+	balance.Start(cfg)
+	admin := &balance.AdminMiddleware{}
+	admin.SetToken(cfg.Admin.Token)
+	reloadOnSignal(*cfgFile, authn, admin)
 	api := rest.NewApi()
 	// api.Use(rest.DefaultDevStack...)
-	api.Use(&rest.CorsMiddleware{
-		RejectNonCorsRequests: false,
-		OriginValidator: func(origin string, request *rest.Request) bool {
-			return true
-		},
-		AllowedMethods:                []string{"GET", "OPTIONS"},
-		AllowedHeaders:                []string{"Accept", "Content-Type", "X-Requested-With", "X-Custome-Header", "Origin"},
-		AccessControlAllowCredentials: true,
-		AccessControlMaxAge:           3600,
-
-		// RejectNonCorsRequests: false,
-		// OriginValidator: func(origin string, request *rest.Request) bool {
-		// 	return true //origin == "http://my.other.host"
-		// },
-		// AllowedMethods: []string{"OPTIONS", "GET", "POST", "PUT"},
-		// AllowedHeaders: []string{
-		// 	"Accept", "Content-Type", "X-Custom-Header", "Origin"},
-		// AccessControlAllowCredentials: true,
-		// AccessControlMaxAge:           3600,
-	})
-	router, err := rest.MakeRouter(
-		&rest.Route{"GET", "/balance/:corp/:subr", balance.Balance},
+	api.Use(&logger.RequestMiddleware{})
+	// CORS goes first so that preflights, which carry no credentials,
+	// are answered before authentication.
+	api.Use(cors)
+	api.Use(admin)
+	api.Use(authn)
+	api.Use(&balance.LimitMiddleware{})
+	routes := metrics.Instrument(
+		&rest.Route{"GET", "/balance/:corp/:subr", auth.Allow(balance.OpBalance, balance.Balance)},
+		&rest.Route{"POST", "/balance/:corp/batch", auth.Allow(balance.OpBalance, balance.Batch)},
+		&rest.Route{"GET", "/balance/:corp/:subr/check", auth.Allow(balance.OpCheck, balance.Check)},
+		&rest.Route{"GET", "/price/:corp/:subr", auth.Allow(balance.OpPrice, balance.GetPrice)},
+		&rest.Route{"POST", "/debit/:corp/:subr", auth.Allow(balance.OpDebit, balance.Debit)},
+		&rest.Route{"POST", "/refund/:corp/:subr", auth.Allow(balance.OpRefund, balance.Refund)},
+		&rest.Route{"POST", "/sessions/:corp/:subr", auth.Allow(balance.OpSession, balance.CreateSession)},
+		&rest.Route{"GET", "/sessions/:id", auth.AllowCorp(balance.OpSession, balance.SessionCorp, balance.GetSession)},
+		&rest.Route{"PUT", "/sessions/:id", auth.AllowCorp(balance.OpSession, balance.SessionCorp, balance.UpdateSession)},
+		&rest.Route{"DELETE", "/sessions/:id", auth.AllowCorp(balance.OpSession, balance.SessionCorp, balance.DeleteSession)},
+		&rest.Route{"GET", "/stats/cache", auth.Allow(balance.OpStats, balance.Stats)},
+		&rest.Route{"GET", "/admin/capture", balance.GetCapture},
+		&rest.Route{"PUT", "/admin/capture", balance.PutCapture},
+		&rest.Route{"POST", "/admin/raw", balance.PostRaw},
+		&rest.Route{"POST", "/admin/reload", balance.PostReload},
 		// &rest.Route{"GET", "/dtn/:subr", dserve.DTNBalance},
 	)
+	// Probes are not instrumented so they do not drown the route metrics.
+	routes = append(routes,
+		&rest.Route{"GET", "/healthz", balance.Healthz},
+		&rest.Route{"GET", "/readyz", balance.Readyz},
+	)
+	router, err := rest.MakeRouter(routes...)
 	if err != nil {
-		log.Fatal(err)
+		logger.Fatal("router setup failed", "err", err)
 	}
 	api.SetApp(router)
 
-	fmt.Println("Start api 8088")
-	log.Fatal(http.ListenAndServe(":8088", api.MakeHandler()))
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/", api.MakeHandler())
+
+	logger.Info("start api", "listen", cfg.Listen)
+	logger.Fatal("api stopped", "err", http.ListenAndServe(cfg.Listen, mux))
 }
@@ -0,0 +1,174 @@
+// Package metrics defines the Prometheus collectors for Diameter and
+// HTTP traffic and serves them on /metrics.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"server/logger"
+)
+
+const namespace = "dccserve"
+
+var (
+	CCRSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ccr_sent_total",
+		Help:      "Credit-Control-Requests sent, by peer.",
+	}, []string{"peer"})
+
+	CCAReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cca_received_total",
+		Help:      "Credit-Control-Answers received, by peer and Result-Code.",
+	}, []string{"peer", "result_code"})
+
+	Timeouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ccr_timeouts_total",
+		Help:      "Credit-Control-Requests that got no answer in time, by peer.",
+	}, []string{"peer"})
+
+	DWRSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dwr_sent_total",
+		Help:      "Device-Watchdog-Requests sent, by peer.",
+	}, []string{"peer"})
+
+	DWAReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dwa_received_total",
+		Help:      "Device-Watchdog-Answers received, by peer.",
+	}, []string{"peer"})
+
+	Reconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "peer_reconnects_total",
+		Help:      "Times the connection to a peer was dialed again.",
+	}, []string{"peer"})
+
+	PeerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "peer_state",
+		Help:      "Peer state: 0 closed, 1 wait CEA, 2 okay, 3 suspect, 4 down.",
+	}, []string{"peer"})
+
+	Pending = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pending_transactions",
+		Help:      "Requests waiting for an answer, by peer.",
+	}, []string{"peer"})
+
+	RoundTrip = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "round_trip_seconds",
+		Help:      "Time from sending a CCR to receiving its CCA, by peer.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"peer"})
+
+	CacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Balance cache lookups by result (HIT, MISS, STALE).",
+	}, []string{"result"})
+
+	InvalidMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "invalid_messages_total",
+		Help:      "Diameter messages that broke the dictionary rules, by direction (in, out).",
+	}, []string{"direction"})
+
+	AuthDenied = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_denied_total",
+		Help:      "HTTP requests refused by authentication or authorization, by reason.",
+	}, []string{"reason"})
+
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Requests refused by a limit, by limit (client, corp, outstanding, busy).",
+	}, []string{"limit"})
+
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method, corp and status code.",
+	}, []string{"route", "method", "corp", "code"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and corp.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "corp"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		CCRSent, CCAReceived, Timeouts,
+		DWRSent, DWAReceived, Reconnects,
+		PeerState, Pending, RoundTrip,
+		CacheLookups, InvalidMessages, AuthDenied, RateLimited,
+		HTTPRequests, HTTPDuration,
+	)
+}
+
+// Handler serves the registered metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+var (
+	corpsLock sync.RWMutex
+	corps     map[string]bool
+)
+
+// SetCorps declares the configured corps. Any other corp in a request
+// path is counted as "other", so clients cannot grow the label set.
+func SetCorps(names []string) {
+	m := make(map[string]bool, len(names))
+	for _, n := range names {
+		m[n] = true
+	}
+	corpsLock.Lock()
+	corps = m
+	corpsLock.Unlock()
+}
+
+func corpLabel(corp string) string {
+	corpsLock.RLock()
+	defer corpsLock.RUnlock()
+	if corp == "" || corps[corp] {
+		return corp
+	}
+	return "other"
+}
+
+// Instrument wraps the handlers of routes so that every request is
+// counted and timed under the route's path expression.
+func Instrument(routes ...*rest.Route) []*rest.Route {
+	for _, r := range routes {
+		r.Func = instrument(r.PathExp, r.Func)
+	}
+	return routes
+}
+
+func instrument(route string, h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, req *rest.Request) {
+		start := time.Now()
+		sw := logger.NewStatusWriter(w)
+		h(sw, req)
+
+		corp := corpLabel(req.PathParam("corp"))
+		HTTPRequests.WithLabelValues(route, req.Method, corp, strconv.Itoa(sw.Code)).Inc()
+		HTTPDuration.WithLabelValues(route, req.Method, corp).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,17 @@
+package metrics
+
+import "testing"
+
+func TestCorpLabel(t *testing.T) {
+	SetCorps([]string{"dtac", "dtn"})
+	for corp, want := range map[string]string{
+		"dtac":    "dtac",
+		"dtn":     "dtn",
+		"":        "",
+		"x' or 1": "other",
+	} {
+		if got := corpLabel(corp); got != want {
+			t.Error("It should be "+want+" but was ", got)
+		}
+	}
+}
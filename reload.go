@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"server/auth"
+	"server/balance"
+	"server/logger"
+)
+
+// reloadOnSignal sets up reloading filename on SIGHUP and through
+// /admin/reload.
+func reloadOnSignal(filename string, authn *auth.Middleware, admin *balance.AdminMiddleware) {
+	balance.Reloader = func() (balance.ReloadResult, error) {
+		return reload(filename, authn, admin)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if _, err := reload(filename, authn, admin); err != nil {
+				logger.Error("reload failed", "file", filename, "err", err)
+			}
+		}
+	}()
+}
+
+// reload reads filename again and applies it. The API clients, admin
+// token and logging only change once the rest of the configuration is
+// in place.
+func reload(filename string, authn *auth.Middleware, admin *balance.AdminMiddleware) (balance.ReloadResult, error) {
+	cfg, level, err := readConfig(filename)
+	if err != nil {
+		return balance.ReloadResult{}, err
+	}
+	policy, err := auth.Load(cfg.Auth)
+	if err != nil {
+		return balance.ReloadResult{}, err
+	}
+	r, err := balance.Reload(cfg)
+	if err != nil {
+		return r, err
+	}
+	authn.SetPolicy(policy)
+	admin.SetToken(cfg.Admin.Token)
+	logger.SetLevel(level)
+	logger.SetTrace(cfg.Log.Trace)
+	logger.Info("configuration reloaded", "file", filename, "added", len(r.Added), "removed", len(r.Removed))
+	return r, nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+
+	"server/dictionary"
+	"server/logger"
+	"server/sim"
+)
+
+// runSim starts the embedded OCS simulator.
+func runSim(args []string) {
+	fs := flag.NewFlagSet("sim", flag.ExitOnError)
+	listen := fs.String("listen", ":3868", "address to accept Diameter connections on")
+	fixture := fs.String("fixture", "sim/testdata/subscribers.json", "JSON fixture of subscribers")
+	fs.Parse(args)
+
+	fx, err := sim.LoadFixture(*fixture)
+	if err != nil {
+		logger.Fatal("fixture load failed", "file", *fixture, "err", err)
+	}
+
+	logger.Info("start ocs simulator", "listen", *listen, "subscribers", len(fx.Subscribers))
+	logger.Fatal("simulator stopped", "err", sim.New(fx).ListenAndServe(*listen, dictionary.Load()))
+}
@@ -0,0 +1,88 @@
+package sim
+
+import (
+	"encoding/json"
+	"os"
+
+	"server/config"
+)
+
+// Fixture describes the subscribers known to the simulator and how it
+// misbehaves. Per-subscriber settings override the global ones.
+type Fixture struct {
+	// Latency delays every CCA.
+	Latency config.Duration `json:"latency"`
+	// DropRate is the fraction of CCRs, between 0 and 1, left
+	// unanswered.
+	DropRate float64 `json:"dropRate"`
+	// ResultCode, when set, is returned for every CCR.
+	ResultCode uint32 `json:"resultCode"`
+	// ValidityTime, when set, is sent with the units granted to a
+	// session, in seconds.
+	ValidityTime uint32 `json:"validityTime"`
+	// UnitPrice is the cost of one service specific unit, in
+	// hundredths of Currency, answered to price enquiries.
+	UnitPrice int64  `json:"unitPrice"`
+	Currency  uint32 `json:"currency"`
+
+	Subscribers map[string]*Subscriber `json:"subscribers"`
+}
+
+// Subscriber is the Huawei Balance-Information returned for a number.
+type Subscriber struct {
+	FirstActiveDate string    `json:"firstActiveDate"`
+	SubscriberState uint32    `json:"subscriberState"`
+	ActivePeriod    string    `json:"activePeriod"`
+	GracePeriod     string    `json:"gracePeriod"`
+	DisablePeriod   string    `json:"disablePeriod"`
+	Balance         int64     `json:"balance"`
+	LanguageIVR     int32     `json:"languageIVR"`
+	LanguageSMS     int32     `json:"languageSMS"`
+	LanguageUSSD    int32     `json:"languageUSSD"`
+	Accounts        []Account `json:"accounts"`
+	Offers          []Offer   `json:"offers"`
+
+	Latency    config.Duration `json:"latency"`
+	ResultCode uint32          `json:"resultCode"`
+	Drop       bool            `json:"drop"`
+}
+
+type Account struct {
+	AccountID             string `json:"accountId"`
+	AccountType           uint32 `json:"accountType"`
+	AccountTypeDesc       string `json:"accountTypeDesc"`
+	AccountBeginDate      string `json:"accountBeginDate"`
+	RelatedType           uint32 `json:"relatedType"`
+	RelatedObjectID       string `json:"relatedObjectId"`
+	CurrentAccountBalance int64  `json:"currentAccountBalance"`
+	AccountEndDate        string `json:"accountEndDate"`
+	MeasureType           int32  `json:"measureType"`
+	ShareFlag             int32  `json:"shareFlag"`
+}
+
+type Offer struct {
+	OfferID                  string `json:"offerId"`
+	OfferOrderKey            string `json:"offerOrderKey"`
+	EffectiveTime            string `json:"effectiveTime"`
+	ExpireTime               string `json:"expireTime"`
+	Status                   string `json:"status"`
+	CurrentCycle             int32  `json:"currentCycle"`
+	TotalCycle               int32  `json:"totalCycle"`
+	OfferOrderIntegrationKey string `json:"offerOrderIntegrationKey"`
+	ExternalOfferCode        string `json:"externalOfferCode"`
+}
+
+// LoadFixture reads a JSON fixture file.
+func LoadFixture(filename string) (*Fixture, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var fx Fixture
+	if err = json.NewDecoder(f).Decode(&fx); err != nil {
+		return nil, err
+	}
+	return &fx, nil
+}
@@ -0,0 +1,370 @@
+// Package sim is a minimal OCS that answers CER, DWR and Huawei balance
+// CCRs from a fixture, for local development and tests.
+package sim
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
+	"github.com/fiorix/go-diameter/diam/datatype"
+	"github.com/fiorix/go-diameter/diam/dict"
+
+	"server/avps"
+	"server/logger"
+)
+
+// UserUnknown is the Result-Code for a subscriber missing from the
+// fixture (DIAMETER_USER_UNKNOWN, RFC 4006).
+const UserUnknown = 5030
+
+type Simulator struct {
+	Identity datatype.DiameterIdentity
+	Realm    datatype.DiameterIdentity
+
+	// Observe, when set, is called with every CCR received.
+	Observe func(m *diam.Message)
+
+	mu      sync.RWMutex
+	fixture *Fixture
+	// conns are the clients that sent a CER, which RAR and ASR are
+	// sent to. answers wait for the RAA or ASA with a Hop-by-Hop Id.
+	conns   map[diam.Conn]bool
+	answers map[uint32]chan *diam.Message
+}
+
+// ErrNoClient is returned when no client is connected to send a server
+// request to.
+var ErrNoClient = errors.New("sim: no client connected")
+
+// ErrNoAnswer is returned when the client does not answer a server
+// request in time.
+var ErrNoAnswer = errors.New("sim: no answer from the client")
+
+// answerTimeout is how long ReAuth and Abort wait for the answer.
+const answerTimeout = 2 * time.Second
+
+func New(f *Fixture) *Simulator {
+	return &Simulator{
+		Identity: "ocs-sim",
+		Realm:    "www.huawei.com",
+		fixture:  f,
+		conns:    make(map[diam.Conn]bool),
+		answers:  make(map[uint32]chan *diam.Message),
+	}
+}
+
+// SetFixture replaces the fixture used for new requests.
+func (s *Simulator) SetFixture(f *Fixture) {
+	s.mu.Lock()
+	s.fixture = f
+	s.mu.Unlock()
+}
+
+// Handler returns the Diameter handler, suitable for diamtest.NewServer.
+func (s *Simulator) Handler() diam.Handler {
+	mux := diam.NewServeMux()
+	mux.HandleFunc("CER", s.onCER)
+	mux.HandleFunc("DWR", s.onDWR)
+	mux.HandleFunc("CCR", s.onCCR)
+	mux.HandleFunc("RAA", s.onAnswer)
+	mux.HandleFunc("ASA", s.onAnswer)
+	go func() {
+		for err := range mux.ErrorReports() {
+			logger.Warn("sim error", "err", err.Error)
+		}
+	}()
+	return mux
+}
+
+func (s *Simulator) ListenAndServe(addr string, dp *dict.Parser) error {
+	return diam.ListenAndServe(addr, s.Handler(), dp)
+}
+
+func (s *Simulator) onCER(c diam.Conn, m *diam.Message) {
+	a := m.Answer(diam.Success)
+	a.NewAVP(avp.OriginHost, avp.Mbit, 0, s.Identity)
+	a.NewAVP(avp.OriginRealm, avp.Mbit, 0, s.Realm)
+	ip, _, _ := net.SplitHostPort(c.LocalAddr().String())
+	a.NewAVP(avp.HostIPAddress, avp.Mbit, 0, datatype.Address(net.ParseIP(ip)))
+	a.NewAVP(avp.VendorID, avp.Mbit, 0, datatype.Unsigned32(0))
+	a.NewAVP(avp.ProductName, 0, 0, datatype.UTF8String("dccserve-sim"))
+	a.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(4))
+	a.WriteTo(c)
+
+	s.mu.Lock()
+	s.conns[c] = true
+	s.mu.Unlock()
+	if cn, ok := c.(diam.CloseNotifier); ok {
+		go func() {
+			<-cn.CloseNotify()
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// ReAuth sends a Re-Auth-Request for sessionID to a connected client
+// and returns the Result-Code of its answer.
+func (s *Simulator) ReAuth(sessionID string) (uint32, error) {
+	return s.serverRequest(diam.ReAuth, sessionID)
+}
+
+// Abort sends an Abort-Session-Request for sessionID to a connected
+// client and returns the Result-Code of its answer.
+func (s *Simulator) Abort(sessionID string) (uint32, error) {
+	return s.serverRequest(diam.AbortSession, sessionID)
+}
+
+func (s *Simulator) serverRequest(cmd uint32, sessionID string) (uint32, error) {
+	m := diam.NewRequest(cmd, 4, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String(sessionID))
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, s.Identity)
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, s.Realm)
+	m.NewAVP(avp.DestinationRealm, avp.Mbit, 0, datatype.DiameterIdentity("dtac.co.th"))
+	m.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(4))
+	if cmd == diam.ReAuth {
+		m.NewAVP(avp.ReAuthRequestType, avp.Mbit, 0, datatype.Enumerated(0))
+	}
+
+	ch := make(chan *diam.Message, 1)
+	s.mu.Lock()
+	var c diam.Conn
+	for conn := range s.conns {
+		c = conn
+		break
+	}
+	s.answers[m.Header.HopByHopID] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.answers, m.Header.HopByHopID)
+		s.mu.Unlock()
+	}()
+	if c == nil {
+		return 0, ErrNoClient
+	}
+
+	if _, err := m.WriteTo(c); err != nil {
+		return 0, err
+	}
+	select {
+	case a := <-ch:
+		var r struct {
+			ResultCode uint32 `avp:"Result-Code"`
+		}
+		if err := a.Unmarshal(&r); err != nil {
+			return 0, err
+		}
+		return r.ResultCode, nil
+	case <-time.After(answerTimeout):
+		return 0, ErrNoAnswer
+	}
+}
+
+// onAnswer hands a RAA or ASA to the server request waiting for it.
+func (s *Simulator) onAnswer(c diam.Conn, m *diam.Message) {
+	s.mu.RLock()
+	ch, ok := s.answers[m.Header.HopByHopID]
+	s.mu.RUnlock()
+	if !ok {
+		return
+	}
+	select {
+	case ch <- m:
+	default:
+	}
+}
+
+func (s *Simulator) onDWR(c diam.Conn, m *diam.Message) {
+	a := m.Answer(diam.Success)
+	a.NewAVP(avp.OriginHost, avp.Mbit, 0, s.Identity)
+	a.NewAVP(avp.OriginRealm, avp.Mbit, 0, s.Realm)
+	a.WriteTo(c)
+}
+
+type ccr struct {
+	SessionID       string `avp:"Session-Id"`
+	CCRequestType   int    `avp:"CC-Request-Type"`
+	CCRequestNumber int    `avp:"CC-Request-Number"`
+	RequestedAction int    `avp:"Requested-Action"`
+	Requested       struct {
+		Units uint64 `avp:"CC-Service-Specific-Units"`
+	} `avp:"Requested-Service-Unit"`
+	SubscriptionID struct {
+		Type int    `avp:"Subscription-Id-Type"`
+		Data string `avp:"Subscription-Id-Data"`
+	} `avp:"Subscription-Id"`
+	MSCC []struct {
+		Requested struct {
+			Time        uint32 `avp:"CC-Time"`
+			TotalOctets uint64 `avp:"CC-Total-Octets"`
+		} `avp:"Requested-Service-Unit"`
+		RatingGroup uint32 `avp:"Rating-Group"`
+	} `avp:"Multiple-Services-Credit-Control"`
+}
+
+func (s *Simulator) onCCR(c diam.Conn, m *diam.Message) {
+	if s.Observe != nil {
+		s.Observe(m)
+	}
+
+	var req ccr
+	if err := m.Unmarshal(&req); err != nil {
+		logger.Warn("sim: bad ccr", "err", err)
+		s.answer(c, m, req, diam.UnableToComply, nil, nil)
+		return
+	}
+
+	s.mu.RLock()
+	f := s.fixture
+	s.mu.RUnlock()
+
+	sub, ok := f.Subscribers[req.SubscriptionID.Data]
+	latency := f.Latency.Duration
+	code := uint32(diam.Success)
+	if f.ResultCode != 0 {
+		code = f.ResultCode
+	}
+	switch {
+	case !ok:
+		code = UserUnknown
+	case sub.ResultCode != 0:
+		code = sub.ResultCode
+	}
+	if ok && sub.Latency.Duration > 0 {
+		latency = sub.Latency.Duration
+	}
+
+	if (ok && sub.Drop) || (f.DropRate > 0 && rand.Float64() < f.DropRate) {
+		logger.Info("sim: dropping ccr", "session_id", req.SessionID)
+		return
+	}
+
+	go func() {
+		time.Sleep(latency)
+		s.answer(c, m, req, code, sub, f)
+	}()
+}
+
+// answer sends the CCA to req. The units requested for a session are
+// granted in full, for the Validity-Time of f when it is set, and price
+// enquiries are answered at the UnitPrice of f.
+func (s *Simulator) answer(c diam.Conn, m *diam.Message, req ccr, code uint32, sub *Subscriber, f *Fixture) {
+	a := m.Answer(code)
+	a.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String(req.SessionID))
+	a.NewAVP(avp.OriginHost, avp.Mbit, 0, s.Identity)
+	a.NewAVP(avp.OriginRealm, avp.Mbit, 0, s.Realm)
+	a.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(4))
+	a.NewAVP(avp.CCRequestType, avp.Mbit, 0, datatype.Enumerated(req.CCRequestType))
+	a.NewAVP(avp.CCRequestNumber, avp.Mbit, 0, datatype.Unsigned32(req.CCRequestNumber))
+	if code == diam.Success && sub != nil {
+		a.NewAVP(avp.ServiceInformation, avp.Mbit, 0, &diam.GroupedAVP{
+			AVP: []*diam.AVP{balanceInfo(sub)},
+		})
+		for _, mscc := range req.MSCC {
+			a.NewAVP(avp.MultipleServicesCreditControl, avp.Mbit, 0, grant(mscc.Requested.Time, mscc.Requested.TotalOctets, mscc.RatingGroup, f.ValidityTime))
+		}
+		if req.RequestedAction == priceEnquiry && f.UnitPrice > 0 {
+			a.NewAVP(avp.CostInformation, avp.Mbit, 0, cost(int64(req.Requested.Units)*f.UnitPrice, f.Currency))
+		}
+	}
+	if _, err := a.WriteTo(c); err != nil {
+		logger.Warn("sim: write failed", "err", err)
+	}
+}
+
+// priceEnquiry is the Requested-Action of a price enquiry (RFC 4006).
+const priceEnquiry = 3
+
+// cost builds the Cost-Information of value hundredths of currency.
+func cost(value int64, currency uint32) *diam.GroupedAVP {
+	return &diam.GroupedAVP{AVP: []*diam.AVP{
+		diam.NewAVP(avp.UnitValue, avp.Mbit, 0, &diam.GroupedAVP{AVP: []*diam.AVP{
+			diam.NewAVP(avp.ValueDigits, avp.Mbit, 0, datatype.Integer64(value)),
+			diam.NewAVP(avp.Exponent, avp.Mbit, 0, datatype.Integer32(-2)),
+		}}),
+		diam.NewAVP(avp.CurrencyCode, avp.Mbit, 0, datatype.Unsigned32(currency)),
+	}}
+}
+
+// grant builds the Multiple-Services-Credit-Control granting seconds
+// and octets to ratingGroup.
+func grant(seconds uint32, octets uint64, ratingGroup, validity uint32) *diam.GroupedAVP {
+	gsu := &diam.GroupedAVP{}
+	if seconds > 0 {
+		gsu.AVP = append(gsu.AVP, diam.NewAVP(avp.CCTime, avp.Mbit, 0, datatype.Unsigned32(seconds)))
+	}
+	if octets > 0 {
+		gsu.AVP = append(gsu.AVP, diam.NewAVP(avp.CCTotalOctets, avp.Mbit, 0, datatype.Unsigned64(octets)))
+	}
+	g := &diam.GroupedAVP{AVP: []*diam.AVP{
+		diam.NewAVP(avp.GrantedServiceUnit, avp.Mbit, 0, gsu),
+		diam.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(diam.Success)),
+	}}
+	if ratingGroup > 0 {
+		g.AVP = append(g.AVP, diam.NewAVP(avp.RatingGroup, avp.Mbit, 0, datatype.Unsigned32(ratingGroup)))
+	}
+	if validity > 0 {
+		g.AVP = append(g.AVP, diam.NewAVP(avp.ValidityTime, avp.Mbit, 0, datatype.Unsigned32(validity)))
+	}
+	return g
+}
+
+func balanceInfo(sub *Subscriber) *diam.AVP {
+	g := &diam.GroupedAVP{AVP: []*diam.AVP{
+		diam.NewAVP(avps.FirstActiveDate, avp.Mbit, 0, datatype.OctetString(sub.FirstActiveDate)),
+		diam.NewAVP(avps.SubscriberState, avp.Mbit, 0, datatype.Unsigned32(sub.SubscriberState)),
+		diam.NewAVP(avps.ActivePeriod, avp.Mbit, 0, datatype.OctetString(sub.ActivePeriod)),
+		diam.NewAVP(avps.GracePeriod, avp.Mbit, 0, datatype.OctetString(sub.GracePeriod)),
+		diam.NewAVP(avps.DisablePeriod, avp.Mbit, 0, datatype.OctetString(sub.DisablePeriod)),
+		diam.NewAVP(avps.Balance, avp.Mbit, 0, datatype.Integer64(sub.Balance)),
+		diam.NewAVP(avps.LanguageIVR, avp.Mbit, 0, datatype.Integer32(sub.LanguageIVR)),
+		diam.NewAVP(avps.LanguageSMS, avp.Mbit, 0, datatype.Integer32(sub.LanguageSMS)),
+		diam.NewAVP(avps.LanguageUSSD, avp.Mbit, 0, datatype.Integer32(sub.LanguageUSSD)),
+	}}
+
+	for _, ac := range sub.Accounts {
+		g.AVP = append(g.AVP, diam.NewAVP(avps.AccountChangeInfo, avp.Mbit, 0, &diam.GroupedAVP{
+			AVP: []*diam.AVP{
+				diam.NewAVP(avps.AccountId, avp.Mbit, 0, datatype.OctetString(ac.AccountID)),
+				diam.NewAVP(avps.AccountType, avp.Mbit, 0, datatype.Unsigned32(ac.AccountType)),
+				diam.NewAVP(avps.AccountTypeDesc, avp.Mbit, 0, datatype.OctetString(ac.AccountTypeDesc)),
+				diam.NewAVP(avps.AccountBeginDate, avp.Mbit, 0, datatype.OctetString(ac.AccountBeginDate)),
+				diam.NewAVP(avps.RelatedType, avp.Mbit, 0, datatype.Unsigned32(ac.RelatedType)),
+				diam.NewAVP(avps.RelatedObjectID, avp.Mbit, 0, datatype.OctetString(ac.RelatedObjectID)),
+				diam.NewAVP(avps.CurrentAccountBalance, avp.Mbit, 0, datatype.Integer64(ac.CurrentAccountBalance)),
+				diam.NewAVP(avps.AccountEndDate, avp.Mbit, 0, datatype.OctetString(ac.AccountEndDate)),
+				diam.NewAVP(avps.MeasureType, avp.Mbit, 0, datatype.Integer32(ac.MeasureType)),
+				diam.NewAVP(avps.ShareFlag, avp.Mbit, 0, datatype.Integer32(ac.ShareFlag)),
+			},
+		}))
+	}
+
+	if len(sub.Offers) > 0 {
+		offers := &diam.GroupedAVP{}
+		for _, o := range sub.Offers {
+			offers.AVP = append(offers.AVP, diam.NewAVP(avps.OfferInfo, avp.Mbit, 0, &diam.GroupedAVP{
+				AVP: []*diam.AVP{
+					diam.NewAVP(avps.OfferId, avp.Mbit, 0, datatype.UTF8String(o.OfferID)),
+					diam.NewAVP(avps.OfferOrderKey, avp.Mbit, 0, datatype.UTF8String(o.OfferOrderKey)),
+					diam.NewAVP(avps.EffectiveTime, avp.Mbit, 0, datatype.UTF8String(o.EffectiveTime)),
+					diam.NewAVP(avps.ExpireTime, avp.Mbit, 0, datatype.UTF8String(o.ExpireTime)),
+					diam.NewAVP(avps.Status, avp.Mbit, 0, datatype.UTF8String(o.Status)),
+					diam.NewAVP(avps.CurrentCycle, avp.Mbit, 0, datatype.Integer32(o.CurrentCycle)),
+					diam.NewAVP(avps.TotalCycle, avp.Mbit, 0, datatype.Integer32(o.TotalCycle)),
+					diam.NewAVP(avps.OfferOrderIntegrationKey, avp.Mbit, 0, datatype.UTF8String(o.OfferOrderIntegrationKey)),
+					diam.NewAVP(avps.ExternalOfferCode, avp.Mbit, 0, datatype.UTF8String(o.ExternalOfferCode)),
+				},
+			}))
+		}
+		g.AVP = append(g.AVP, diam.NewAVP(avps.OfferInformation, avp.Mbit, 0, offers))
+	}
+
+	return diam.NewAVP(avps.BalanceInformation, avp.Mbit, 0, g)
+}
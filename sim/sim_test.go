@@ -0,0 +1,111 @@
+package sim_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
+	"github.com/fiorix/go-diameter/diam/datatype"
+	"github.com/fiorix/go-diameter/diam/diamtest"
+
+	"server/dictionary"
+	"server/sim"
+)
+
+type cca struct {
+	SessionID          string `avp:"Session-Id"`
+	ResultCode         int    `avp:"Result-Code"`
+	ServiceInformation struct {
+		BalanceInformation struct {
+			Balance int `avp:"Balance"`
+		} `avp:"Balance-Information"`
+	} `avp:"Service-Information"`
+}
+
+func exchange(t *testing.T, subr string) (cca, bool) {
+	dp := dictionary.Load()
+	fx, err := sim.LoadFixture("testdata/subscribers.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := diamtest.NewServer(sim.New(fx).Handler(), dp)
+	defer srv.Close()
+
+	answers := make(chan cca, 1)
+	cmux := diam.NewServeMux()
+	cmux.HandleFunc("CCA", func(c diam.Conn, m *diam.Message) {
+		var a cca
+		m.Unmarshal(&a)
+		answers <- a
+	})
+
+	cli, err := diam.Dial(srv.Address, cmux, dp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	r := diam.NewRequest(diam.CreditControl, 4, dp)
+	r.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("sim;1"))
+	r.NewAVP(avp.CCRequestType, avp.Mbit, 0, datatype.Enumerated(4))
+	r.NewAVP(avp.CCRequestNumber, avp.Mbit, 0, datatype.Unsigned32(0))
+	r.NewAVP(avp.SubscriptionID, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(avp.SubscriptionIDType, avp.Mbit, 0, datatype.Enumerated(0)),
+			diam.NewAVP(avp.SubscriptionIDData, avp.Mbit, 0, datatype.UTF8String(subr)),
+		},
+	})
+	if _, err := r.WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case a := <-answers:
+		return a, true
+	case <-time.After(500 * time.Millisecond):
+		return cca{}, false
+	}
+}
+
+func TestBalanceFromFixture(t *testing.T) {
+	a, ok := exchange(t, "66812345678")
+	if !ok {
+		t.Fatal("Timed out: no CCA received")
+	}
+	if a.ResultCode != diam.Success {
+		t.Error("It should be 2001 but was ", a.ResultCode)
+	}
+	if a.SessionID != "sim;1" {
+		t.Error("It should be sim;1 but was ", a.SessionID)
+	}
+	if b := a.ServiceInformation.BalanceInformation.Balance; b != 12550 {
+		t.Error("It should be 12550 but was ", b)
+	}
+}
+
+func TestUnknownSubscriber(t *testing.T) {
+	a, ok := exchange(t, "66811111111")
+	if !ok {
+		t.Fatal("Timed out: no CCA received")
+	}
+	if a.ResultCode != sim.UserUnknown {
+		t.Error("It should be 5030 but was ", a.ResultCode)
+	}
+}
+
+func TestFixtureResultCode(t *testing.T) {
+	a, ok := exchange(t, "66899999999")
+	if !ok {
+		t.Fatal("Timed out: no CCA received")
+	}
+	if a.ResultCode != 4012 {
+		t.Error("It should be 4012 but was ", a.ResultCode)
+	}
+}
+
+func TestDroppedAnswer(t *testing.T) {
+	if _, ok := exchange(t, "66800000000"); ok {
+		t.Error("It should drop the answer")
+	}
+}